@@ -0,0 +1,279 @@
+package agent
+
+import (
+	"compass/api"
+	"compass/client"
+	"compass/scope"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The environment variable that points tools to the agent socket
+const SOCKET_ENV = "COMPASS_AGENT_SOCK"
+
+// How long a token from the agent is valid when the request doesn't say
+const DEFAULT_VALIDITY = 15 * time.Minute
+
+// The longest validity the agent grants, unless told otherwise
+const DEFAULT_MAX_VALIDITY = 24 * time.Hour
+
+// A request for a token
+type Request struct {
+	// Who is asking, shown when the request is approved
+	Requester string `json:"requester,omitempty"`
+	Name      string `json:"name"`
+	// The scope in text form, e.g. "zone:Finance get,list"
+	Scope string `json:"scope"`
+	// Validity in seconds. Defaults to DEFAULT_VALIDITY
+	Validity int64 `json:"validity,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Decides whether a request is granted
+type Approver interface {
+	Approve(r Request) bool
+}
+
+// Approve requests with a function
+type ApproverFunc func(r Request) bool
+
+func (f ApproverFunc) Approve(r Request) bool {
+	return f(r)
+}
+
+// Grants every request
+var ApproveAll = ApproverFunc(func(Request) bool { return true })
+
+// Serves tokens to local tools with the session of the user
+type Server struct {
+	client      client.ClientInterface
+	key         string
+	approver    Approver
+	maxValidity time.Duration
+	clients     []scope.Client
+	// The client isn't safe for concurrent use
+	mu sync.Mutex
+}
+
+// Require every request to carry this key
+func WithKey(key string) func(*Server) {
+	return func(s *Server) {
+		s.key = key
+	}
+}
+
+func WithApprover(a Approver) func(*Server) {
+	return func(s *Server) {
+		s.approver = a
+	}
+}
+
+func WithMaxValidity(d time.Duration) func(*Server) {
+	return func(s *Server) {
+		s.maxValidity = d
+	}
+}
+
+// The clients tokens are issued for. Defaults to SynkzoneSSI
+func WithClients(clients []scope.Client) func(*Server) {
+	return func(s *Server) {
+		s.clients = clients
+	}
+}
+
+func New(c client.ClientInterface, options ...func(*Server)) *Server {
+	s := &Server{
+		client:      c,
+		approver:    ApproveAll,
+		maxValidity: DEFAULT_MAX_VALIDITY,
+		clients:     []scope.Client{scope.ClientSSI},
+	}
+	for _, o := range options {
+		o(s)
+	}
+	return s
+}
+
+// The http handler of the agent API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /tokens", s.createToken)
+	return s.authenticate(mux)
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given := []byte(r.Header.Get("Authorization"))
+		expected := []byte("Bearer " + s.key)
+		if s.key == "" || subtle.ConstantTimeCompare(given, expected) != 1 {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("Invalid agent key"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) createToken(w http.ResponseWriter, r *http.Request) {
+	req := Request{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("Could not read request :: %+v", err))
+		return
+	}
+	if req.Validity == 0 {
+		req.Validity = int64(DEFAULT_VALIDITY / time.Second)
+	}
+	if req.Validity < 0 || time.Duration(req.Validity)*time.Second > s.maxValidity {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("Validity must be between 1 and %d seconds", int64(s.maxValidity/time.Second)))
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("A token needs a name"))
+		return
+	}
+	if req.Requester == "" {
+		req.Requester = "an unnamed tool"
+	}
+
+	s.mu.Lock()
+	zones, err := api.GetZones(s.client)
+	s.mu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	perms, err := scope.ParseScope(req.Scope, zones)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(perms) == 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("The scope grants nothing"))
+		return
+	}
+
+	name, validity := req.Name, req.Validity
+	tokenScope := scope.SPScope{
+		Name:        name,
+		Description: "Requested from the compass agent by " + req.Requester,
+		Clients:     s.clients,
+		Permissions: perms,
+	}
+	if err := tokenScope.ValidateClients(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if !s.approver.Approve(req) {
+		writeError(w, http.StatusForbidden, fmt.Errorf("The request was denied"))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, err := api.CreateToken(s.client, scope.NewTokenRequest{
+		Scope:     tokenScope,
+		TokenName: &name,
+		Validity:  &validity,
+	})
+	if err != nil {
+		writeError(w, http.StatusBadGateway, fmt.Errorf("Could not create token :: %+v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: err.Error()})
+}
+
+// The socket in the users runtime directory, e.g. /run/user/1000/compass/agent.sock, or
+// the config directory when there is none. $COMPASS_AGENT_SOCK takes precedence.
+// The directory is created if it doesn't exist.
+func DefaultSocketPath() (string, error) {
+	if path := os.Getenv(SOCKET_ENV); path != "" {
+		return path, nil
+	}
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		var err error
+		if dir, err = os.UserConfigDir(); err != nil {
+			return "", err
+		}
+	}
+	dir = filepath.Join(dir, "compass")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "agent.sock"), nil
+}
+
+// Where the key for the socket at path is kept
+func KeyPath(socket string) string {
+	return socket + ".key"
+}
+
+// A random key for the agent API
+func NewKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("Could not create agent key :: %+v", err)
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// Listen on a Unix socket that only the user can use, and write the key next to it.
+// A socket left behind by an agent that is gone is replaced, anything else at path is left alone
+func Listen(path string, key string) (net.Listener, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("An agent is already listening on %s", path)
+	}
+	info, err := os.Lstat(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("Could not check for an old socket :: %+v", err)
+	}
+	if err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("Could not remove old socket :: %+v", err)
+		}
+	}
+
+	// Created without access for others, rather than restricted after anyone could have connected
+	umask := syscall.Umask(0177)
+	l, err := net.Listen("unix", path)
+	syscall.Umask(umask)
+	if err != nil {
+		return nil, fmt.Errorf("Could not listen on %s :: %+v", path, err)
+	}
+	if err := os.WriteFile(KeyPath(path), []byte(key+"\n"), 0600); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("Could not write agent key :: %+v", err)
+	}
+	return l, nil
+}
+
+// Stop listening and remove the key
+func Close(l net.Listener) error {
+	os.Remove(KeyPath(l.Addr().String()))
+	return l.Close()
+}
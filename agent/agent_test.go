@@ -0,0 +1,167 @@
+package agent
+
+import (
+	"compass/client"
+	"compass/scope"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testZoneId = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+
+// A fake SSI that records the token requests it receives
+func ssiServer(t *testing.T, created *[]scope.NewTokenRequest) client.ClientInterface {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "test-session" {
+			t.Errorf("expected session token, got %q", r.Header.Get("Authorization"))
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/zones":
+			w.Write([]byte(`[{"name": "Finance", "id": "` + testZoneId + `"}]`))
+		case r.Method == http.MethodPost && r.URL.Path == "/tokens":
+			req := scope.NewTokenRequest{}
+			json.NewDecoder(r.Body).Decode(&req)
+			*created = append(*created, req)
+			w.Write([]byte(`{"token": "secret", "expiresAt": "2030-01-01T00:00:00Z", "id": "66666666-6666-6666-6666-666666666666"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return client.NewClient(server.URL, client.WithAuth("test-session"))
+}
+
+// Serve s on a socket in a temporary directory and return its path
+func serve(t *testing.T, s *Server, key string) string {
+	path := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := Listen(path, key)
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	go http.Serve(l, s.Handler())
+	t.Cleanup(func() { Close(l) })
+	return path
+}
+
+func TestAgent_Token(t *testing.T) {
+	created := []scope.NewTokenRequest{}
+	approved := []Request{}
+	s := New(ssiServer(t, &created), WithKey("key"), WithApprover(ApproverFunc(func(r Request) bool {
+		approved = append(approved, r)
+		return true
+	})))
+	socket := serve(t, s, "key")
+
+	info, err := os.Stat(socket)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected a socket only the user can use, got %v, %v", info, err)
+	}
+
+	c, err := Dial(socket)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	res, err := c.Token(Request{Requester: "sync", Name: "sync", Scope: "zone:Finance get,list"})
+	if err != nil || res.Token != "secret" {
+		t.Fatalf("expected a token, got %+v, %v", res, err)
+	}
+	if len(approved) != 1 || approved[0].Requester != "sync" {
+		t.Errorf("expected the request to be approved, got %+v", approved)
+	}
+	if len(created) != 1 || *created[0].Validity != 900 {
+		t.Fatalf("expected a token with the default validity, got %+v", created)
+	}
+	if p := created[0].Scope.Permissions["Zone="+testZoneId]; !p.Get || !p.List || p.All {
+		t.Errorf("unexpected permissions %+v", created[0].Scope.Permissions)
+	}
+}
+
+func TestAgent_Errors(t *testing.T) {
+	created := []scope.NewTokenRequest{}
+	deny := ApproverFunc(func(r Request) bool { return r.Name != "denied" })
+	socket := serve(t, New(ssiServer(t, &created), WithKey("key"), WithApprover(deny)), "key")
+
+	tests := []struct {
+		key      string
+		request  Request
+		expected string
+	}{
+		{"wrong", Request{Name: "sync", Scope: "zone:Finance get"}, "Invalid agent key"},
+		{"key", Request{Name: "denied", Scope: "zone:Finance get"}, "denied"},
+		{"key", Request{Name: "sync", Scope: "zone:Finance get", Validity: 2 * 24 * 3600}, "Validity"},
+		{"key", Request{Name: "sync", Scope: "zone:Nope get"}, "Nope"},
+		{"key", Request{Scope: "zone:Finance get"}, "name"},
+	}
+	for _, test := range tests {
+		_, err := NewClient(socket, test.key).Token(test.request)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%+v: expected error containing %q, got %v", test.request, test.expected, err)
+		}
+	}
+	if len(created) != 0 {
+		t.Errorf("expected no tokens, got %+v", created)
+	}
+}
+
+func TestListen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := Listen(path, "key")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := Listen(path, "other"); err == nil {
+		t.Error("expected error while another agent is listening")
+	}
+	if err := Close(l); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if _, err := os.Stat(KeyPath(path)); !os.IsNotExist(err) {
+		t.Errorf("expected the key to be removed, got %v", err)
+	}
+
+	// A stale socket is replaced
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	l, err = Listen(path, "key")
+	if err != nil {
+		t.Fatalf("expected a stale socket to be replaced, got %v", err)
+	}
+	Close(l)
+
+	// Anything else is left alone
+	other := filepath.Join(t.TempDir(), "agent.sock")
+	os.WriteFile(other, []byte("data"), 0600)
+	if _, err := Listen(other, "key"); err == nil {
+		t.Error("expected an error for a file that isn't a socket")
+	}
+	if data, _ := os.ReadFile(other); string(data) != "data" {
+		t.Errorf("expected the file to be left alone, got %q", data)
+	}
+}
+
+func TestAgent_Clients(t *testing.T) {
+	defer scope.RegisterClient(scope.ClientInfo{Client: "Reader", Options: scope.S_GET})()
+	created := []scope.NewTokenRequest{}
+	socket := serve(t, New(ssiServer(t, &created), WithKey("key"), WithClients([]scope.Client{"Reader"})), "key")
+	c := NewClient(socket, "key")
+
+	if _, err := c.Token(Request{Name: "sync", Scope: "zone:Finance get"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(created) != 1 || len(created[0].Scope.Clients) != 1 || created[0].Scope.Clients[0] != "Reader" {
+		t.Errorf("expected a token for Reader, got %+v", created)
+	}
+	if _, err := c.Token(Request{Name: "sync", Scope: "zone:Finance get,list"}); err == nil || !strings.Contains(err.Error(), "none of the clients") {
+		t.Errorf("expected the clients to reject list, got %v", err)
+	}
+}
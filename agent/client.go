@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"bytes"
+	"compass/scope"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Requests tokens from a running agent
+type Client struct {
+	socket string
+	key    string
+	http   http.Client
+}
+
+// Connect to the agent at socket, reading its key from next to it
+func Dial(socket string) (*Client, error) {
+	key, err := os.ReadFile(KeyPath(socket))
+	if err != nil {
+		return nil, fmt.Errorf("Could not read agent key, is compass agent running? :: %+v", err)
+	}
+	return NewClient(socket, strings.TrimSpace(string(key))), nil
+}
+
+func NewClient(socket string, key string) *Client {
+	return &Client{
+		socket: socket,
+		key:    key,
+		http: http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// Ask the agent for a token. Blocks until the request is approved or denied
+func (c *Client) Token(r Request) (scope.TokenResult, error) {
+	res := scope.TokenResult{}
+	body, err := json.Marshal(r)
+	if err != nil {
+		return res, err
+	}
+	req, err := http.NewRequest(http.MethodPost, "http://agent/tokens", bytes.NewReader(body))
+	if err != nil {
+		return res, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.key)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return res, fmt.Errorf("Could not reach the agent on %s :: %+v", c.socket, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return res, err
+	}
+	if resp.StatusCode >= 300 {
+		e := errorResponse{}
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			return res, fmt.Errorf("%s", e.Error)
+		}
+		return res, fmt.Errorf("%s :: %s", resp.Status, data)
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return res, fmt.Errorf("Could not read token :: %+v", err)
+	}
+	return res, nil
+}
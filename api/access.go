@@ -0,0 +1,63 @@
+package api
+
+import (
+	"compass/client"
+	"compass/scope"
+)
+
+type LogonRequest struct {
+	AcceptSessions   *bool          `json:"acceptSessions,omitempty"`
+	AutoLogon        *bool          `json:"autoLogon,omitempty"`
+	LogonSessionId   *string        `json:"logonSessionId,omitempty"`
+	OrganizationName *string        `json:"organizationName,omitempty"`
+	Password         *[]string      `json:"password,omitempty"`
+	PasswordAsString *string        `json:"passwordAsString,omitempty"`
+	SavePassword     *bool          `json:"savePassword,omitempty"`
+	Scope            *scope.SPScope `json:"scope,omitempty"`
+	SetCookie        *bool          `json:"setCookie,omitempty"`
+	Timeout          *int64         `json:"timeout,omitempty"`
+	TimeoutInSeconds *int64         `json:"timeoutInSeconds,omitempty"`
+	Token            *string        `json:"token,omitempty"`
+	Username         *string        `json:"username,omitempty"`
+	VerificationCode *string        `json:"verificationCode,omitempty"`
+}
+
+type LogonResult struct {
+	ExpiresInMillis          *int64  `json:"expiresInMillis,omitempty"`
+	IdpURI                   *string `json:"idpURI,omitempty"`
+	LogonOK                  bool    `json:"logonOK"`
+	PasswordChangeIsRequired *bool   `json:"passwordChangeIsRequired,omitempty"`
+	SessionId                *string `json:"sessionId,omitempty"`
+}
+
+type AuthorizationRequirements struct {
+	CanSavePassword  *bool   `json:"canSavePassword,omitempty"`
+	OrganizationName *string `json:"organizationName,omitempty"`
+	OtpRequired      *bool   `json:"otpRequired,omitempty"`
+	OtpType          *string `json:"otpType,omitempty"`
+	PasswordRequired *bool   `json:"passwordRequired,omitempty"`
+	WorldName        *string `json:"worldName,omitempty"`
+}
+
+// What SSI needs to sign a user in, e.g. a password and a one time password
+func GetAuthorizationRequirements(c client.ClientInterface) (AuthorizationRequirements, error) {
+	req := AuthorizationRequirements{}
+	if err := c.Get("/access/authorizationrequirements", &req); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+// Sign in. The session id of the result authorizes further requests
+func Logon(c client.ClientInterface, req LogonRequest) (LogonResult, error) {
+	res := LogonResult{}
+	if err := c.Post("/access/logon", req, &res); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// End the session of the signed in user
+func Logoff(c client.ClientInterface) error {
+	return c.Post("/access/logoff", nil, nil)
+}
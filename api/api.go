@@ -0,0 +1,80 @@
+package api
+
+import (
+	"compass/client"
+	"compass/scope"
+	"net/url"
+)
+
+// Fetch every zone the signed in user can see
+func GetZones(c client.ClientInterface) ([]scope.ZoneData, error) {
+	zones := []scope.ZoneData{}
+	if err := c.Get("/zones", &zones); err != nil {
+		return zones, err
+	}
+	return zones, nil
+}
+
+// Create a new token from a token request
+func CreateToken(c client.ClientInterface, req scope.NewTokenRequest) (scope.TokenResult, error) {
+	tokenRes := scope.TokenResult{}
+	if err := c.Post("/tokens", req, &tokenRes); err != nil {
+		return tokenRes, err
+	}
+	return tokenRes, nil
+}
+
+// List the files and folders directly inside a folder in a zone. The root folder is "/"
+func ListFiles(c client.ClientInterface, zoneId scope.UUID, path string) ([]scope.FileEntry, error) {
+	files := []scope.FileEntry{}
+	endpoint := "/zones/" + zoneId.String() + "/files?path=" + url.QueryEscape(path)
+	if err := c.Get(endpoint, &files); err != nil {
+		return files, err
+	}
+	return files, nil
+}
+
+// List the members of a zone
+func GetZoneMembers(c client.ClientInterface, zoneId scope.UUID) ([]scope.ZoneMember, error) {
+	members := []scope.ZoneMember{}
+	if err := c.Get("/zones/"+zoneId.String()+"/members", &members); err != nil {
+		return members, err
+	}
+	return members, nil
+}
+
+// List the tokens of the signed in user
+func ListTokens(c client.ClientInterface) ([]scope.TokenInformation, error) {
+	tokens := []scope.TokenInformation{}
+	if err := c.Get("/tokens", &tokens); err != nil {
+		return tokens, err
+	}
+	return tokens, nil
+}
+
+// Revoke a token by its id
+func RevokeToken(c client.ClientInterface, id string) error {
+	return c.Delete("/tokens/"+url.PathEscape(id), nil)
+}
+
+// Fetch a token by its id
+func GetToken(c client.ClientInterface, id string) (scope.TokenInformation, error) {
+	info := scope.TokenInformation{}
+	if err := c.Get("/tokens/"+url.PathEscape(id), &info); err != nil {
+		return info, err
+	}
+	return info, nil
+}
+
+type introspectRequest struct {
+	Token string `json:"token"`
+}
+
+// Look up the token a raw token string belongs to
+func IntrospectToken(c client.ClientInterface, token string) (scope.TokenInformation, error) {
+	info := scope.TokenInformation{}
+	if err := c.Post("/tokens/introspect", introspectRequest{Token: token}, &info); err != nil {
+		return info, err
+	}
+	return info, nil
+}
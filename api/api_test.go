@@ -0,0 +1,210 @@
+package api
+
+import (
+	"compass/client"
+	"compass/scope"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestGetZones(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/zones" {
+			t.Errorf("expected path /zones, got %s", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode([]scope.ZoneData{{Name: "Finance"}, {Name: "HR"}})
+	}))
+	defer mockServer.Close()
+
+	zones, err := GetZones(client.NewClient(mockServer.URL))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(zones) != 2 || zones[0].Name != "Finance" {
+		t.Errorf("expected two zones starting with Finance, got %+v", zones)
+	}
+}
+
+func TestCreateToken(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/tokens" {
+			t.Errorf("expected POST /tokens, got %s %s", r.Method, r.URL.Path)
+		}
+		req := scope.NewTokenRequest{}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Scope.Name != "backup" {
+			t.Errorf("expected scope name 'backup', got '%s'", req.Scope.Name)
+		}
+		json.NewEncoder(w).Encode(scope.TokenResult{Token: "secret", Id: "1"})
+	}))
+	defer mockServer.Close()
+
+	res, err := CreateToken(client.NewClient(mockServer.URL), scope.NewTokenRequest{
+		Scope: scope.SPScope{Name: "backup"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if res.Token != "secret" {
+		t.Errorf("expected token 'secret', got '%s'", res.Token)
+	}
+}
+
+func TestCreateToken_Error(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer mockServer.Close()
+
+	if _, err := CreateToken(client.NewClient(mockServer.URL), scope.NewTokenRequest{}); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestListFiles(t *testing.T) {
+	zoneId := uuid.MustParse("7c9e6679-7425-40de-944b-e07fc1f90ae7")
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/zones/"+zoneId.String()+"/files" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("path") != "/Reports & Plans" {
+			t.Errorf("expected path query '/Reports & Plans', got '%s'", r.URL.Query().Get("path"))
+		}
+		json.NewEncoder(w).Encode([]scope.FileEntry{{Name: "2024", Path: "/Reports & Plans/2024", IsFolder: true}})
+	}))
+	defer mockServer.Close()
+
+	files, err := ListFiles(client.NewClient(mockServer.URL), zoneId, "/Reports & Plans")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(files) != 1 || !files[0].IsFolder {
+		t.Errorf("unexpected files %+v", files)
+	}
+}
+
+func TestGetZoneMembers(t *testing.T) {
+	zoneId := uuid.MustParse("7c9e6679-7425-40de-944b-e07fc1f90ae7")
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/zones/"+zoneId.String()+"/members" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode([]scope.ZoneMember{{MemberId: "1", DisplayName: "Ada", MemberType: "user"}})
+	}))
+	defer mockServer.Close()
+
+	members, err := GetZoneMembers(client.NewClient(mockServer.URL), zoneId)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(members) != 1 || members[0].DisplayName != "Ada" {
+		t.Errorf("unexpected members %+v", members)
+	}
+}
+
+func TestListTokens(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/tokens" {
+			t.Errorf("expected GET /tokens, got %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`[{"tokenName": "backup", "validUntil": "2024-06-01T12:00:00Z"}]`))
+	}))
+	defer mockServer.Close()
+
+	tokens, err := ListTokens(client.NewClient(mockServer.URL))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(tokens) != 1 || *tokens[0].TokenName != "backup" {
+		t.Errorf("unexpected tokens %+v", tokens)
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Path != "/tokens/abc" {
+			t.Errorf("expected DELETE /tokens/abc, got %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer mockServer.Close()
+
+	if err := RevokeToken(client.NewClient(mockServer.URL), "abc"); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestGetToken(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/tokens/abc" {
+			t.Errorf("expected GET /tokens/abc, got %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"tokenName": "backup"}`))
+	}))
+	defer mockServer.Close()
+
+	info, err := GetToken(client.NewClient(mockServer.URL), "abc")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if *info.TokenName != "backup" {
+		t.Errorf("unexpected token %+v", info)
+	}
+}
+
+func TestIntrospectToken(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/tokens/introspect" {
+			t.Errorf("expected POST /tokens/introspect, got %s %s", r.Method, r.URL.Path)
+		}
+		req := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["token"] != "secret" {
+			t.Errorf("expected token 'secret', got %+v", req)
+		}
+		w.Write([]byte(`{"tokenName": "backup"}`))
+	}))
+	defer mockServer.Close()
+
+	info, err := IntrospectToken(client.NewClient(mockServer.URL), "secret")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if *info.TokenName != "backup" {
+		t.Errorf("unexpected token %+v", info)
+	}
+}
+
+func TestLogon(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/access/authorizationrequirements":
+			w.Write([]byte(`{"otpRequired": false, "passwordRequired": true}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/access/logon":
+			req := LogonRequest{}
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Username == nil || *req.Username != "alice" {
+				t.Errorf("expected user alice, got %+v", req)
+			}
+			w.Write([]byte(`{"logonOK": true, "sessionId": "session"}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer mockServer.Close()
+
+	c := client.NewClient(mockServer.URL)
+	reqs, err := GetAuthorizationRequirements(c)
+	if err != nil || !*reqs.PasswordRequired || *reqs.OtpRequired {
+		t.Errorf("unexpected requirements %+v, %v", reqs, err)
+	}
+	user := "alice"
+	res, err := Logon(c, LogonRequest{Username: &user})
+	if err != nil || !res.LogonOK || *res.SessionId != "session" {
+		t.Errorf("unexpected result %+v, %v", res, err)
+	}
+}
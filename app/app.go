@@ -0,0 +1,49 @@
+package app
+
+import (
+	"compass/client"
+	"compass/config"
+	"compass/session"
+	"fmt"
+	"os"
+)
+
+// What the views of the TUI share: the settings in use, the client talking to SSI and the session it signs in with
+type Context struct {
+	Config  config.Config
+	Client  *client.Client
+	Session session.SessionInterface
+}
+
+// Set up a client and session for the host, TLS settings and session storage in cfg.
+// Requests carry the token of the session once there is one, and a 401 from SSI clears it
+func New(cfg config.Config) (*Context, error) {
+	options, err := cfg.ClientOptions()
+	if err != nil {
+		return nil, err
+	}
+	store, err := cfg.SessionStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := &Context{
+		Config:  cfg,
+		Session: session.New(session.WithStore(store)),
+	}
+	options = append([]func(*client.Client){client.WithHeader("Content-Type", "application/json")}, options...)
+	ctx.Client = client.NewClient(cfg.Host, append(options,
+		client.WithInterceptor(func(c *client.Client) {
+			if token := ctx.Session.GetToken(); token != "" {
+				c.SetHeader("Authorization", token)
+			}
+		}),
+		client.WithStatusHandler(401, func() {
+			// Not on stdout, which may be where tokens are written with -o -
+			fmt.Fprintln(os.Stderr, "Session was invalid. Next call will prompt a sign in")
+			defer ctx.Session.Save()
+			ctx.Session.SetToken("")
+		}),
+	)...)
+	return ctx, nil
+}
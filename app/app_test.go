@@ -0,0 +1,43 @@
+package app
+
+import (
+	"compass/config"
+	"compass/session"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	authorization := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = append(authorization, r.Header.Get("Authorization"))
+		if r.URL.Path == "/expired" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "session.json")
+	ctx, err := New(config.Config{Host: server.URL, Session: path})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	ctx.Client.Get("/", nil)
+	ctx.Session.SetToken("test-session")
+	ctx.Client.Get("/", nil)
+	if len(authorization) != 2 || authorization[0] != "" || authorization[1] != "test-session" {
+		t.Errorf("expected the token only once there is a session, got %v", authorization)
+	}
+
+	ctx.Client.Get("/expired", nil)
+	if ctx.Session.GetToken() != "" {
+		t.Errorf("expected a 401 to clear the session, got %s", ctx.Session.GetToken())
+	}
+	stored := &session.Session{Token: "unsaved"}
+	if err := session.FileStorage(path).Load(stored); err != nil || stored.Token != "" {
+		t.Errorf("expected the cleared session to be saved, got %+v, %v", stored, err)
+	}
+}
@@ -172,6 +172,18 @@ func New(name string, displayName string) Model {
 	}
 }
 
+// Check the options that are set in p
+func (m Model) SetPermission(p scope.Permission) Model {
+	m.All.SetChecked(p.All)
+	m.Access.SetChecked(p.Access)
+	m.Create.SetChecked(p.Create)
+	m.Delete.SetChecked(p.Delete)
+	m.Get.SetChecked(p.Get)
+	m.List.SetChecked(p.List)
+	m.Modify.SetChecked(p.Modify)
+	return m
+}
+
 type PermissionMessage struct {
 	Flag byte
 	Name string
@@ -0,0 +1,75 @@
+package scopediff
+
+import (
+	"compass/scope"
+	"encoding/json"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	addedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	removedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	changedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+)
+
+// Render a scope diff with one line per change. Zones found in zones are shown by name
+func Render(d scope.ScopeDiff, zones []scope.ZoneData) string {
+	if d.Empty() {
+		return "No changes"
+	}
+
+	doc := strings.Builder{}
+	for _, r := range scope.NameRules(d.Removed, zones) {
+		doc.WriteString(removedStyle.Render("- "+r.String()) + "\n")
+	}
+	for _, r := range scope.NameRules(d.Added, zones) {
+		doc.WriteString(addedStyle.Render("+ "+r.String()) + "\n")
+	}
+	for _, c := range d.Changed {
+		ref := scope.NameRules([]scope.Rule{{Kind: c.Kind, Ref: c.Ref, Permission: c.New}}, zones)[0]
+		line := strings.Builder{}
+		line.WriteString(changedStyle.Render("~ " + ref.String()))
+		for _, n := range c.Granted() {
+			line.WriteString(" " + addedStyle.Render("+"+n))
+		}
+		for _, n := range c.Revoked() {
+			line.WriteString(" " + removedStyle.Render("-"+n))
+		}
+		doc.WriteString(line.String() + "\n")
+	}
+	for _, c := range d.RemovedClients {
+		doc.WriteString(removedStyle.Render("- client:"+string(c)) + "\n")
+	}
+	for _, c := range d.AddedClients {
+		doc.WriteString(addedStyle.Render("+ client:"+string(c)) + "\n")
+	}
+	for _, c := range d.Limitations {
+		doc.WriteString(renderEntry("limitation", c) + "\n")
+	}
+	for _, c := range d.Extensions {
+		doc.WriteString(renderEntry("extension", c) + "\n")
+	}
+	return strings.TrimSuffix(doc.String(), "\n")
+}
+
+// A limitation or extension change, with its values as json
+func renderEntry(kind string, c scope.EntryChange) string {
+	ref := kind + ":" + c.Key
+	switch {
+	case c.New == nil:
+		return removedStyle.Render("- " + ref + " " + entryValue(c.Old))
+	case c.Old == nil:
+		return addedStyle.Render("+ " + ref + " " + entryValue(c.New))
+	}
+	return changedStyle.Render("~ "+ref) + " " + removedStyle.Render(entryValue(c.Old)) + " -> " + addedStyle.Render(entryValue(c.New))
+}
+
+func entryValue(v interface{}) string {
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return "?"
+	}
+	return string(jsonData)
+}
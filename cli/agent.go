@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"compass/agent"
+	"compass/client"
+	"compass/inventory"
+	"compass/scope"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+var agentCommand = Command{
+	Name:    "agent",
+	Usage:   "agent [-socket path] [-approve] [-max-validity 24h] [-clients list]",
+	Summary: "Serve scoped tokens to local tools over a Unix socket, like ssh-agent",
+	Run:     runAgent,
+}
+
+func runAgent(e *Env, args []string) error {
+	fs := flag.NewFlagSet("agent", flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	socket := fs.String("socket", "", "Where to listen, defaults to $"+agent.SOCKET_ENV+" or agent.sock in the runtime directory")
+	approve := fs.Bool("approve", false, "Ask for approval of every request in the TUI")
+	maxValidity := fs.String("max-validity", agent.DEFAULT_MAX_VALIDITY.String(), "The longest validity a tool can ask for, e.g. 1h or 1d")
+	clientList := fs.String("clients", e.Config.Clients, "Comma separated clients the tokens are issued for, defaults to the clients in the config or "+string(scope.ClientSSI))
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errUsage
+	}
+	clients := []scope.Client{scope.ClientSSI}
+	if *clientList != "" {
+		parsed, err := scope.ParseClients(*clientList)
+		if err != nil {
+			return err
+		}
+		if len(parsed) > 0 {
+			clients = parsed
+		}
+	}
+	if *approve && e.Approval == nil {
+		return fmt.Errorf("Approval needs the TUI")
+	}
+	limit, err := inventory.ParseDuration(*maxValidity)
+	if err != nil {
+		return err
+	}
+
+	c, err := e.sessionClient()
+	if err != nil {
+		return err
+	}
+
+	path := *socket
+	if path == "" {
+		if path, err = agent.DefaultSocketPath(); err != nil {
+			return err
+		}
+	}
+	key, err := agent.NewKey()
+	if err != nil {
+		return err
+	}
+	l, err := agent.Listen(path, key)
+	if err != nil {
+		return err
+	}
+	defer agent.Close(l)
+
+	var approver agent.Approver = agent.ApproverFunc(func(r agent.Request) bool {
+		fmt.Fprintf(e.Stderr, "Granted %q to %s for %s\n", r.Name, r.Requester, r.Scope)
+		return true
+	})
+	var wait func() error
+	if *approve {
+		approver, wait = e.Approval()
+	}
+
+	server := &http.Server{Handler: agent.New(c, agent.WithKey(key), agent.WithApprover(approver), agent.WithMaxValidity(limit), agent.WithClients(clients)).Handler()}
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(l)
+	}()
+	fmt.Fprintf(e.Stdout, "%s=%s; export %s;\n", agent.SOCKET_ENV, path, agent.SOCKET_ENV)
+
+	if wait == nil {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		wait = func() error {
+			select {
+			case <-ctx.Done():
+				return nil
+			case err := <-served:
+				return err
+			}
+		}
+	}
+	err = wait()
+	server.Close()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// A client that uses the session stored by the TUI, read again before every request so the
+// agent keeps working when the user signs in again
+func (e *Env) sessionClient() (*client.Client, error) {
+	s, err := e.signedIn()
+	if err != nil {
+		return nil, err
+	}
+	return e.newClient(
+		client.WithInterceptor(func(c *client.Client) {
+			// Rather no session than a stale one, the request then fails as unauthorized
+			if err := s.Load(); err != nil {
+				fmt.Fprintf(e.Stderr, "Could not read the session :: %+v\n", err)
+				c.RemoveHeader("Authorization")
+				return
+			}
+			c.SetHeader("Authorization", s.GetToken())
+		}),
+	)
+}
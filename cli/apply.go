@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"compass/api"
+	"compass/manifest"
+	"compass/scope"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var applyCommand = Command{
+	Name:    "apply",
+	Usage:   "apply [-o path] [-format json] [-y] <manifest>",
+	Summary: "Create every token described in a yaml or json manifest",
+	Run:     runApply,
+}
+
+func runApply(e *Env, args []string) error {
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	out := e.outputFlags(fs)
+	yes := fs.Bool("y", false, "Create the tokens without asking for confirmation")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+
+	w, status, err := e.tokenWriter(out)
+	if err != nil {
+		return err
+	}
+
+	m, err := manifest.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	c, err := e.Client()
+	if err != nil {
+		return err
+	}
+	zones, err := api.GetZones(c)
+	if err != nil {
+		return err
+	}
+	plans, err := m.Resolve(zones)
+	if err != nil {
+		return err
+	}
+	if len(plans) == 0 {
+		fmt.Fprintln(e.Stdout, "Nothing to do")
+		return nil
+	}
+
+	if len(plans) > 1 && !w.ToDir() && !w.ToStdout() {
+		return fmt.Errorf("Can't write %d tokens to the file %s, use a directory", len(plans), w.Path)
+	}
+	if len(plans) > 1 && w.Secret.Name != "" {
+		return fmt.Errorf("Can't name %d secrets %s, leave out -secret-name to name them after the tokens", len(plans), w.Secret.Name)
+	}
+
+	printPlan(status, plans)
+	if !*yes && !e.Confirm(fmt.Sprintf("Create %d token(s)?", len(plans))) {
+		return fmt.Errorf("Aborted")
+	}
+
+	failed := 0
+	for _, p := range plans {
+		res, err := api.CreateToken(c, p.Request)
+		if err != nil {
+			failed++
+			fmt.Fprintf(e.Stderr, "Could not create %s :: %+v\n", p.Token.Name, err)
+			continue
+		}
+		fileName, err := w.Write(p.Token.Name, res)
+		if err != nil {
+			failed++
+			fmt.Fprintf(e.Stderr, "Created %s (%s) but could not write it :: %+v\n", p.Token.Name, res.Id, err)
+			continue
+		}
+		e.record(p.Token.Name, res, fileName)
+		fmt.Fprintf(status, "Wrote %s to %s\n", p.Token.Name, fileName)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d token(s) failed", failed, len(plans))
+	}
+	return nil
+}
+
+func printPlan(out io.Writer, plans []manifest.Plan) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, p := range plans {
+		validity := time.Duration(*p.Request.Validity) * time.Second
+		fmt.Fprintf(w, "+ %s\t(valid for %s)\n", p.Token.Name, validity)
+		for _, z := range p.Zones {
+			perm := p.Request.Scope.Permissions[scope.ZoneKey(z.Id)]
+			fmt.Fprintf(w, "    %s\t%s\n", z.Name, strings.Join(scope.PermissionNames(perm), ", "))
+		}
+	}
+	w.Flush()
+}
@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"compass/scope"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testZoneId = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+
+func applyHandler(t *testing.T, created *[]scope.NewTokenRequest) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "test-session" {
+			t.Errorf("expected session token, got %q", r.Header.Get("Authorization"))
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/zones":
+			w.Write([]byte(`[{"name": "Finance", "id": "` + testZoneId + `"}]`))
+		case r.Method == http.MethodPost && r.URL.Path == "/tokens":
+			req := scope.NewTokenRequest{}
+			json.NewDecoder(r.Body).Decode(&req)
+			*created = append(*created, req)
+			json.NewEncoder(w).Encode(scope.TokenResult{Token: "secret-" + req.Scope.Name, Id: req.Scope.Name})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func writeManifest(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "manifest.yaml")
+	content := `
+tokens:
+  - name: backup
+    validity: 3600
+    zones:
+      - name: Finance
+        permissions: [get, list]
+  - name: audit log
+    validity: 60
+    zones:
+      - id: ` + testZoneId + `
+        permissions: [all]
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("unable to write manifest: %v", err)
+	}
+	return path
+}
+
+func TestApply(t *testing.T) {
+	created := []scope.NewTokenRequest{}
+	e, stdout, stderr := newTestEnv(t, applyHandler(t, &created), "y\n")
+	outDir := t.TempDir()
+
+	if code := Run(e, []string{"apply", "-o", outDir, writeManifest(t)}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if len(created) != 2 {
+		t.Fatalf("expected two tokens to be created, got %d", len(created))
+	}
+	if !strings.Contains(stdout.String(), "Finance") || !strings.Contains(stdout.String(), "get, list") {
+		t.Errorf("expected the plan to be printed, got %s", stdout.String())
+	}
+
+	data, err := os.ReadFile(filepath.Join(outDir, "token~audit_log.json"))
+	if err != nil {
+		t.Fatalf("expected token file, got %v", err)
+	}
+	res := scope.TokenResult{}
+	json.Unmarshal(data, &res)
+	if res.Token != "secret-audit log" {
+		t.Errorf("expected token to be written, got %+v", res)
+	}
+}
+
+func TestApply_Declined(t *testing.T) {
+	created := []scope.NewTokenRequest{}
+	e, _, _ := newTestEnv(t, applyHandler(t, &created), "n\n")
+
+	if code := Run(e, []string{"apply", "-o", t.TempDir(), writeManifest(t)}); code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if len(created) != 0 {
+		t.Errorf("expected no tokens to be created, got %d", len(created))
+	}
+}
+
+func TestApply_Usage(t *testing.T) {
+	e, _, _ := newTestEnv(t, http.NotFoundHandler(), "")
+	if code := Run(e, []string{"apply"}); code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+}
+
+func TestApply_Stdout(t *testing.T) {
+	created := []scope.NewTokenRequest{}
+	e, stdout, stderr := newTestEnv(t, applyHandler(t, &created), "")
+
+	if code := Run(e, []string{"apply", "-y", "-o", "-", "-format", "env", writeManifest(t)}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	expected := "COMPASS_TOKEN=secret-backup\n"
+	if !strings.HasPrefix(stdout.String(), expected) || strings.Contains(stdout.String(), "Finance") {
+		t.Errorf("expected only tokens on stdout, got %q", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "Wrote backup to stdout") {
+		t.Errorf("expected status on stderr, got %q", stderr.String())
+	}
+}
+
+func TestApply_SeveralTokensToOneFile(t *testing.T) {
+	created := []scope.NewTokenRequest{}
+	e, _, _ := newTestEnv(t, applyHandler(t, &created), "")
+
+	file := filepath.Join(t.TempDir(), "token.json")
+	if code := Run(e, []string{"apply", "-y", "-o", file, writeManifest(t)}); code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if len(created) != 0 {
+		t.Errorf("expected no tokens to be created, got %d", len(created))
+	}
+}
+
+func TestApply_SeveralTokensToOneSecret(t *testing.T) {
+	created := []scope.NewTokenRequest{}
+	e, _, _ := newTestEnv(t, applyHandler(t, &created), "")
+
+	if code := Run(e, []string{"apply", "-y", "-o", "-", "-format", "kubernetes", "-secret-name", "backup", writeManifest(t)}); code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if len(created) != 0 {
+		t.Errorf("expected no tokens to be created, got %d", len(created))
+	}
+}
@@ -0,0 +1,268 @@
+package cli
+
+import (
+	"bufio"
+	"compass/agent"
+	"compass/client"
+	"compass/config"
+	"compass/output"
+	"compass/registry"
+	"compass/scope"
+	"compass/session"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// A subcommand that runs outside of the TUI
+type Command struct {
+	Name    string
+	Usage   string
+	Summary string
+	Run     func(e *Env, args []string) error
+	// Subcommands, e.g. diff in compass scope diff. Run is not used when set
+	Commands []Command
+	// The command doesn't talk to SSI, so it works without a host
+	Offline bool
+}
+
+// Everything a command needs to talk to SSI and the user
+type Env struct {
+	Host   string
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// Where and how created tokens are written, unless a command is told otherwise
+	Output output.Writer
+	// Settings from config files, the environment and flags
+	Config config.Config
+	// The profile asked for with -profile, empty for the one in the config
+	Profile string
+	// Asks the user to approve agent requests in the TUI. Wait blocks until the user is done.
+	// Set by main, since commands don't draw the TUI
+	Approval func() (approver agent.Approver, wait func() error)
+}
+
+func NewEnv(host string) *Env {
+	return &Env{
+		Host:   host,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+}
+
+// Returned by a command when it was called with the wrong arguments
+var errUsage = errors.New("usage")
+
+// Returned when there is no session to use
+var errNotSignedIn = errors.New("Not signed in")
+
+// Exit code when there is no session, or SSI rejects it
+const EXIT_AUTH = 4
+
+// Returned by a command to exit with a code of its own, without printing an error
+type exitCode int
+
+func (c exitCode) Error() string {
+	return fmt.Sprintf("exit code %d", int(c))
+}
+
+func commands() []Command {
+	return []Command{
+		agentCommand,
+		applyCommand,
+		configCommand,
+		credentialCommand,
+		loginCommand,
+		logoutCommand,
+		execCommand,
+		scopeCommand,
+		tokenCommand,
+		whoamiCommand,
+		zonesCommand,
+	}
+}
+
+// Run the command named by the first argument. Returns the exit code for the process
+func Run(e *Env, args []string) int {
+	return run(e, commands(), "", args)
+}
+
+// Whether args name a command that needs no host or session, e.g. config set.
+// These still run when the config can't be loaded, so that it can be fixed with compass config
+func Offline(args []string) bool {
+	return offline(commands(), args)
+}
+
+func offline(cmds []Command, args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	for _, c := range cmds {
+		if c.Name != args[0] {
+			continue
+		}
+		if len(c.Commands) > 0 {
+			return offline(c.Commands, args[1:])
+		}
+		return c.Offline
+	}
+	return false
+}
+
+func run(e *Env, cmds []Command, prefix string, args []string) int {
+	if len(args) == 0 {
+		usage(e, cmds, prefix)
+		return 2
+	}
+	for _, c := range cmds {
+		if c.Name != args[0] {
+			continue
+		}
+		if len(c.Commands) > 0 {
+			return run(e, c.Commands, prefix+c.Name+" ", args[1:])
+		}
+		if e.Host == "" && !c.Offline {
+			fmt.Fprintln(e.Stderr, "No host provided, use -h or compass config set host <url>")
+			return 2
+		}
+		err := c.Run(e, args[1:])
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(e.Stderr, "Usage: compass %s%s\n", prefix, c.Usage)
+			return 2
+		}
+		var code exitCode
+		if errors.As(err, &code) {
+			return int(code)
+		}
+		if err != nil {
+			fmt.Fprintf(e.Stderr, "Error: %s\n", err)
+			if unauthorized(err) {
+				return EXIT_AUTH
+			}
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprintf(e.Stderr, "Unknown command %q\n", prefix+args[0])
+	usage(e, cmds, prefix)
+	return 2
+}
+
+func usage(e *Env, cmds []Command, prefix string) {
+	fmt.Fprintf(e.Stderr, "Usage: compass -h <host> %s[command]\n", prefix)
+	if prefix == "" {
+		fmt.Fprintln(e.Stderr, "\nWithout a command compass starts the interactive TUI.")
+	}
+	fmt.Fprintln(e.Stderr, "\nCommands:")
+	for _, c := range cmds {
+		fmt.Fprintf(e.Stderr, "  %-40s %s\n", prefix+c.Usage, c.Summary)
+	}
+}
+
+// Whether err means the user has to sign in. A 403 is a signed in user without access, which signing in again won't fix
+func unauthorized(err error) bool {
+	var status *client.StatusError
+	if errors.As(err, &status) {
+		return status.Code == 401
+	}
+	return errors.Is(err, errNotSignedIn)
+}
+
+// The stored session, whether or not it is signed in
+func (e *Env) session() (*session.Session, error) {
+	cfg := e.Config
+	cfg.Host = e.Host
+	store, err := cfg.SessionStorage()
+	if err != nil {
+		return nil, err
+	}
+	s := session.New(session.WithStore(store))
+	s.Load()
+	return s, nil
+}
+
+// The stored session. Fails with errNotSignedIn if there is none
+func (e *Env) signedIn() (*session.Session, error) {
+	s, err := e.session()
+	if err != nil {
+		return nil, err
+	}
+	if s.GetToken() == "" {
+		where := "-h " + e.Host
+		if e.Config.Profile != "" {
+			where = "-profile " + e.Config.Profile
+		}
+		return nil, fmt.Errorf("%w, run compass %s login or start the TUI to sign in", errNotSignedIn, where)
+	}
+	return s, nil
+}
+
+// Create a client that is authorized with the stored session
+func (e *Env) Client() (*client.Client, error) {
+	s, err := e.signedIn()
+	if err != nil {
+		return nil, err
+	}
+	return e.newClient(client.WithAuth(s.GetToken()))
+}
+
+// A client for the host with the configured timeout and TLS settings
+func (e *Env) newClient(options ...func(*client.Client)) (*client.Client, error) {
+	base, err := e.Config.ClientOptions()
+	if err != nil {
+		return nil, err
+	}
+	base = append([]func(*client.Client){client.WithHeader("Content-Type", "application/json")}, base...)
+	return client.NewClient(e.Host, append(base, options...)...), nil
+}
+
+// Ask the user a yes or no question on stdin. Anything but y or yes is a no
+func (e *Env) Confirm(question string) bool {
+	fmt.Fprintf(e.Stdout, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(e.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// Remember a created token in the local registry. Failing to do so is only a warning
+func (e *Env) record(name string, res scope.TokenResult, path string) {
+	if err := registry.Record(registry.NewEntry(name, res, path)); err != nil {
+		fmt.Fprintf(e.Stderr, "Warning: %s\n", err)
+	}
+}
+
+// Remove a revoked token from the local registry. Failing to do so is only a warning
+func (e *Env) forget(id string) {
+	if err := registry.Forget(id); err != nil {
+		fmt.Fprintf(e.Stderr, "Warning: %s\n", err)
+	}
+}
+
+// Add the flags for writing created tokens to fs, defaulting to e.Output
+func (e *Env) outputFlags(fs *flag.FlagSet) *output.Writer {
+	w := e.Output
+	if w.Format == "" {
+		w = output.New()
+	}
+	w.Flags(fs)
+	return &w
+}
+
+// A writer for created tokens. Status messages go to the returned writer, which is stderr when tokens go to stdout
+func (e *Env) tokenWriter(w *output.Writer) (output.Writer, io.Writer, error) {
+	if !output.ValidFormat(w.Format) {
+		return output.Writer{}, nil, fmt.Errorf("Unknown output format %s, use one of %s", w.Format, strings.Join(output.Formats(), ", "))
+	}
+	res := *w
+	res.Host = e.Host
+	res.Stdout = e.Stdout
+	if res.ToStdout() {
+		return res, e.Stderr, nil
+	}
+	return res, e.Stdout, nil
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"bytes"
+	"compass/session"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Set up an environment against a test server, signed in with a stored session
+func newTestEnv(t *testing.T, handler http.Handler, stdin string) (*Env, *bytes.Buffer, *bytes.Buffer) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	store, err := session.DefaultStorage()
+	if err != nil {
+		t.Fatalf("unable to create session storage: %v", err)
+	}
+	if err := store.Save(&session.Session{Token: "test-session", Host: server.URL}); err != nil {
+		t.Fatalf("unable to save session: %v", err)
+	}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	return &Env{
+		Host:   server.URL,
+		Stdin:  strings.NewReader(stdin),
+		Stdout: stdout,
+		Stderr: stderr,
+	}, stdout, stderr
+}
+
+func TestRun_UnknownCommand(t *testing.T) {
+	e, _, stderr := newTestEnv(t, http.NotFoundHandler(), "")
+	if code := Run(e, []string{"nope"}); code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "Unknown command") {
+		t.Errorf("expected unknown command message, got %s", stderr.String())
+	}
+}
+
+func TestEnv_Client_NotSignedIn(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	e := &Env{Host: "http://localhost"}
+	if _, err := e.Client(); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestEnv_Confirm(t *testing.T) {
+	tests := map[string]bool{"y\n": true, "YES\n": true, "n\n": false, "": false}
+	for input, expected := range tests {
+		e := &Env{Stdin: strings.NewReader(input), Stdout: &bytes.Buffer{}}
+		if got := e.Confirm("Sure?"); got != expected {
+			t.Errorf("expected %q to give %v, got %v", input, expected, got)
+		}
+	}
+}
+
+func TestEnv_SessionClient_LoadError(t *testing.T) {
+	auth := []string{}
+	e, _, stderr := newTestEnv(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		w.Write([]byte("[]"))
+	}), "")
+	c, err := e.sessionClient()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := c.Get("/zones", nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// Signing in to another host replaces the stored session, which must not be sent here
+	store, _ := session.DefaultStorage()
+	store.Save(&session.Session{Token: "other-session", Host: "http://elsewhere"})
+	c.Get("/zones", nil)
+
+	if len(auth) != 2 || auth[0] != "test-session" || auth[1] != "" {
+		t.Errorf("expected the session and then none, got %q", auth)
+	}
+	if !strings.Contains(stderr.String(), "Could not read the session") {
+		t.Errorf("expected a warning, got %s", stderr.String())
+	}
+}
@@ -0,0 +1,240 @@
+package cli
+
+import (
+	"compass/config"
+	"compass/global"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+)
+
+var configCommand = Command{
+	Name:    "config",
+	Usage:   "config <command>",
+	Summary: "Read and change settings in config files",
+	Commands: []Command{
+		{
+			Name:    "get",
+			Usage:   "get <key>",
+			Summary: "Print the value of a setting. Exits with 1 if it isn't set",
+			Run:     runConfigGet,
+			Offline: true,
+		},
+		{
+			Name:    "set",
+			Usage:   "set [-system|-project] [-profile name] <key> <value>",
+			Summary: "Change a setting in the user config, or remove it with an empty value",
+			Run:     runConfigSet,
+			Offline: true,
+		},
+		{
+			Name:    "profiles",
+			Usage:   "profiles [-output text|json]",
+			Summary: "List the profiles and the host of each",
+			Run:     runConfigProfiles,
+			Offline: true,
+		},
+		{
+			Name:    "list",
+			Usage:   "list [-output text|json]",
+			Summary: "List every setting and where its value comes from",
+			Run:     runConfigList,
+			Offline: true,
+		},
+	},
+}
+
+func runConfigGet(e *Env, args []string) error {
+	fs := flag.NewFlagSet("get", flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+	_, c, _ := e.configLayers()
+	value, err := c.Get(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		return exitCode(1)
+	}
+	fmt.Fprintln(e.Stdout, value)
+	return nil
+}
+
+func runConfigSet(e *Env, args []string) error {
+	fs := flag.NewFlagSet("set", flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	system := fs.Bool("system", false, "Change the system config, "+config.SystemPath)
+	project := fs.Bool("project", false, "Change the closest "+global.CONFIG_FILE+", or create one in the working directory")
+	profile := fs.String("profile", "", "Change the setting in this profile, creating it if needed")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 2 || (*system && *project) {
+		return errUsage
+	}
+	key, value := fs.Arg(0), fs.Arg(1)
+	if *profile != "" && key == config.KEY_PROFILE {
+		return fmt.Errorf("Profiles can't pick a profile of their own")
+	}
+	if *project && value != "" && config.UserOnly(key) {
+		return fmt.Errorf("%s can only be set in the user or system config", key)
+	}
+
+	path, err := config.UserPath()
+	if err != nil {
+		return err
+	}
+	if *system {
+		path = config.SystemPath
+	}
+	if *project {
+		if path = config.ProjectPath(); path == "" {
+			path = global.CONFIG_FILE
+		}
+	}
+
+	c, err := config.LoadFile(path)
+	if err != nil {
+		return err
+	}
+	target := &c
+	where := path
+	if *profile != "" {
+		p := c.Profiles[*profile]
+		target = &p
+		where = fmt.Sprintf("profile %s in %s", *profile, path)
+	}
+	if err := target.Set(key, value); err != nil {
+		return err
+	}
+	if *profile != "" {
+		if c.Profiles == nil {
+			c.Profiles = map[string]config.Config{}
+		}
+		c.Profiles[*profile] = *target
+	}
+	if err := c.Save(path); err != nil {
+		return err
+	}
+	if value == "" {
+		fmt.Fprintf(e.Stdout, "Removed %s from %s\n", key, where)
+	} else {
+		fmt.Fprintf(e.Stdout, "Set %s to %s in %s\n", key, value, where)
+	}
+	return nil
+}
+
+// Every layer and the config they resolve to with the active profile. The config commands are how a broken
+// config gets fixed, so files that can't be read and an unknown profile are only warned about
+func (e *Env) configLayers() ([]config.Layer, config.Config, map[string]string) {
+	layers, err := config.Layers()
+	if err != nil {
+		fmt.Fprintf(e.Stderr, "Warning: %s\n", err)
+	}
+	for _, l := range layers {
+		for _, w := range l.Warnings {
+			fmt.Fprintf(e.Stderr, "Warning: %s\n", w)
+		}
+	}
+	c, sources, err := config.Resolve(layers, e.Profile)
+	if err != nil {
+		fmt.Fprintf(e.Stderr, "Warning: %s\n", err)
+	}
+	return layers, c, sources
+}
+
+// A setting as printed by compass config list
+type setting struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Source      string `json:"source,omitempty"`
+	Env         string `json:"env"`
+	Description string `json:"description"`
+}
+
+func runConfigList(e *Env, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	output := outputFlag(fs)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 || !validOutput(*output) {
+		return errUsage
+	}
+	layers, c, sources := e.configLayers()
+
+	settings := []setting{}
+	for _, key := range config.Keys() {
+		value, _ := c.Get(key)
+		settings = append(settings, setting{
+			Key:         key,
+			Value:       value,
+			Source:      sources[key],
+			Env:         config.Env(key),
+			Description: config.Describe(key),
+		})
+	}
+	if *output == "json" {
+		return printJSON(e, settings)
+	}
+
+	w := tabwriter.NewWriter(e.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tFROM\tDESCRIPTION")
+	for _, s := range settings {
+		value, source := s.Value, s.Source
+		if value == "" {
+			value, source = "-", "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Key, value, source, s.Description)
+	}
+	w.Flush()
+
+	if c.Profile != "" {
+		fmt.Fprintf(e.Stdout, "\nProfile: %s\n", c.Profile)
+	}
+	fmt.Fprintln(e.Stdout, "\nRead from:")
+	for _, l := range layers {
+		if l.Path != "" {
+			fmt.Fprintf(e.Stdout, "  %-8s %s\n", l.Name, l.Path)
+		}
+	}
+	return nil
+}
+
+// A profile as printed by compass config profiles
+type profile struct {
+	Name   string `json:"name"`
+	Host   string `json:"host,omitempty"`
+	Active bool   `json:"active"`
+}
+
+func runConfigProfiles(e *Env, args []string) error {
+	fs := flag.NewFlagSet("profiles", flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	output := outputFlag(fs)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 || !validOutput(*output) {
+		return errUsage
+	}
+	_, c, _ := e.configLayers()
+	active := c.Profile
+
+	profiles := []profile{}
+	for _, name := range c.ProfileNames() {
+		profiles = append(profiles, profile{Name: name, Host: c.Profiles[name].Host, Active: name == active})
+	}
+	if *output == "json" {
+		return printJSON(e, profiles)
+	}
+	if len(profiles) == 0 {
+		fmt.Fprintln(e.Stdout, "No profiles, add one with compass config set -profile <name> host <url>")
+		return nil
+	}
+	w := tabwriter.NewWriter(e.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "\tNAME\tHOST")
+	for _, p := range profiles {
+		mark := ""
+		if p.Active {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", mark, p.Name, p.Host)
+	}
+	w.Flush()
+	return nil
+}
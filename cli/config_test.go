@@ -0,0 +1,216 @@
+package cli
+
+import (
+	"bytes"
+	"compass/config"
+	"compass/global"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// An environment without a host, reading config from temporary directories only
+func newConfigEnv(t *testing.T) (*Env, *bytes.Buffer, *bytes.Buffer) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("HOME", dir)
+	for _, key := range config.Keys() {
+		t.Setenv(config.Env(key), "")
+	}
+	old := config.SystemPath
+	config.SystemPath = filepath.Join(dir, "etc", "config.yaml")
+	t.Cleanup(func() { config.SystemPath = old })
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	return &Env{Stdin: strings.NewReader(""), Stdout: stdout, Stderr: stderr}, stdout, stderr
+}
+
+func TestConfig_SetGetList(t *testing.T) {
+	e, stdout, stderr := newConfigEnv(t)
+	if code := Run(e, []string{"config", "set", "host", "https://ssi.example.com"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if code := Run(e, []string{"config", "set", "-project", "validity", "7d"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+
+	stdout.Reset()
+	if code := Run(e, []string{"config", "get", "host"}); code != 0 || stdout.String() != "https://ssi.example.com\n" {
+		t.Errorf("expected the host, got %d: %q", code, stdout.String())
+	}
+
+	t.Setenv("COMPASS_THEME", "plain")
+	stdout.Reset()
+	if code := Run(e, []string{"config", "list", "-output", "json"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	settings := []setting{}
+	if err := json.Unmarshal(stdout.Bytes(), &settings); err != nil {
+		t.Fatalf("unable to parse output %s: %v", stdout.String(), err)
+	}
+	expected := map[string]string{
+		"host":     "https://ssi.example.com user",
+		"validity": "7d project",
+		"theme":    "plain env",
+		"clients":  " ",
+	}
+	for _, s := range settings {
+		if e, ok := expected[s.Key]; ok && s.Value+" "+s.Source != e {
+			t.Errorf("%s: expected %q, got %q", s.Key, e, s.Value+" "+s.Source)
+		}
+	}
+}
+
+func TestConfig_Get_Unset(t *testing.T) {
+	e, stdout, _ := newConfigEnv(t)
+	if code := Run(e, []string{"config", "get", "theme"}); code != 1 || stdout.Len() != 0 {
+		t.Errorf("expected exit code 1 and no output, got %d: %q", code, stdout.String())
+	}
+}
+
+func TestConfig_Set_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"unknown key", []string{"config", "set", "color", "red"}},
+		{"invalid value", []string{"config", "set", "output.format", "yaml"}},
+		{"two targets", []string{"config", "set", "-system", "-project", "theme", "dark"}},
+		{"host in a project", []string{"config", "set", "-project", "host", "https://ssi.example.com"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e, _, _ := newConfigEnv(t)
+			if code := Run(e, test.args); code == 0 {
+				t.Errorf("expected a failing exit code, got %d", code)
+			}
+		})
+	}
+}
+
+func TestRun_NoHost(t *testing.T) {
+	e, _, stderr := newConfigEnv(t)
+	if code := Run(e, []string{"zones", "list"}); code != 2 || !strings.Contains(stderr.String(), "No host provided") {
+		t.Errorf("expected exit code 2 and a hint, got %d: %s", code, stderr.String())
+	}
+}
+
+func TestRun_Offline(t *testing.T) {
+	tests := [][]string{
+		{"token", "expiring"},
+		{"token", "decrypt"},
+		{"scope", "diff"},
+		{"scope", "union"},
+		{"scope", "intersect"},
+		{"scope", "subtract"},
+	}
+	for _, args := range tests {
+		e, _, stderr := newConfigEnv(t)
+		Run(e, args)
+		if strings.Contains(stderr.String(), "No host provided") {
+			t.Errorf("%v: expected to work without a host, got %s", args, stderr.String())
+		}
+	}
+}
+
+func TestConfig_Profiles(t *testing.T) {
+	e, stdout, stderr := newConfigEnv(t)
+	for _, args := range [][]string{
+		{"config", "set", "-profile", "prod", "host", "https://prod.example.com"},
+		{"config", "set", "-profile", "staging", "host", "https://staging.example.com"},
+		{"config", "set", "profile", "staging"},
+	} {
+		if code := Run(e, args); code != 0 {
+			t.Fatalf("%v: expected exit code 0, got %d: %s", args, code, stderr.String())
+		}
+	}
+	if code := Run(e, []string{"config", "set", "-profile", "prod", "profile", "staging"}); code == 0 {
+		t.Error("expected a profile not to pick a profile")
+	}
+
+	stdout.Reset()
+	if code := Run(e, []string{"config", "get", "host"}); code != 0 || stdout.String() != "https://staging.example.com\n" {
+		t.Errorf("expected the host of the default profile, got %d: %q", code, stdout.String())
+	}
+	e.Profile = "prod"
+	stdout.Reset()
+	if code := Run(e, []string{"config", "get", "host"}); code != 0 || stdout.String() != "https://prod.example.com\n" {
+		t.Errorf("expected the host of the profile asked for, got %d: %q", code, stdout.String())
+	}
+
+	stdout.Reset()
+	if code := Run(e, []string{"config", "profiles", "-output", "json"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	profiles := []profile{}
+	json.Unmarshal(stdout.Bytes(), &profiles)
+	expected := []profile{
+		{Name: "prod", Host: "https://prod.example.com", Active: true},
+		{Name: "staging", Host: "https://staging.example.com"},
+	}
+	if len(profiles) != len(expected) || profiles[0] != expected[0] || profiles[1] != expected[1] {
+		t.Errorf("expected %+v, got %+v", expected, profiles)
+	}
+}
+
+func TestConfig_BrokenLayers(t *testing.T) {
+	e, stdout, stderr := newConfigEnv(t)
+	os.WriteFile(global.CONFIG_FILE, []byte("output:\n  format: yaml\n"), 0600)
+	t.Setenv(config.Env(config.KEY_VALIDITY), "soon")
+	e.Profile = "prod"
+
+	if _, _, err := config.Load(e.Profile); err == nil {
+		t.Fatal("expected the config not to load")
+	}
+	if !Offline([]string{"config", "set"}) || Offline([]string{"zones", "list"}) || Offline([]string{"config"}) {
+		t.Error("expected only config set to be offline")
+	}
+
+	if code := Run(e, []string{"config", "set", "host", "https://ssi.example.com"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	stdout.Reset()
+	stderr.Reset()
+	if code := Run(e, []string{"config", "get", "host"}); code != 0 || stdout.String() != "https://ssi.example.com\n" {
+		t.Errorf("expected the host, got %d: %q", code, stdout.String())
+	}
+	for _, expected := range []string{"output.format", "COMPASS_VALIDITY", "Unknown profile prod"} {
+		if !strings.Contains(stderr.String(), expected) {
+			t.Errorf("expected a warning about %s, got %s", expected, stderr.String())
+		}
+	}
+	if code := Run(e, []string{"config", "list"}); code != 0 || !strings.Contains(stdout.String(), "https://ssi.example.com") {
+		t.Errorf("expected the settings that could be read, got %d: %s", code, stdout.String())
+	}
+}
+
+func TestConfig_Set_NewProfileFromEnv(t *testing.T) {
+	e, stdout, stderr := newConfigEnv(t)
+	t.Setenv(config.Env(config.KEY_PROFILE), "prod")
+	if code := Run(e, []string{"config", "set", "-profile", "prod", "host", "https://prod.example.com"}); code != 0 || stderr.Len() != 0 {
+		t.Fatalf("expected exit code 0 and no warnings, got %d: %s", code, stderr.String())
+	}
+	stdout.Reset()
+	if code := Run(e, []string{"config", "get", "host"}); code != 0 || stdout.String() != "https://prod.example.com\n" {
+		t.Errorf("expected the host of the new profile, got %d: %q", code, stdout.String())
+	}
+}
+
+func TestConfig_List_ProjectUserOnly(t *testing.T) {
+	e, stdout, stderr := newConfigEnv(t)
+	os.WriteFile(global.CONFIG_FILE, []byte("host: https://evil\ntheme: dark\n"), 0600)
+	if code := Run(e, []string{"config", "list"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if strings.Contains(stdout.String(), "https://evil") || !strings.Contains(stdout.String(), "dark") {
+		t.Errorf("expected the host to be ignored, got %s", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "host can only be set in the user or system config") {
+		t.Errorf("expected a warning, got %s", stderr.String())
+	}
+}
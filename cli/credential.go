@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"compass/api"
+	"compass/credential"
+	"compass/inventory"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var credentialCommand = Command{
+	Name:    "credential",
+	Usage:   "credential [-config path] get|store|erase",
+	Summary: "Hand scoped tokens to git as a credential helper",
+	Run:     runCredential,
+}
+
+// How long tokens for git are valid, unless the host config says otherwise
+const DEFAULT_CREDENTIAL_VALIDITY = time.Hour
+
+// Cached tokens that expire sooner than this are replaced, so git doesn't get a token that expires mid clone
+const credentialMargin = time.Minute
+
+func runCredential(e *Env, args []string) error {
+	fs := flag.NewFlagSet("credential", flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	configPath := fs.String("config", "", "Which git hosts get tokens, defaults to git-credentials.yaml in the config directory")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	attrs, err := credential.Parse(e.Stdin)
+	if err != nil {
+		return err
+	}
+	if *configPath == "" {
+		if *configPath, err = credential.DefaultConfigPath(); err != nil {
+			return err
+		}
+	}
+	config, err := credential.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	host, ok := config.Find(attrs["host"])
+	if !ok {
+		// Not a host compass knows, so git asks the next helper
+		return nil
+	}
+	cachePath, err := credential.DefaultCachePath()
+	if err != nil {
+		return err
+	}
+	cache, err := credential.LoadCache(cachePath)
+	if err != nil {
+		return err
+	}
+
+	hostEnv := *e
+	if host.SSI != "" {
+		hostEnv.Host = host.SSI
+	}
+
+	switch fs.Arg(0) {
+	case "get":
+		// A token must never be sent in the clear
+		if attrs["protocol"] != "https" {
+			return fmt.Errorf("Refusing credentials for %s over %q, only https is allowed", attrs["host"], attrs["protocol"])
+		}
+		return credentialGet(&hostEnv, host, cache)
+	case "erase":
+		return credentialErase(&hostEnv, host, cache, attrs)
+	}
+	// Tokens are cached when they are minted, so there is nothing to store.
+	// Git expects other operations to be ignored
+	return nil
+}
+
+func credentialGet(e *Env, host credential.Host, cache *credential.Cache) error {
+	entry, ok := cache.Get(host.Host, time.Now(), credentialMargin)
+	if !ok {
+		var err error
+		if entry, err = mintCredential(e, host); err != nil {
+			return err
+		}
+		cache.Put(host.Host, entry)
+		if err := cache.Save(); err != nil {
+			fmt.Fprintf(e.Stderr, "Warning: %s\n", err)
+		}
+	}
+
+	answer := credential.Attributes{
+		"username": entry.Username,
+		"password": entry.Token,
+	}
+	if !entry.Expires.IsZero() {
+		answer["password_expiry_utc"] = strconv.FormatInt(entry.Expires.Unix(), 10)
+	}
+	return answer.Write(e.Stdout)
+}
+
+func mintCredential(e *Env, host credential.Host) (credential.Entry, error) {
+	entry := credential.Entry{}
+	validity := DEFAULT_CREDENTIAL_VALIDITY
+	if host.Validity != "" {
+		var err error
+		if validity, err = inventory.ParseDuration(host.Validity); err != nil {
+			return entry, err
+		}
+	}
+	manifest := host.Manifest
+	if manifest == "" {
+		manifest = "compass.yaml"
+	}
+
+	c, err := e.Client()
+	if err != nil {
+		return entry, err
+	}
+	zones, err := api.GetZones(c)
+	if err != nil {
+		return entry, err
+	}
+	req, err := tokenRequest(manifest, host.Template, host.Scope, zones)
+	if err != nil {
+		return entry, err
+	}
+	name := "git " + host.Host
+	seconds := int64(validity / time.Second)
+	req.Scope.Name = name
+	req.TokenName = &name
+	req.Validity = &seconds
+
+	res, err := api.CreateToken(c, req)
+	if err != nil {
+		return entry, fmt.Errorf("Could not create a token for %s :: %+v", host.Host, err)
+	}
+	entry = credential.Entry{
+		Username: host.Username,
+		Token:    res.Token,
+		Id:       res.Id,
+	}
+	if entry.Username == "" {
+		entry.Username = res.Id
+	}
+	if expires, ok := inventory.ParseTime(res.ExpiresAt); ok {
+		entry.Expires = expires
+	}
+	return entry, nil
+}
+
+// Git rejected the token, so forget and revoke it
+func credentialErase(e *Env, host credential.Host, cache *credential.Cache, attrs credential.Attributes) error {
+	entry, ok := cache.Remove(host.Host)
+	if !ok {
+		return nil
+	}
+	if password, given := attrs["password"]; given && password != entry.Token {
+		// Not the token compass handed out
+		return nil
+	}
+	if err := cache.Save(); err != nil {
+		return err
+	}
+
+	c, err := e.Client()
+	if err != nil {
+		return err
+	}
+	if err := api.RevokeToken(c, entry.Id); err != nil {
+		fmt.Fprintf(e.Stderr, "Warning: could not revoke %s :: %+v\n", entry.Id, err)
+	}
+	return nil
+}
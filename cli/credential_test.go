@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"compass/scope"
+	"strings"
+	"testing"
+)
+
+const credentialConfig = `
+hosts:
+  - host: git.example.com
+    scope: "zone:Finance get,list"
+    validity: 2h
+    username: git
+`
+
+func TestCredential_Get(t *testing.T) {
+	created, revoked := []scope.NewTokenRequest{}, []string{}
+	e, stdout, stderr := newTestEnv(t, execHandler(&created, &revoked), "protocol=https\nhost=git.example.com\npath=team/repo.git\n\n")
+	config := writeFile(t, "git-credentials.yaml", credentialConfig)
+
+	if code := Run(e, []string{"credential", "-config", config, "get"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	expected := "username=git\npassword=short-lived\npassword_expiry_utc=1893456000\n"
+	if stdout.String() != expected {
+		t.Errorf("expected %q, got %q", expected, stdout.String())
+	}
+	if len(created) != 1 || *created[0].Validity != 7200 || *created[0].TokenName != "git git.example.com" {
+		t.Errorf("unexpected token requests %+v", created)
+	}
+
+	// The second time the cached token is used
+	stdout.Reset()
+	e.Stdin = strings.NewReader("protocol=https\nhost=git.example.com\n\n")
+	if code := Run(e, []string{"credential", "-config", config, "get"}); code != 0 || stdout.String() != expected {
+		t.Errorf("expected the cached token, got %d: %q", code, stdout.String())
+	}
+	if len(created) != 1 {
+		t.Errorf("expected no new token, got %d", len(created))
+	}
+}
+
+func TestCredential_UnknownHost(t *testing.T) {
+	created, revoked := []scope.NewTokenRequest{}, []string{}
+	e, stdout, _ := newTestEnv(t, execHandler(&created, &revoked), "protocol=https\nhost=github.com\n\n")
+	config := writeFile(t, "git-credentials.yaml", credentialConfig)
+
+	if code := Run(e, []string{"credential", "-config", config, "get"}); code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+	if stdout.Len() != 0 || len(created) != 0 {
+		t.Errorf("expected no answer for an unknown host, got %q", stdout.String())
+	}
+}
+
+func TestCredential_NotHttps(t *testing.T) {
+	created, revoked := []scope.NewTokenRequest{}, []string{}
+	e, stdout, _ := newTestEnv(t, execHandler(&created, &revoked), "protocol=http\nhost=git.example.com\n\n")
+	config := writeFile(t, "git-credentials.yaml", credentialConfig)
+
+	if code := Run(e, []string{"credential", "-config", config, "get"}); code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if stdout.Len() != 0 || len(created) != 0 {
+		t.Errorf("expected no token over http, got %q", stdout.String())
+	}
+}
+
+func TestCredential_Erase(t *testing.T) {
+	created, revoked := []scope.NewTokenRequest{}, []string{}
+	e, _, stderr := newTestEnv(t, execHandler(&created, &revoked), "protocol=https\nhost=git.example.com\n\n")
+	config := writeFile(t, "git-credentials.yaml", credentialConfig)
+	if code := Run(e, []string{"credential", "-config", config, "get"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+
+	// Someone else's password is left alone
+	e.Stdin = strings.NewReader("host=git.example.com\nusername=git\npassword=other\n\n")
+	Run(e, []string{"credential", "-config", config, "erase"})
+	if len(revoked) != 0 {
+		t.Errorf("expected no revocation, got %v", revoked)
+	}
+
+	e.Stdin = strings.NewReader("host=git.example.com\nusername=git\npassword=short-lived\n\n")
+	if code := Run(e, []string{"credential", "-config", config, "erase"}); code != 0 {
+		t.Errorf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if len(revoked) != 1 || revoked[0] != "55555555-5555-5555-5555-555555555555" {
+		t.Errorf("expected the token to be revoked, got %v", revoked)
+	}
+
+	// After erasing, a new token is minted
+	e.Stdin = strings.NewReader("protocol=https\nhost=git.example.com\n\n")
+	Run(e, []string{"credential", "-config", config, "get"})
+	if len(created) != 2 {
+		t.Errorf("expected a new token, got %d", len(created))
+	}
+}
+
+func TestCredential_StoreAndUsage(t *testing.T) {
+	created, revoked := []scope.NewTokenRequest{}, []string{}
+	e, stdout, _ := newTestEnv(t, execHandler(&created, &revoked), "host=git.example.com\nusername=git\npassword=secret\n\n")
+	config := writeFile(t, "git-credentials.yaml", credentialConfig)
+
+	if code := Run(e, []string{"credential", "-config", config, "store"}); code != 0 || stdout.Len() != 0 || len(created) != 0 {
+		t.Errorf("expected store to do nothing, got %d: %q", code, stdout.String())
+	}
+	if code := Run(e, []string{"credential", "-config", config}); code != 2 {
+		t.Errorf("expected exit code 2 without an operation, got %d", code)
+	}
+}
@@ -0,0 +1,186 @@
+package cli
+
+import (
+	"compass/api"
+	"compass/inventory"
+	"compass/manifest"
+	"compass/scope"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+var execCommand = Command{
+	Name:    "exec",
+	Usage:   "exec [-f compass.yaml] [-template name | -scope text] [-ttl 15m] [-env COMPASS_TOKEN] -- <command> [args...]",
+	Summary: "Run a command with a short-lived token that is revoked when it exits",
+	Run:     runExec,
+}
+
+// How long a token for compass exec is valid, unless told otherwise
+const DEFAULT_EXEC_TTL = 15 * time.Minute
+
+// Signals that are passed on to the command instead of stopping compass, so the token is always revoked
+var forwardedSignals = []os.Signal{syscall.SIGTERM, syscall.SIGHUP}
+
+// Signals the terminal already sends to the command, which shares the process group of compass.
+// They are caught so compass outlives the command, but not passed on again
+var terminalSignals = []os.Signal{os.Interrupt, syscall.SIGQUIT}
+
+func runExec(e *Env, args []string) error {
+	fs := flag.NewFlagSet("exec", flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	file := fs.String("f", "compass.yaml", "The manifest templates are read from")
+	template := fs.String("template", "", "Use the scope of the token with this name in the manifest")
+	scopeText := fs.String("scope", "", "Use a scope in text form instead of a template, e.g. \"zone:Finance get,list\"")
+	ttl := fs.String("ttl", DEFAULT_EXEC_TTL.String(), "How long the token is valid, e.g. 15m or 1d")
+	envName := fs.String("env", "COMPASS_TOKEN", "The environment variable the token is passed in")
+	if err := fs.Parse(args); err != nil || fs.NArg() == 0 {
+		return errUsage
+	}
+	if (*template == "") == (*scopeText == "") {
+		return fmt.Errorf("Use either -template or -scope")
+	}
+	validity, err := inventory.ParseDuration(*ttl)
+	if err != nil {
+		return err
+	}
+	if validity < time.Second {
+		return fmt.Errorf("The ttl must be at least a second")
+	}
+
+	c, err := e.Client()
+	if err != nil {
+		return err
+	}
+	zones, err := api.GetZones(c)
+	if err != nil {
+		return err
+	}
+	req, err := tokenRequest(*file, *template, *scopeText, zones)
+	if err != nil {
+		return err
+	}
+	seconds := int64(validity / time.Second)
+	req.Validity = &seconds
+
+	res, err := api.CreateToken(c, req)
+	if err != nil {
+		return fmt.Errorf("Could not create a token :: %+v", err)
+	}
+	fmt.Fprintf(e.Stderr, "Created %s (%s), valid for %s\n", *req.TokenName, res.Id, validity)
+
+	runErr := runChild(e, fs.Args(), *envName+"="+res.Token)
+	revokeErr := api.RevokeToken(c, res.Id)
+	if revokeErr != nil {
+		fmt.Fprintf(e.Stderr, "Could not revoke %s, it expires at %s :: %+v\n", res.Id, res.ExpiresAt, revokeErr)
+	} else {
+		fmt.Fprintf(e.Stderr, "Revoked %s\n", res.Id)
+	}
+
+	if runErr != nil {
+		return runErr
+	}
+	if revokeErr != nil {
+		return exitCode(1)
+	}
+	return nil
+}
+
+// The request for a token, from a template in the manifest or a scope in text form.
+// The validity is only set when the template has one
+func tokenRequest(file string, template string, text string, zones []scope.ZoneData) (scope.NewTokenRequest, error) {
+	if text != "" {
+		perms, err := scope.ParseScope(text, zones)
+		if err != nil {
+			return scope.NewTokenRequest{}, err
+		}
+		name := "compass exec"
+		return scope.NewTokenRequest{
+			Scope: scope.SPScope{
+				Name:        name,
+				Clients:     []scope.Client{scope.ClientSSI},
+				Permissions: perms,
+			},
+			TokenName: &name,
+		}, nil
+	}
+
+	m, err := manifest.Load(file)
+	if err != nil {
+		return scope.NewTokenRequest{}, err
+	}
+	token, err := m.Find(template)
+	if err != nil {
+		return scope.NewTokenRequest{}, err
+	}
+	// The validity can be given separately, so templates don't need one
+	hasValidity := token.Validity > 0
+	token.Validity = max(token.Validity, 1)
+	plan, err := token.Resolve(zones)
+	if err != nil {
+		return scope.NewTokenRequest{}, fmt.Errorf("Invalid template %s :: %+v", template, err)
+	}
+	if !hasValidity {
+		plan.Request.Validity = nil
+	}
+	return plan.Request, nil
+}
+
+// Run a command with an extra environment variable, passing on signals. Returns the exit code of the command
+// as an exitCode when it fails
+func runChild(e *Env, args []string, env string) error {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = e.Stdin
+	cmd.Stdout = e.Stdout
+	cmd.Stderr = e.Stderr
+	cmd.Env = append(os.Environ(), env)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+	// Caught rather than ignored, as the command would inherit ignored signals
+	caught := make(chan os.Signal, 1)
+	signal.Notify(caught, terminalSignals...)
+	defer signal.Stop(caught)
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("Could not run %s :: %+v", args[0], err)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case s := <-signals:
+				cmd.Process.Signal(s)
+			case <-caught:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitCode(childExitCode(exitErr))
+	}
+	if err != nil {
+		return fmt.Errorf("Could not run %s :: %+v", args[0], err)
+	}
+	return nil
+}
+
+// The exit code of a command, 128 + the signal when it was killed like a shell reports it
+func childExitCode(err *exec.ExitError) int {
+	if status, ok := err.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return err.ExitCode()
+}
@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"compass/scope"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Not a real test. Run by the exec tests as the command, it prints the token and exits with the code in its argument
+func TestExecHelperProcess(t *testing.T) {
+	if os.Getenv("COMPASS_EXEC_HELPER") != "1" {
+		return
+	}
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+	fmt.Printf("token=%s\n", os.Getenv("SYNC_TOKEN"))
+	code, _ := strconv.Atoi(args[1])
+	os.Exit(code)
+}
+
+func helperCommand(code int) []string {
+	return []string{os.Args[0], "-test.run=TestExecHelperProcess", "--", strconv.Itoa(code)}
+}
+
+func execHandler(created *[]scope.NewTokenRequest, revoked *[]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/zones":
+			w.Write([]byte(`[{"name": "Finance", "id": "` + testZoneId + `"}]`))
+		case r.Method == http.MethodPost && r.URL.Path == "/tokens":
+			req := scope.NewTokenRequest{}
+			json.NewDecoder(r.Body).Decode(&req)
+			*created = append(*created, req)
+			w.Write([]byte(`{"token": "short-lived", "expiresAt": "2030-01-01T00:00:00Z", "id": "55555555-5555-5555-5555-555555555555"}`))
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/tokens/"):
+			*revoked = append(*revoked, strings.TrimPrefix(r.URL.Path, "/tokens/"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestExec_Template(t *testing.T) {
+	t.Setenv("COMPASS_EXEC_HELPER", "1")
+	created, revoked := []scope.NewTokenRequest{}, []string{}
+	e, stdout, stderr := newTestEnv(t, execHandler(&created, &revoked), "")
+
+	args := append([]string{"exec", "-f", writeManifest(t), "-template", "backup", "-ttl", "10m", "-env", "SYNC_TOKEN", "--"}, helperCommand(7)...)
+	if code := Run(e, args); code != 7 {
+		t.Errorf("expected the exit code of the command, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "token=short-lived") {
+		t.Errorf("expected the token in the environment, got %q", stdout.String())
+	}
+	if len(created) != 1 || *created[0].Validity != 600 || created[0].Scope.Name != "backup" {
+		t.Errorf("unexpected token requests %+v", created)
+	}
+	if len(revoked) != 1 || revoked[0] != "55555555-5555-5555-5555-555555555555" {
+		t.Errorf("expected the token to be revoked, got %v", revoked)
+	}
+}
+
+func TestExec_Scope(t *testing.T) {
+	t.Setenv("COMPASS_EXEC_HELPER", "1")
+	created, revoked := []scope.NewTokenRequest{}, []string{}
+	e, _, stderr := newTestEnv(t, execHandler(&created, &revoked), "")
+
+	args := append([]string{"exec", "-scope", "zone:Finance get", "--"}, helperCommand(0)...)
+	if code := Run(e, args); code != 0 {
+		t.Errorf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if len(created) != 1 || *created[0].Validity != 900 || !created[0].Scope.Permissions["Zone="+testZoneId].Get {
+		t.Errorf("unexpected token requests %+v", created)
+	}
+	if len(revoked) != 1 {
+		t.Errorf("expected the token to be revoked, got %v", revoked)
+	}
+}
+
+func TestExec_Errors(t *testing.T) {
+	tests := [][]string{
+		{"exec"},
+		{"exec", "-ttl", "10m", "--", "true"},
+		{"exec", "-template", "backup", "-scope", "zone:Finance get", "--", "true"},
+	}
+	for _, args := range tests {
+		created, revoked := []scope.NewTokenRequest{}, []string{}
+		e, _, _ := newTestEnv(t, execHandler(&created, &revoked), "")
+		if code := Run(e, args); code == 0 {
+			t.Errorf("%v: expected a failure", args)
+		}
+		if len(created) != 0 {
+			t.Errorf("%v: expected no token, got %+v", args, created)
+		}
+	}
+}
+
+func TestExec_CommandNotFound(t *testing.T) {
+	created, revoked := []scope.NewTokenRequest{}, []string{}
+	e, _, stderr := newTestEnv(t, execHandler(&created, &revoked), "")
+	if code := Run(e, []string{"exec", "-scope", "zone:Finance get", "--", "./does-not-exist"}); code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if len(revoked) != 1 {
+		t.Errorf("expected the token to be revoked, got %v: %s", revoked, stderr.String())
+	}
+}
@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"bytes"
+	"compass/api"
+	"compass/bubbles/scopediff"
+	"compass/scope"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+var scopeCommand = Command{
+	Name:    "scope",
+	Usage:   "scope <command>",
+	Summary: "Work with scopes stored in files",
+	Commands: []Command{
+		{
+			Name:    "diff",
+			Usage:   "diff <old> <new>",
+			Summary: "Show how the new scope differs from the old one",
+			Run:     runScopeDiff,
+			Offline: true,
+		},
+		{
+			Name:    "union",
+			Usage:   "union [-output text|json] <a> <b>...",
+			Summary: "Print everything that any of the scopes allow",
+			Run:     scopeSetOperation(scope.SPScope.Union),
+			Offline: true,
+		},
+		{
+			Name:    "intersect",
+			Usage:   "intersect [-output text|json] <a> <b>...",
+			Summary: "Print what all of the scopes allow",
+			Run:     scopeSetOperation(scope.SPScope.Intersect),
+			Offline: true,
+		},
+		{
+			Name:    "subtract",
+			Usage:   "subtract [-output text|json] <a> <b>...",
+			Summary: "Print what the first scope allows that none of the others do",
+			Run:     scopeSetOperation(scope.SPScope.Subtract),
+			Offline: true,
+		},
+	},
+}
+
+func scopeSetOperation(op func(scope.SPScope, scope.SPScope) scope.SPScope) func(*Env, []string) error {
+	return func(e *Env, args []string) error {
+		fs := flag.NewFlagSet("scope", flag.ContinueOnError)
+		fs.SetOutput(e.Stderr)
+		output := fs.String("output", "text", "Output format, text or json")
+		if err := fs.Parse(args); err != nil || fs.NArg() < 2 {
+			return errUsage
+		}
+		if *output != "text" && *output != "json" {
+			return errUsage
+		}
+
+		zones := e.zones()
+		res := scope.SPScope{}
+		for i, path := range fs.Args() {
+			s, err := readScopeFile(path, zones)
+			if err != nil {
+				return err
+			}
+			if i == 0 {
+				res = s
+				continue
+			}
+			res = op(res, s)
+		}
+
+		if *output == "json" {
+			return printJSON(e, res)
+		}
+		fmt.Fprintln(e.Stdout, scope.FormatScope(res.Permissions, zones))
+		return nil
+	}
+}
+
+func runScopeDiff(e *Env, args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 2 {
+		return errUsage
+	}
+
+	zones := e.zones()
+	a, err := readScopeFile(fs.Arg(0), zones)
+	if err != nil {
+		return err
+	}
+	b, err := readScopeFile(fs.Arg(1), zones)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(e.Stdout, scopediff.Render(scope.Diff(a, b), zones))
+	return nil
+}
+
+func printJSON(e *Env, v any) error {
+	jsonData, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(e.Stdout, string(jsonData))
+	return nil
+}
+
+// Read a scope from a file. The file can hold an SPScope as json, json with the scope
+// in a "scope" field like token information and token requests, or the scope text form
+func readScopeFile(path string, zones []scope.ZoneData) (scope.SPScope, error) {
+	s := scope.SPScope{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		perms, err := scope.ParseScope(string(data), zones)
+		if err != nil {
+			return s, fmt.Errorf("%s :: %+v", path, err)
+		}
+		s.Permissions = perms
+		return s, nil
+	}
+
+	wrapper := struct {
+		Scope *scope.SPScope `json:"scope"`
+	}{}
+	if err := json.Unmarshal(data, &wrapper); err != nil {
+		return s, fmt.Errorf("%s :: %+v", path, err)
+	}
+	if wrapper.Scope != nil {
+		return *wrapper.Scope, nil
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("%s :: %+v", path, err)
+	}
+	return s, nil
+}
+
+// Fetch the zones if signed in, so scopes can be shown with zone names.
+// Returns no zones when that isn't possible
+func (e *Env) zones() []scope.ZoneData {
+	if e.Host == "" {
+		return nil
+	}
+	c, err := e.Client()
+	if err != nil {
+		return nil
+	}
+	zones, err := api.GetZones(c)
+	if err != nil {
+		return nil
+	}
+	return zones
+}
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("unable to write %s: %v", name, err)
+	}
+	return path
+}
+
+func zonesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/zones" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`[{"name": "Finance", "id": "` + testZoneId + `"}]`))
+	})
+}
+
+func TestScopeDiff(t *testing.T) {
+	e, stdout, stderr := newTestEnv(t, zonesHandler(), "")
+	old := writeFile(t, "old.json", `{"clients": ["SynkzoneSSI"], "permissions": {"Zone=`+testZoneId+`": {"get": true, "delete": true}}}`)
+	new := writeFile(t, "new.json", `{"id": "1", "scope": {"clients": ["SynkzoneSSI"], "permissions": {"Zone=`+testZoneId+`": {"get": true, "list": true}}}}`)
+
+	if code := Run(e, []string{"scope", "diff", old, new}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	expected := "~ zone:Finance get,list +list -delete"
+	if !strings.Contains(stdout.String(), expected) {
+		t.Errorf("expected %q, got %q", expected, stdout.String())
+	}
+}
+
+func TestScopeDiff_Limitations(t *testing.T) {
+	e, stdout, stderr := newTestEnv(t, zonesHandler(), "")
+	old := writeFile(t, "old.json", `{"clients": ["SynkzoneSSI"], "limitations": {"maxUses": {"uses": 3}}}`)
+	new := writeFile(t, "new.json", `{"clients": ["SynkzoneSSI"], "extensions": {"audit": true}}`)
+
+	if code := Run(e, []string{"scope", "diff", old, new}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	for _, expected := range []string{`- limitation:maxUses {"uses":3}`, "+ extension:audit true"} {
+		if !strings.Contains(stdout.String(), expected) {
+			t.Errorf("expected %q, got %q", expected, stdout.String())
+		}
+	}
+}
+
+func TestScopeDiff_TextForm(t *testing.T) {
+	e, stdout, stderr := newTestEnv(t, zonesHandler(), "")
+	old := writeFile(t, "old.scope", "zone:Finance all")
+	new := writeFile(t, "new.scope", "")
+
+	if code := Run(e, []string{"scope", "diff", old, new}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "- zone:Finance all") {
+		t.Errorf("expected Finance to be removed, got %q", stdout.String())
+	}
+}
+
+func TestScopeDiff_Usage(t *testing.T) {
+	e, _, _ := newTestEnv(t, zonesHandler(), "")
+	if code := Run(e, []string{"scope", "diff", "one"}); code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+	if code := Run(e, []string{"scope"}); code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+}
+
+func TestScopeSetOperations(t *testing.T) {
+	a := writeFile(t, "a.scope", "zone:Finance get,list,modify")
+	b := writeFile(t, "b.scope", "zone:Finance list")
+
+	tests := []struct {
+		command  string
+		expected string
+	}{
+		{"union", "zone:Finance get,list,modify"},
+		{"intersect", "zone:Finance list"},
+		{"subtract", "zone:Finance get,modify"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			e, stdout, stderr := newTestEnv(t, zonesHandler(), "")
+			if code := Run(e, []string{"scope", tt.command, a, b}); code != 0 {
+				t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+			}
+			if strings.TrimSpace(stdout.String()) != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestScopeSetOperations_Json(t *testing.T) {
+	e, stdout, stderr := newTestEnv(t, zonesHandler(), "")
+	a := writeFile(t, "a.scope", "zone:Finance all")
+	b := writeFile(t, "b.scope", "zone:Finance get")
+
+	if code := Run(e, []string{"scope", "subtract", "-output", "json", a, b}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), `"Zone=`+testZoneId+`"`) || strings.Contains(stdout.String(), `"get"`) {
+		t.Errorf("unexpected json %s", stdout.String())
+	}
+	if code := Run(e, []string{"scope", "union", "-output", "yaml", a, b}); code != 2 {
+		t.Errorf("expected exit code 2 for unknown output, got %d", code)
+	}
+}
@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"bufio"
+	"compass/api"
+	"compass/client"
+	"compass/config"
+	"compass/inventory"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// The environment variable login reads the password from, unless it is given on stdin
+const PASSWORD_ENV = "COMPASS_PASSWORD"
+
+var loginCommand = Command{
+	Name:    "login",
+	Usage:   "login -user name [-password-stdin] [-otp code] [-timeout 15m] [-output text|json]",
+	Summary: "Sign in without the TUI, with the password from stdin or $" + PASSWORD_ENV,
+	Run:     runLogin,
+}
+
+var logoutCommand = Command{
+	Name:    "logout",
+	Usage:   "logout [-output text|json]",
+	Summary: "End the stored session",
+	Run:     runLogout,
+}
+
+var whoamiCommand = Command{
+	Name:    "whoami",
+	Usage:   "whoami [-output text|json]",
+	Summary: "Show who is signed in. Exits with 4 if nobody is",
+	Run:     runWhoami,
+}
+
+// What login, logout and whoami print with -output json
+type sessionStatus struct {
+	Host     string `json:"host"`
+	User     string `json:"user,omitempty"`
+	SignedIn bool   `json:"signedIn"`
+}
+
+func (e *Env) printSession(output string, status sessionStatus, text string) error {
+	if output == "json" {
+		return printJSON(e, status)
+	}
+	fmt.Fprintln(e.Stdout, text)
+	return nil
+}
+
+func outputFlag(fs *flag.FlagSet) *string {
+	return fs.String("output", "text", "Output format, text or json")
+}
+
+func validOutput(output string) bool {
+	return output == "text" || output == "json"
+}
+
+func runLogin(e *Env, args []string) error {
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	user := fs.String("user", "", "The user to sign in as")
+	passwordStdin := fs.Bool("password-stdin", false, "Read the password from the first line of stdin")
+	otp := fs.String("otp", "", "A one time password, if SSI asks for one")
+	timeout := fs.String("timeout", e.Config.SessionTimeoutOr(config.DEFAULT_SESSION_TIMEOUT).String(), "How long the session lasts without being used")
+	output := outputFlag(fs)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 || *user == "" || !validOutput(*output) {
+		return errUsage
+	}
+	d, err := inventory.ParseDuration(*timeout)
+	if err != nil {
+		return err
+	}
+
+	password := os.Getenv(PASSWORD_ENV)
+	if *passwordStdin {
+		line, err := bufio.NewReader(e.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("Could not read the password from stdin :: %+v", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	c, err := e.newClient()
+	if err != nil {
+		return err
+	}
+	reqs, err := api.GetAuthorizationRequirements(c)
+	if err != nil {
+		return err
+	}
+	req := api.LogonRequest{Username: user}
+	if reqs.PasswordRequired != nil && *reqs.PasswordRequired {
+		if password == "" {
+			return fmt.Errorf("SSI wants a password, pass it on stdin with -password-stdin or in $%s", PASSWORD_ENV)
+		}
+		chars := strings.Split(password, "")
+		req.Password = &chars
+	}
+	if reqs.OtpRequired != nil && *reqs.OtpRequired {
+		if *otp == "" {
+			return fmt.Errorf("SSI wants a one time password, pass it with -otp")
+		}
+		req.VerificationCode = otp
+	}
+	seconds := int64(d / time.Second)
+	req.TimeoutInSeconds = &seconds
+
+	res, err := api.Logon(c, req)
+	if err != nil {
+		return fmt.Errorf("%w :: %+v", errNotSignedIn, err)
+	}
+	if !res.LogonOK || res.SessionId == nil {
+		return fmt.Errorf("%w, SSI did not accept the credentials", errNotSignedIn)
+	}
+
+	s, err := e.session()
+	if err != nil {
+		return err
+	}
+	s.SetToken(*res.SessionId)
+	s.SetUser(*user)
+	if err := s.Save(); err != nil {
+		return err
+	}
+	status := sessionStatus{Host: e.Host, User: *user, SignedIn: true}
+	return e.printSession(*output, status, fmt.Sprintf("Signed in as %s on %s", *user, e.Host))
+}
+
+func runLogout(e *Env, args []string) error {
+	fs := flag.NewFlagSet("logout", flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	output := outputFlag(fs)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 || !validOutput(*output) {
+		return errUsage
+	}
+
+	s, err := e.session()
+	if err != nil {
+		return err
+	}
+	status := sessionStatus{Host: e.Host}
+	if s.GetToken() == "" {
+		return e.printSession(*output, status, "Not signed in")
+	}
+
+	c, err := e.newClient(client.WithAuth(s.GetToken()))
+	if err != nil {
+		return err
+	}
+	if err := api.Logoff(c); err != nil {
+		fmt.Fprintf(e.Stderr, "Warning: could not end the session in SSI :: %+v\n", err)
+	}
+	user := s.GetUser()
+	s.SetToken("")
+	s.SetUser("")
+	if err := s.Save(); err != nil {
+		return err
+	}
+	status.User = user
+	return e.printSession(*output, status, "Signed out")
+}
+
+func runWhoami(e *Env, args []string) error {
+	fs := flag.NewFlagSet("whoami", flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	output := outputFlag(fs)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 || !validOutput(*output) {
+		return errUsage
+	}
+
+	s, err := e.session()
+	if err != nil {
+		return err
+	}
+	status := sessionStatus{Host: e.Host, User: s.GetUser()}
+	if s.GetToken() != "" {
+		// The stored session may have run out, so ask SSI
+		c, err := e.Client()
+		if err != nil {
+			return err
+		}
+		_, err = api.GetZones(c)
+		if err != nil && !unauthorized(err) {
+			return err
+		}
+		status.SignedIn = err == nil
+	}
+
+	if !status.SignedIn {
+		if err := e.printSession(*output, status, "Not signed in"); err != nil {
+			return err
+		}
+		return exitCode(EXIT_AUTH)
+	}
+	user := status.User
+	if user == "" {
+		user = "an unknown user"
+	}
+	return e.printSession(*output, status, fmt.Sprintf("Signed in as %s on %s", user, e.Host))
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"compass/session"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// A fake SSI that signs in alice with the password secret, and accepts the session it hands out
+func sessionHandler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/access/authorizationrequirements":
+			w.Write([]byte(`{"otpRequired": false, "passwordRequired": true}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/access/logon":
+			req := struct {
+				Username string   `json:"username"`
+				Password []string `json:"password"`
+			}{}
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Username != "alice" || strings.Join(req.Password, "") != "secret" {
+				w.Write([]byte(`{"logonOK": false}`))
+				return
+			}
+			w.Write([]byte(`{"logonOK": true, "sessionId": "new-session"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/access/logoff":
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet && r.URL.Path == "/zones":
+			if r.Header.Get("Authorization") != "new-session" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`[{"name": "Finance", "id": "` + testZoneId + `"}, {"name": "HR", "id": "4f1c2a8e-3b7d-4e5f-9a6b-1c2d3e4f5a6b"}]`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func storedSession(t *testing.T) *session.Session {
+	store, err := session.DefaultStorage()
+	if err != nil {
+		t.Fatalf("unable to open session storage: %v", err)
+	}
+	s := session.New(session.WithStore(store))
+	s.Load()
+	return s
+}
+
+func TestLogin(t *testing.T) {
+	e, stdout, stderr := newTestEnv(t, sessionHandler(t), "secret\n")
+	if code := Run(e, []string{"login", "-user", "alice", "-password-stdin", "-output", "json"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	status := sessionStatus{}
+	if err := json.Unmarshal(stdout.Bytes(), &status); err != nil || !status.SignedIn || status.User != "alice" {
+		t.Errorf("unexpected output %s, %v", stdout.String(), err)
+	}
+	if s := storedSession(t); s.GetToken() != "new-session" || s.GetUser() != "alice" {
+		t.Errorf("expected the session to be stored, got %+v", s)
+	}
+}
+
+func TestLogin_Rejected(t *testing.T) {
+	t.Setenv(PASSWORD_ENV, "wrong")
+	e, _, _ := newTestEnv(t, sessionHandler(t), "")
+	if code := Run(e, []string{"login", "-user", "alice"}); code != EXIT_AUTH {
+		t.Errorf("expected exit code %d, got %d", EXIT_AUTH, code)
+	}
+	if s := storedSession(t); s.GetToken() != "test-session" {
+		t.Errorf("expected the old session to be kept, got %+v", s)
+	}
+	if code := Run(e, []string{"login"}); code != 2 {
+		t.Errorf("expected exit code 2 without a user, got %d", code)
+	}
+}
+
+func TestWhoami(t *testing.T) {
+	e, stdout, _ := newTestEnv(t, sessionHandler(t), "secret\n")
+
+	// The session from newTestEnv is not accepted by this SSI
+	if code := Run(e, []string{"whoami"}); code != EXIT_AUTH || !strings.Contains(stdout.String(), "Not signed in") {
+		t.Errorf("expected exit code %d, got %d: %s", EXIT_AUTH, code, stdout.String())
+	}
+
+	Run(e, []string{"login", "-user", "alice", "-password-stdin"})
+	stdout.Reset()
+	if code := Run(e, []string{"whoami"}); code != 0 || !strings.Contains(stdout.String(), "Signed in as alice") {
+		t.Errorf("expected alice to be signed in, got %d: %s", code, stdout.String())
+	}
+
+	stdout.Reset()
+	if code := Run(e, []string{"logout"}); code != 0 || !strings.Contains(stdout.String(), "Signed out") {
+		t.Errorf("expected to sign out, got %d: %s", code, stdout.String())
+	}
+	if s := storedSession(t); s.GetToken() != "" || s.GetUser() != "" {
+		t.Errorf("expected the session to be cleared, got %+v", s)
+	}
+	stdout.Reset()
+	if code := Run(e, []string{"whoami", "-output", "json"}); code != EXIT_AUTH || !strings.Contains(stdout.String(), `"signedIn": false`) {
+		t.Errorf("expected exit code %d, got %d: %s", EXIT_AUTH, code, stdout.String())
+	}
+}
+
+func TestZonesList(t *testing.T) {
+	e, stdout, stderr := newTestEnv(t, sessionHandler(t), "secret\n")
+	if code := Run(e, []string{"zones", "list"}); code != EXIT_AUTH {
+		t.Errorf("expected exit code %d for a rejected session, got %d: %s", EXIT_AUTH, code, stderr.String())
+	}
+
+	Run(e, []string{"login", "-user", "alice", "-password-stdin"})
+	stdout.Reset()
+	if code := Run(e, []string{"zones", "list", "-filter", "fin", "-output", "json"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	zones := []map[string]string{}
+	if err := json.Unmarshal(stdout.Bytes(), &zones); err != nil || len(zones) != 1 || zones[0]["name"] != "Finance" {
+		t.Errorf("expected Finance, got %s, %v", stdout.String(), err)
+	}
+}
+
+func TestLogin_ProfileSession(t *testing.T) {
+	e, _, stderr := newTestEnv(t, sessionHandler(t), "secret\n")
+	e.Config.Profile = "prod"
+	if code := Run(e, []string{"login", "-user", "alice", "-password-stdin"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if s := storedSession(t); s.GetToken() != "test-session" {
+		t.Errorf("expected the default session to be left alone, got %s", s.GetToken())
+	}
+	s, err := e.session()
+	if err != nil || s.GetToken() != "new-session" || s.GetUser() != "alice" {
+		t.Errorf("expected the profile to have its own session, got %+v, %v", s, err)
+	}
+}
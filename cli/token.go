@@ -0,0 +1,680 @@
+package cli
+
+import (
+	"compass/api"
+	"compass/client"
+	"compass/crypt"
+	"compass/fsutil"
+	"compass/inventory"
+	"compass/output"
+	"compass/registry"
+	"compass/scope"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var tokenCommand = Command{
+	Name:    "token",
+	Usage:   "token <command>",
+	Summary: "Manage existing tokens",
+	Commands: []Command{
+		{
+			Name:    "list",
+			Usage:   "list [-sort name|created|expires] [-reverse] [-filter text] [-soon 7d] [-output text|json]",
+			Summary: "List your tokens",
+			Run:     runTokenList,
+		},
+		{
+			Name:    "create",
+			Usage:   "create [-name name] [-scope text|-] [-template name] [-f compass.yaml] [-validity 30d] [-description text] [-clients list] [-o path] [-format json] [-output text|json]",
+			Summary: "Create a token from a scope in text form or a template in a manifest",
+			Run:     runTokenCreate,
+		},
+		{
+			Name:    "revoke",
+			Usage:   "revoke [-output text|json] <id|name>... | revoke -due",
+			Summary: "Revoke tokens by id or name, or the ones rotated with -revoke-after once their grace period is over",
+			Run:     runTokenRevoke,
+		},
+		{
+			Name:    "rotate",
+			Usage:   "rotate [-validity 30d] [-o path] [-format json] [-revoke-after 1h] <id|name>",
+			Summary: "Create a new token with the same scope as an existing one",
+			Run:     runTokenRotate,
+		},
+		{
+			Name:    "inspect",
+			Usage:   "inspect [-output text|json] <token file|->",
+			Summary: "Show what a token file or raw token gives access to",
+			Run:     runTokenInspect,
+		},
+		{
+			Name:    "expiring",
+			Usage:   "expiring [-within 7d] [-output text|json]",
+			Summary: "List tokens created with compass that expire soon. Exits with 3 if there are any",
+			Run:     runTokenExpiring,
+			Offline: true,
+		},
+		{
+			Name:    "decrypt",
+			Usage:   "decrypt [-i identity-file] [-o file] <encrypted file|->",
+			Summary: "Decrypt a token file, with an age identity or the passphrase in $" + crypt.PASSPHRASE_ENV,
+			Run:     runTokenDecrypt,
+			Offline: true,
+		},
+	},
+}
+
+func runTokenList(e *Env, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	sortKey := fs.String("sort", string(inventory.SORT_NAME), "Sort by name, created or expires")
+	reverse := fs.Bool("reverse", false, "Reverse the sort order")
+	filter := fs.String("filter", "", "Only show tokens where the name, id, client or scope contains this text")
+	soon := fs.String("soon", "7d", "Tokens expiring within this duration are marked as expiring")
+	output := fs.String("output", "text", "Output format, text or json")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errUsage
+	}
+	if !validSortKey(*sortKey) || (*output != "text" && *output != "json") {
+		return errUsage
+	}
+	soonDuration, err := inventory.ParseDuration(*soon)
+	if err != nil {
+		return err
+	}
+
+	c, err := e.Client()
+	if err != nil {
+		return err
+	}
+	tokens, err := api.ListTokens(c)
+	if err != nil {
+		return err
+	}
+	zones, err := api.GetZones(c)
+	if err != nil {
+		return err
+	}
+
+	tokens = inventory.Filter(tokens, *filter, zones)
+	inventory.Sort(tokens, inventory.SortKey(*sortKey), *reverse)
+
+	if *output == "json" {
+		return printJSON(e, tokens)
+	}
+	printTokens(e, tokens, zones, soonDuration)
+	return nil
+}
+
+func validSortKey(key string) bool {
+	for _, k := range inventory.SortKeys {
+		if string(k) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func printTokens(e *Env, tokens []scope.TokenInformation, zones []scope.ZoneData, soon time.Duration) {
+	now := time.Now()
+	w := tabwriter.NewWriter(e.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tID\tCREATED\tVALID UNTIL\tSTATUS\tCLIENT\tSCOPE")
+	for _, t := range tokens {
+		client := "-"
+		if t.ClientIdentifier != nil {
+			client = *t.ClientIdentifier
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			inventory.Name(t),
+			inventory.Id(t),
+			inventory.FormatTime(inventory.Created(t)),
+			inventory.FormatTime(inventory.Expires(t)),
+			inventory.StatusOf(t, now, soon),
+			client,
+			inventory.Summary(t.Scope, zones),
+		)
+	}
+	w.Flush()
+}
+
+// What compass token create prints with -output json
+type created struct {
+	Name      string `json:"name"`
+	Id        string `json:"id"`
+	ExpiresAt string `json:"expiresAt"`
+	Path      string `json:"path"`
+}
+
+func runTokenCreate(e *Env, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	name := fs.String("name", "", "The name of the token, defaults to the template name")
+	scopeText := fs.String("scope", "", "A scope in text form, e.g. \"zone:Finance get,list\", or - to read it from stdin")
+	template := fs.String("template", "", "Use the token with this name in the manifest as a template")
+	file := fs.String("f", "compass.yaml", "The manifest templates are read from")
+	validity := fs.String("validity", "", "How long the token is valid, defaults to the template or the validity setting")
+	description := fs.String("description", "", "A description of the token")
+	clients := fs.String("clients", "", "Comma separated clients the token is issued for, defaults to the template or "+string(scope.ClientSSI))
+	out := e.outputFlags(fs)
+	output := outputFlag(fs)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 || !validOutput(*output) {
+		return errUsage
+	}
+	if (*template == "") == (*scopeText == "") {
+		return fmt.Errorf("Use either -template or -scope")
+	}
+	if *name == "" {
+		if *name = *template; *name == "" {
+			return fmt.Errorf("A token needs a -name")
+		}
+	}
+
+	w, status, err := e.tokenWriter(out)
+	if err != nil {
+		return err
+	}
+	if w.ToStdout() && *output == "json" {
+		return fmt.Errorf("Can't write both the token and json to stdout, use -o")
+	}
+	if *scopeText == "-" {
+		data, err := io.ReadAll(e.Stdin)
+		if err != nil {
+			return fmt.Errorf("Could not read the scope from stdin :: %+v", err)
+		}
+		*scopeText = string(data)
+	}
+
+	c, err := e.Client()
+	if err != nil {
+		return err
+	}
+	zones, err := api.GetZones(c)
+	if err != nil {
+		return err
+	}
+	req, err := tokenRequest(*file, *template, *scopeText, zones)
+	if err != nil {
+		return err
+	}
+
+	req.Scope.Name = *name
+	req.TokenName = name
+	if *description != "" {
+		req.Scope.Description = *description
+	}
+	if *clients != "" {
+		if req.Scope.Clients, err = scope.ParseClients(*clients); err != nil {
+			return err
+		}
+		if err := req.Scope.ValidateClients(); err != nil {
+			return err
+		}
+	}
+	if *validity != "" || req.Validity == nil {
+		d := e.Config.ValidityOr(inventory.DefaultRotateValidity)
+		if *validity != "" {
+			if d, err = inventory.ParseDuration(*validity); err != nil {
+				return err
+			}
+		}
+		seconds := int64(d / time.Second)
+		req.Validity = &seconds
+	}
+
+	res, err := api.CreateToken(c, req)
+	if err != nil {
+		return fmt.Errorf("Could not create %s :: %w", *name, err)
+	}
+	fileName, err := w.Write(*name, res)
+	if err != nil {
+		return fmt.Errorf("Created %s (%s) but could not write it :: %+v", *name, res.Id, err)
+	}
+	e.record(*name, res, fileName)
+
+	if *output == "json" {
+		return printJSON(e, created{Name: *name, Id: res.Id, ExpiresAt: res.ExpiresAt, Path: fileName})
+	}
+	fmt.Fprintf(status, "Created %s (%s), wrote it to %s\n", *name, res.Id, fileName)
+	return nil
+}
+
+// What compass token revoke prints with -output json, one per token
+type revoked struct {
+	Ref     string `json:"ref"`
+	Id      string `json:"id,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Revoked bool   `json:"revoked"`
+	Error   string `json:"error,omitempty"`
+}
+
+func runTokenRevoke(e *Env, args []string) error {
+	fs := flag.NewFlagSet("revoke", flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	output := outputFlag(fs)
+	due := fs.Bool("due", false, "Revoke the tokens whose grace period after a rotation is over")
+	if err := fs.Parse(args); err != nil || (fs.NArg() == 0) != *due || !validOutput(*output) {
+		return errUsage
+	}
+
+	c, err := e.Client()
+	if err != nil {
+		return err
+	}
+
+	// Tokens to revoke as a reference and the token it was resolved to, or why it couldn't be
+	type target struct {
+		ref   string
+		token scope.TokenInformation
+		err   error
+	}
+	targets := []target{}
+	if *due {
+		r, err := registry.LoadDefault()
+		if err != nil {
+			return err
+		}
+		for _, entry := range r.Due(time.Now()) {
+			id, err := uuid.Parse(entry.Id)
+			name := entry.Name
+			targets = append(targets, target{ref: entry.Id, token: scope.TokenInformation{Id: &id, TokenName: &name}, err: err})
+		}
+		if len(targets) == 0 && *output == "text" {
+			fmt.Fprintln(e.Stdout, "No tokens are due to be revoked")
+		}
+	} else {
+		tokens, err := api.ListTokens(c)
+		if err != nil {
+			return err
+		}
+		for _, ref := range fs.Args() {
+			t, err := e.find(tokens, ref)
+			targets = append(targets, target{ref: ref, token: t, err: err})
+		}
+	}
+
+	failed := 0
+	results := []revoked{}
+	for _, target := range targets {
+		t, err := target.token, target.err
+		if err == nil {
+			err = api.RevokeToken(c, inventory.Id(t))
+		}
+		if err != nil {
+			failed++
+			results = append(results, revoked{Ref: target.ref, Error: err.Error()})
+			fmt.Fprintf(e.Stderr, "Could not revoke %s :: %+v\n", target.ref, err)
+			continue
+		}
+		e.forget(inventory.Id(t))
+		results = append(results, revoked{Ref: target.ref, Id: inventory.Id(t), Name: inventory.Name(t), Revoked: true})
+		if *output == "text" {
+			fmt.Fprintf(e.Stdout, "Revoked %s (%s)\n", inventory.Name(t), inventory.Id(t))
+		}
+	}
+	if *output == "json" {
+		if err := printJSON(e, results); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d token(s) could not be revoked", failed, len(targets))
+	}
+	return nil
+}
+
+// Find a token like inventory.Find. When a name is ambiguous, tokens waiting to be revoked
+// after a rotation don't count, so the name refers to the token that replaced them
+func (e *Env) find(tokens []scope.TokenInformation, ref string) (scope.TokenInformation, error) {
+	t, err := inventory.Find(tokens, ref)
+	if err == nil {
+		return t, nil
+	}
+	r, rErr := registry.LoadDefault()
+	if rErr != nil {
+		return t, err
+	}
+	current := []scope.TokenInformation{}
+	for _, t := range tokens {
+		if !r.Pending(inventory.Id(t)) {
+			current = append(current, t)
+		}
+	}
+	if len(current) == len(tokens) {
+		return t, err
+	}
+	if t, cErr := inventory.Find(current, ref); cErr == nil {
+		return t, nil
+	}
+	return t, err
+}
+
+func runTokenRotate(e *Env, args []string) error {
+	fs := flag.NewFlagSet("rotate", flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	validity := fs.String("validity", "", "How long the new token is valid, defaults to the lifetime of the old token")
+	out := e.outputFlags(fs)
+	revokeAfter := fs.String("revoke-after", "", "Revoke the old token after this grace period, e.g. 0s or 1h")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	w, status, err := e.tokenWriter(out)
+	if err != nil {
+		return err
+	}
+
+	grace := time.Duration(-1)
+	if *revokeAfter != "" {
+		d, err := inventory.ParseDuration(*revokeAfter)
+		if err != nil {
+			return err
+		}
+		grace = d
+	}
+
+	c, err := e.Client()
+	if err != nil {
+		return err
+	}
+	tokens, err := api.ListTokens(c)
+	if err != nil {
+		return err
+	}
+	old, err := e.find(tokens, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	lifetime, ok := inventory.Lifetime(old)
+	if !ok {
+		lifetime = inventory.DefaultRotateValidity
+	}
+	if *validity != "" {
+		if lifetime, err = inventory.ParseDuration(*validity); err != nil {
+			return err
+		}
+	}
+	req, err := inventory.RotateRequest(old, lifetime)
+	if err != nil {
+		return err
+	}
+
+	res, err := api.CreateToken(c, req)
+	if err != nil {
+		return fmt.Errorf("Could not create a new token for %s :: %w", inventory.Name(old), err)
+	}
+	fileName, err := w.Write(inventory.Name(old), res)
+	if err != nil {
+		return fmt.Errorf("Created %s (%s) but could not write it :: %+v", inventory.Name(old), res.Id, err)
+	}
+	e.record(inventory.Name(old), res, fileName)
+	fmt.Fprintf(status, "Rotated %s (%s) to %s, wrote it to %s\n", inventory.Name(old), inventory.Id(old), res.Id, fileName)
+
+	if grace < 0 {
+		return nil
+	}
+	if grace > 0 {
+		// The session may well run out before the grace period does, so the revoke is left to compass token revoke -due
+		at := time.Now().Add(grace)
+		if err := registry.ScheduleRevoke(inventory.Id(old), inventory.Name(old), at); err != nil {
+			return fmt.Errorf("Could not schedule revoking %s :: %+v", inventory.Id(old), err)
+		}
+		fmt.Fprintf(status, "%s (%s) can be revoked from %s with compass token revoke -due\n", inventory.Name(old), inventory.Id(old), at.Format(time.RFC3339))
+		return nil
+	}
+	if err := api.RevokeToken(c, inventory.Id(old)); err != nil {
+		return fmt.Errorf("Could not revoke %s :: %w", inventory.Id(old), err)
+	}
+	e.forget(inventory.Id(old))
+	fmt.Fprintf(status, "Revoked %s (%s)\n", inventory.Name(old), inventory.Id(old))
+	return nil
+}
+
+// What compass token inspect prints with -output json
+type inspection struct {
+	Token            scope.TokenInformation `json:"token"`
+	Status           string                 `json:"status"`
+	RemainingSeconds *int64                 `json:"remainingSeconds,omitempty"`
+	Scope            []string               `json:"scope"`
+	Limitations      []string               `json:"limitations"`
+}
+
+func runTokenInspect(e *Env, args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	output := fs.String("output", "text", "Output format, text or json")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+	if *output != "text" && *output != "json" {
+		return errUsage
+	}
+
+	input, err := readTokenInput(e, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	c, err := e.Client()
+	if err != nil {
+		return err
+	}
+	info, err := resolveToken(c, input)
+	if err != nil {
+		return err
+	}
+	zones, err := api.GetZones(c)
+	if err != nil {
+		return err
+	}
+
+	res := inspection{
+		Token:       info,
+		Status:      inventory.StatusOf(info, time.Now(), inventory.DefaultSoon).String(),
+		Scope:       []string{},
+		Limitations: []string{},
+	}
+	if expires, ok := inventory.Expires(info); ok {
+		remaining := int64(time.Until(expires) / time.Second)
+		res.RemainingSeconds = &remaining
+	}
+	if info.Scope != nil {
+		for _, r := range scope.NameRules(scope.RulesFromPermissions(info.Scope.Permissions), zones) {
+			res.Scope = append(res.Scope, r.String())
+		}
+		res.Limitations = inventory.Limitations(*info.Scope)
+	}
+
+	if *output == "json" {
+		return printJSON(e, res)
+	}
+	printInspection(e, res)
+	return nil
+}
+
+// Read a token file, or a token file or raw token from stdin when ref is -.
+// Raw tokens are never taken from the arguments, where they would end up in the process list
+func readTokenInput(e *Env, ref string) (string, error) {
+	if ref == "-" {
+		data, err := io.ReadAll(e.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("Could not read stdin :: %+v", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		return "", fmt.Errorf("Could not read %s, pass raw tokens on stdin with - :: %+v", ref, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Look up a token from the contents of a token file or a raw token
+func resolveToken(c client.ClientInterface, input string) (scope.TokenInformation, error) {
+	if input == "" {
+		return scope.TokenInformation{}, fmt.Errorf("No token to inspect")
+	}
+	if strings.HasPrefix(input, "{") {
+		res := scope.TokenResult{}
+		if err := json.Unmarshal([]byte(input), &res); err != nil {
+			return scope.TokenInformation{}, fmt.Errorf("Could not read token file :: %+v", err)
+		}
+		if res.Id != "" {
+			return api.GetToken(c, res.Id)
+		}
+		input = res.Token
+		if input == "" {
+			return scope.TokenInformation{}, fmt.Errorf("Token file has neither an id nor a token")
+		}
+	}
+	return api.IntrospectToken(c, input)
+}
+
+func printInspection(e *Env, res inspection) {
+	t := res.Token
+	w := tabwriter.NewWriter(e.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Name\t%s\n", inventory.Name(t))
+	fmt.Fprintf(w, "Id\t%s\n", inventory.Id(t))
+	if t.Scope != nil && t.Scope.Description != "" {
+		fmt.Fprintf(w, "Description\t%s\n", t.Scope.Description)
+	}
+	fmt.Fprintf(w, "Created\t%s\n", inventory.FormatTime(inventory.Created(t)))
+	validUntil := inventory.FormatTime(inventory.Expires(t))
+	if res.RemainingSeconds != nil {
+		remaining := time.Duration(*res.RemainingSeconds) * time.Second
+		if remaining > 0 {
+			validUntil += " (" + inventory.FormatRemaining(remaining) + " left)"
+		} else {
+			validUntil += " (expired " + inventory.FormatRemaining(remaining) + ")"
+		}
+	}
+	fmt.Fprintf(w, "Valid until\t%s\n", validUntil)
+	fmt.Fprintf(w, "Status\t%s\n", res.Status)
+	clients := []string{}
+	if t.Scope != nil {
+		for _, c := range t.Scope.Clients {
+			clients = append(clients, string(c))
+		}
+	}
+	if len(clients) == 0 && t.ClientIdentifier != nil {
+		clients = append(clients, *t.ClientIdentifier)
+	}
+	fmt.Fprintf(w, "Clients\t%s\n", strings.Join(clients, ", "))
+	w.Flush()
+
+	fmt.Fprintln(e.Stdout, "\nScope")
+	if len(res.Scope) == 0 {
+		fmt.Fprintln(e.Stdout, "  none")
+	}
+	for _, r := range res.Scope {
+		fmt.Fprintln(e.Stdout, "  "+r)
+	}
+	if len(res.Limitations) > 0 {
+		fmt.Fprintln(e.Stdout, "\nLimitations")
+		for _, l := range res.Limitations {
+			fmt.Fprintln(e.Stdout, "  "+l)
+		}
+	}
+}
+
+// Exit code of compass token expiring when tracked tokens expire soon
+const EXIT_EXPIRING = 3
+
+func runTokenExpiring(e *Env, args []string) error {
+	fs := flag.NewFlagSet("expiring", flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	within := fs.String("within", "7d", "Report tokens that expire within this duration")
+	output := fs.String("output", "text", "Output format, text or json")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errUsage
+	}
+	if *output != "text" && *output != "json" {
+		return errUsage
+	}
+	d, err := inventory.ParseDuration(*within)
+	if err != nil {
+		return err
+	}
+
+	r, err := registry.LoadDefault()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	expiring := r.Expiring(now, d)
+
+	if *output == "json" {
+		if err := printJSON(e, expiring); err != nil {
+			return err
+		}
+	} else if len(expiring) == 0 {
+		fmt.Fprintf(e.Stdout, "No tokens expire within %s\n", *within)
+	} else {
+		w := tabwriter.NewWriter(e.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tID\tVALID UNTIL\tLEFT\tPATH")
+		for _, t := range expiring {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				t.Name,
+				t.Id,
+				inventory.FormatTime(t.Expires, true),
+				inventory.FormatRemaining(t.Expires.Sub(now)),
+				t.Path,
+			)
+		}
+		w.Flush()
+	}
+	if len(expiring) > 0 {
+		return exitCode(EXIT_EXPIRING)
+	}
+	return nil
+}
+
+func runTokenDecrypt(e *Env, args []string) error {
+	fs := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	identityFile := fs.String("i", "", "File with age identities, AGE-SECRET-KEY-1...")
+	outPath := fs.String("o", output.STDOUT, "File to write the decrypted token to, - for stdout")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	var data []byte
+	var err error
+	if fs.Arg(0) == "-" {
+		data, err = io.ReadAll(e.Stdin)
+	} else {
+		data, err = os.ReadFile(fs.Arg(0))
+	}
+	if err != nil {
+		return fmt.Errorf("Could not read %s :: %+v", fs.Arg(0), err)
+	}
+
+	passphrase := ""
+	identities := []crypt.Identity{}
+	if *identityFile != "" {
+		if identities, err = crypt.LoadIdentities(*identityFile); err != nil {
+			return err
+		}
+	} else if passphrase, err = crypt.EnvPassphrase(); err != nil {
+		return fmt.Errorf("Use -i for an identity file or set %s to the passphrase", crypt.PASSPHRASE_ENV)
+	}
+
+	plain, err := crypt.Decrypt(data, passphrase, identities)
+	if err != nil {
+		return err
+	}
+	if *outPath == output.STDOUT {
+		_, err = e.Stdout.Write(plain)
+		return err
+	}
+	return fsutil.WriteFileAtomic(*outPath, plain)
+}
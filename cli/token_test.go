@@ -0,0 +1,446 @@
+package cli
+
+import (
+	"compass/crypt"
+	"compass/crypt/crypttest"
+	"compass/registry"
+	"compass/scope"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func tokensHandler(t *testing.T) http.Handler {
+	soon := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+	later := time.Now().Add(90 * 24 * time.Hour).UTC().Format(time.RFC3339)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/zones":
+			w.Write([]byte(`[{"name": "Finance", "id": "` + testZoneId + `"}]`))
+		case r.Method == http.MethodGet && r.URL.Path == "/tokens":
+			w.Write([]byte(`[
+				{"tokenName": "backup", "tokenId": "11111111-1111-1111-1111-111111111111", "validUntil": "` + later + `",
+				 "clientIdentifier": "SynkzoneSSI", "scope": {"permissions": {"Zone=` + testZoneId + `": {"get": true}}}},
+				{"tokenName": "audit", "tokenId": "22222222-2222-2222-2222-222222222222", "validUntil": "` + soon + `"},
+				{"tokenName": "old", "tokenId": "33333333-3333-3333-3333-333333333333", "validUntil": "2020-01-01T00:00:00Z"}
+			]`))
+		case r.Method == http.MethodDelete && r.URL.Path == "/tokens/11111111-1111-1111-1111-111111111111":
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestTokenList(t *testing.T) {
+	e, stdout, stderr := newTestEnv(t, tokensHandler(t), "")
+	if code := Run(e, []string{"token", "list"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected a header and three tokens, got %q", stdout.String())
+	}
+	if !strings.HasPrefix(lines[1], "audit") || !strings.Contains(lines[1], "expiring") {
+		t.Errorf("expected audit to be first and expiring, got %q", lines[1])
+	}
+	if !strings.Contains(lines[2], "zone:Finance get") || !strings.Contains(lines[2], "valid") {
+		t.Errorf("expected backup with its scope, got %q", lines[2])
+	}
+	if !strings.Contains(lines[3], "expired") {
+		t.Errorf("expected old to be expired, got %q", lines[3])
+	}
+}
+
+func TestTokenList_FilterAndJson(t *testing.T) {
+	e, stdout, stderr := newTestEnv(t, tokensHandler(t), "")
+	if code := Run(e, []string{"token", "list", "-filter", "finance", "-output", "json"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	tokens := []map[string]any{}
+	if err := json.Unmarshal(stdout.Bytes(), &tokens); err != nil {
+		t.Fatalf("expected json, got %v", err)
+	}
+	if len(tokens) != 1 || tokens[0]["tokenName"] != "backup" {
+		t.Errorf("expected only backup, got %+v", tokens)
+	}
+}
+
+func TestTokenList_Usage(t *testing.T) {
+	e, _, _ := newTestEnv(t, tokensHandler(t), "")
+	if code := Run(e, []string{"token", "list", "-sort", "size"}); code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+}
+
+func TestTokenRevoke(t *testing.T) {
+	e, stdout, stderr := newTestEnv(t, tokensHandler(t), "")
+	if code := Run(e, []string{"token", "revoke", "backup"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Revoked backup (11111111-1111-1111-1111-111111111111)") {
+		t.Errorf("unexpected output %q", stdout.String())
+	}
+}
+
+func TestTokenRevoke_PartialFailure(t *testing.T) {
+	e, stdout, stderr := newTestEnv(t, tokensHandler(t), "")
+	code := Run(e, []string{"token", "revoke", "11111111-1111-1111-1111-111111111111", "audit", "nope"})
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "Revoked backup") {
+		t.Errorf("expected backup to be revoked, got %q", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "Could not revoke audit") || !strings.Contains(stderr.String(), "Could not revoke nope") {
+		t.Errorf("expected a result for every token, got %q", stderr.String())
+	}
+}
+
+// Serve tokensHandler and record the token requests it receives
+func rotateHandler(t *testing.T, requests *[]scope.NewTokenRequest) http.Handler {
+	tokens := tokensHandler(t)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/tokens" {
+			tokens.ServeHTTP(w, r)
+			return
+		}
+		req := scope.NewTokenRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("unable to decode request: %v", err)
+		}
+		*requests = append(*requests, req)
+		w.Write([]byte(`{"token": "secret", "expiresAt": "2030-01-01T00:00:00Z", "id": "44444444-4444-4444-4444-444444444444"}`))
+	})
+}
+
+func TestTokenRotate(t *testing.T) {
+	requests := []scope.NewTokenRequest{}
+	e, stdout, stderr := newTestEnv(t, rotateHandler(t, &requests), "")
+	outDir := t.TempDir()
+	code := Run(e, []string{"token", "rotate", "-validity", "2d", "-o", outDir, "-revoke-after", "0s", "backup"})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+
+	if len(requests) != 1 {
+		t.Fatalf("expected one token request, got %d", len(requests))
+	}
+	req := requests[0]
+	if *req.TokenName != "backup" || *req.Validity != 2*24*60*60 {
+		t.Errorf("unexpected request %+v", req)
+	}
+	if !req.Scope.Permissions["Zone="+testZoneId].Get {
+		t.Errorf("expected the scope of the old token, got %+v", req.Scope)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "token~backup.json")); err != nil {
+		t.Errorf("expected a token file, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "Revoked backup (11111111-1111-1111-1111-111111111111)") {
+		t.Errorf("expected the old token to be revoked, got %q", stdout.String())
+	}
+}
+
+func TestTokenRotate_KeepsOldToken(t *testing.T) {
+	requests := []scope.NewTokenRequest{}
+	e, stdout, stderr := newTestEnv(t, rotateHandler(t, &requests), "")
+	if code := Run(e, []string{"token", "rotate", "-o", t.TempDir(), "backup"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if strings.Contains(stdout.String(), "Revoked") {
+		t.Errorf("expected the old token to be kept, got %q", stdout.String())
+	}
+	if len(requests) != 1 || *requests[0].Validity != 30*24*60*60 {
+		t.Errorf("expected the default validity, got %+v", requests)
+	}
+}
+
+func TestTokenRotate_RevokeLater(t *testing.T) {
+	requests := []scope.NewTokenRequest{}
+	e, stdout, stderr := newTestEnv(t, rotateHandler(t, &requests), "")
+	if code := Run(e, []string{"token", "rotate", "-o", t.TempDir(), "-revoke-after", "1h", "backup"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if strings.Contains(stdout.String(), "Revoked") || !strings.Contains(stdout.String(), "revoke -due") {
+		t.Errorf("expected the revoke to be left for later, got %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := Run(e, []string{"token", "revoke", "-due"}); code != 0 || !strings.Contains(stdout.String(), "No tokens are due") {
+		t.Errorf("expected nothing to be due yet, got %d: %q", code, stdout.String())
+	}
+
+	// The grace period is over
+	registry.ScheduleRevoke("11111111-1111-1111-1111-111111111111", "backup", time.Now().Add(-time.Minute))
+	stdout.Reset()
+	if code := Run(e, []string{"token", "revoke", "-due"}); code != 0 || !strings.Contains(stdout.String(), "Revoked backup (11111111-1111-1111-1111-111111111111)") {
+		t.Errorf("expected the old token to be revoked, got %d: %q %s", code, stdout.String(), stderr.String())
+	}
+	r, _ := registry.LoadDefault()
+	if r.Pending("11111111-1111-1111-1111-111111111111") {
+		t.Error("expected the revoked token to be forgotten")
+	}
+	if code := Run(e, []string{"token", "revoke", "-due", "backup"}); code != 2 {
+		t.Errorf("expected -due with names to be a usage error, got %d", code)
+	}
+}
+
+func TestEnv_Find_Rotated(t *testing.T) {
+	e, _, _ := newTestEnv(t, tokensHandler(t), "")
+	name := "backup"
+	oldId, newId := uuid.MustParse("11111111-1111-1111-1111-111111111111"), uuid.MustParse("44444444-4444-4444-4444-444444444444")
+	tokens := []scope.TokenInformation{{TokenName: &name, TokenId: &oldId}, {TokenName: &name, TokenId: &newId}}
+	if _, err := e.find(tokens, "backup"); err == nil {
+		t.Error("expected two tokens with the same name to be ambiguous")
+	}
+	registry.ScheduleRevoke(oldId.String(), name, time.Now().Add(time.Hour))
+	if found, err := e.find(tokens, "backup"); err != nil || *found.TokenId != newId {
+		t.Errorf("expected the token that replaced the rotated one, got %+v, %v", found, err)
+	}
+}
+
+func TestTokenRotate_NoScope(t *testing.T) {
+	requests := []scope.NewTokenRequest{}
+	e, _, _ := newTestEnv(t, rotateHandler(t, &requests), "")
+	if code := Run(e, []string{"token", "rotate", "-o", t.TempDir(), "audit"}); code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if len(requests) != 0 {
+		t.Errorf("expected no token to be created, got %+v", requests)
+	}
+}
+
+func inspectHandler(t *testing.T) http.Handler {
+	later := time.Now().Add(10 * 24 * time.Hour).UTC().Format(time.RFC3339)
+	info := `{"tokenName": "backup", "tokenId": "11111111-1111-1111-1111-111111111111", "validUntil": "` + later + `",
+		"scope": {"clients": ["SynkzoneSSI"], "permissions": {"Zone=` + testZoneId + `": {"get": true, "list": true}},
+		"limitations": {"maxUses": {"uses": 3}}}}`
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/zones":
+			w.Write([]byte(`[{"name": "Finance", "id": "` + testZoneId + `"}]`))
+		case r.Method == http.MethodGet && r.URL.Path == "/tokens/11111111-1111-1111-1111-111111111111":
+			w.Write([]byte(info))
+		case r.Method == http.MethodPost && r.URL.Path == "/tokens/introspect":
+			req := map[string]string{}
+			json.NewDecoder(r.Body).Decode(&req)
+			if req["token"] != "secret" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(info))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestTokenInspect_File(t *testing.T) {
+	e, stdout, stderr := newTestEnv(t, inspectHandler(t), "")
+	file := writeFile(t, "token~2024-06-01T12:00:00Z.json", `{"token": "secret", "expiresAt": "", "id": "11111111-1111-1111-1111-111111111111"}`)
+	if code := Run(e, []string{"token", "inspect", file}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	for _, expected := range []string{"backup", "left)", "SynkzoneSSI", "zone:Finance get,list", "Max uses: 3"} {
+		if !strings.Contains(stdout.String(), expected) {
+			t.Errorf("expected %q in output, got %q", expected, stdout.String())
+		}
+	}
+}
+
+func TestTokenInspect_RawTokenJson(t *testing.T) {
+	e, stdout, stderr := newTestEnv(t, inspectHandler(t), "secret\n")
+	if code := Run(e, []string{"token", "inspect", "-output", "json", "-"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	res := inspection{}
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		t.Fatalf("expected json, got %v", err)
+	}
+	if res.Status != "valid" || res.RemainingSeconds == nil || *res.RemainingSeconds <= 0 {
+		t.Errorf("expected a valid token with time left, got %+v", res)
+	}
+	if len(res.Scope) != 1 || res.Scope[0] != "zone:Finance get,list" {
+		t.Errorf("unexpected scope %+v", res.Scope)
+	}
+}
+
+func TestTokenInspect_UnknownToken(t *testing.T) {
+	e, _, _ := newTestEnv(t, inspectHandler(t), "not-a-token\n")
+	if code := Run(e, []string{"token", "inspect", "-"}); code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+}
+
+func TestTokenInspect_MissingFile(t *testing.T) {
+	e, _, stderr := newTestEnv(t, inspectHandler(t), "")
+	if code := Run(e, []string{"token", "inspect", "secret"}); code != 1 || !strings.Contains(stderr.String(), "Could not read secret") {
+		t.Errorf("expected exit code 1 and an error, got %d: %s", code, stderr.String())
+	}
+}
+
+func TestTokenExpiring(t *testing.T) {
+	e, stdout, stderr := newTestEnv(t, http.NotFoundHandler(), "")
+	if code := Run(e, []string{"token", "expiring"}); code != 0 {
+		t.Fatalf("expected exit code 0 without tracked tokens, got %d: %s", code, stderr.String())
+	}
+
+	registry.Record(registry.Entry{Id: "1", Name: "backup", Expires: time.Now().Add(48 * time.Hour), Path: "token~backup.json"})
+	registry.Record(registry.Entry{Id: "2", Name: "audit", Expires: time.Now().Add(60 * 24 * time.Hour)})
+	stdout.Reset()
+	if code := Run(e, []string{"token", "expiring", "-within", "7d"}); code != EXIT_EXPIRING {
+		t.Fatalf("expected exit code %d, got %d: %s", EXIT_EXPIRING, code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "token~backup.json") || strings.Contains(stdout.String(), "audit") {
+		t.Errorf("expected only backup, got %q", stdout.String())
+	}
+}
+
+func TestTokenRotate_Registry(t *testing.T) {
+	requests := []scope.NewTokenRequest{}
+	e, _, stderr := newTestEnv(t, rotateHandler(t, &requests), "")
+	registry.Record(registry.Entry{Id: "11111111-1111-1111-1111-111111111111", Name: "backup"})
+	if code := Run(e, []string{"token", "rotate", "-o", t.TempDir(), "-revoke-after", "0s", "backup"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	r, err := registry.LoadDefault()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(r.Entries) != 1 || r.Entries[0].Id != "44444444-4444-4444-4444-444444444444" {
+		t.Errorf("expected only the new token to be tracked, got %+v", r.Entries)
+	}
+}
+
+func TestTokenDecrypt(t *testing.T) {
+	identity, recipient := crypttest.Identity, crypttest.Recipient
+	requests := []scope.NewTokenRequest{}
+	e, stdout, stderr := newTestEnv(t, rotateHandler(t, &requests), "")
+	outDir := t.TempDir()
+	if code := Run(e, []string{"token", "rotate", "-o", outDir, "-encrypt-to", recipient, "backup"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	file := filepath.Join(outDir, "token~backup.json.age")
+	if data, err := os.ReadFile(file); err != nil || strings.Contains(string(data), "secret") {
+		t.Fatalf("expected an encrypted token file, got %v", err)
+	}
+
+	t.Setenv(crypt.PASSPHRASE_ENV, "")
+	if code := Run(e, []string{"token", "decrypt", file}); code != 1 {
+		t.Errorf("expected exit code 1 without a key, got %d", code)
+	}
+
+	stdout.Reset()
+	identityFile := writeFile(t, "identity.txt", identity+"\n")
+	if code := Run(e, []string{"token", "decrypt", "-i", identityFile, file}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	res := scope.TokenResult{}
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil || res.Token != "secret" {
+		t.Errorf("expected the token, got %q, %v", stdout.String(), err)
+	}
+}
+
+func TestTokenCreate(t *testing.T) {
+	requests := []scope.NewTokenRequest{}
+	e, stdout, stderr := newTestEnv(t, rotateHandler(t, &requests), "zone:Finance get,list\n")
+	outDir := t.TempDir()
+	code := Run(e, []string{"token", "create", "-name", "ci", "-scope", "-", "-validity", "1d", "-description", "For CI", "-o", outDir, "-output", "json"})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if len(requests) != 1 {
+		t.Fatalf("expected one token request, got %d", len(requests))
+	}
+	req := requests[0]
+	if *req.TokenName != "ci" || *req.Validity != 86400 || req.Scope.Description != "For CI" || !req.Scope.Permissions["Zone="+testZoneId].List {
+		t.Errorf("unexpected request %+v", req)
+	}
+
+	res := created{}
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil || res.Id != "44444444-4444-4444-4444-444444444444" {
+		t.Fatalf("unexpected output %s, %v", stdout.String(), err)
+	}
+	if data, err := os.ReadFile(res.Path); err != nil || !strings.Contains(string(data), "secret") {
+		t.Errorf("expected the token in %s, got %s, %v", res.Path, data, err)
+	}
+}
+
+func TestTokenCreate_Rejected(t *testing.T) {
+	tests := []struct {
+		status   int
+		expected int
+	}{
+		{http.StatusUnauthorized, EXIT_AUTH},
+		{http.StatusForbidden, 1},
+	}
+	for _, test := range tests {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/zones" {
+				w.Write([]byte(`[{"name": "Finance", "id": "` + testZoneId + `"}]`))
+				return
+			}
+			w.WriteHeader(test.status)
+		})
+		e, _, stderr := newTestEnv(t, handler, "")
+		if code := Run(e, []string{"token", "create", "-name", "ci", "-scope", "zone:Finance get", "-o", t.TempDir()}); code != test.expected {
+			t.Errorf("%d: expected exit code %d, got %d: %s", test.status, test.expected, code, stderr.String())
+		}
+	}
+}
+
+func TestTokenCreate_Template(t *testing.T) {
+	requests := []scope.NewTokenRequest{}
+	e, _, stderr := newTestEnv(t, rotateHandler(t, &requests), "")
+	code := Run(e, []string{"token", "create", "-template", "backup", "-f", writeManifest(t), "-o", t.TempDir()})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if len(requests) != 1 || *requests[0].TokenName != "backup" || *requests[0].Validity != 3600 {
+		t.Errorf("expected the template name and validity, got %+v", requests)
+	}
+}
+
+func TestTokenCreate_Usage(t *testing.T) {
+	requests := []scope.NewTokenRequest{}
+	e, _, _ := newTestEnv(t, rotateHandler(t, &requests), "")
+	tests := [][]string{
+		{"token", "create", "-scope", "zone:Finance get"},
+		{"token", "create", "-name", "ci"},
+		{"token", "create", "-name", "ci", "-scope", "zone:Finance get", "-o", "-", "-output", "json"},
+		{"token", "create", "-name", "ci", "-scope", "zone:Finance get", "-clients", "SynkzonSSI"},
+	}
+	for _, args := range tests {
+		if code := Run(e, args); code == 0 {
+			t.Errorf("%v: expected a failure", args)
+		}
+	}
+	if len(requests) != 0 {
+		t.Errorf("expected no tokens, got %+v", requests)
+	}
+}
+
+func TestTokenRevoke_Json(t *testing.T) {
+	e, stdout, _ := newTestEnv(t, tokensHandler(t), "")
+	if code := Run(e, []string{"token", "revoke", "-output", "json", "backup", "nope"}); code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	results := []revoked{}
+	if err := json.Unmarshal(stdout.Bytes(), &results); err != nil || len(results) != 2 {
+		t.Fatalf("unexpected output %s, %v", stdout.String(), err)
+	}
+	if !results[0].Revoked || results[0].Id != "11111111-1111-1111-1111-111111111111" || results[1].Revoked || results[1].Error == "" {
+		t.Errorf("unexpected results %+v", results)
+	}
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"compass/api"
+	"compass/scope"
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+var zonesCommand = Command{
+	Name:    "zones",
+	Usage:   "zones <command>",
+	Summary: "Look at the zones tokens can be scoped to",
+	Commands: []Command{
+		{
+			Name:    "list",
+			Usage:   "list [-filter text] [-output text|json]",
+			Summary: "List the zones you can see",
+			Run:     runZonesList,
+		},
+	},
+}
+
+func runZonesList(e *Env, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+	filter := fs.String("filter", "", "Only show zones where the name or id contains this text")
+	output := outputFlag(fs)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 || !validOutput(*output) {
+		return errUsage
+	}
+
+	c, err := e.Client()
+	if err != nil {
+		return err
+	}
+	zones, err := api.GetZones(c)
+	if err != nil {
+		return err
+	}
+
+	query := strings.ToLower(*filter)
+	matches := []scope.ZoneData{}
+	for _, z := range zones {
+		if strings.Contains(strings.ToLower(z.Name), query) || strings.Contains(z.Id.String(), query) {
+			matches = append(matches, z)
+		}
+	}
+
+	if *output == "json" {
+		return printJSON(e, matches)
+	}
+	w := tabwriter.NewWriter(e.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tID")
+	for _, z := range matches {
+		fmt.Fprintf(w, "%s\t%s\n", z.Name, z.Id)
+	}
+	w.Flush()
+	return nil
+}
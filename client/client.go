@@ -2,10 +2,12 @@ package client
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 )
 
 type Client struct {
@@ -16,42 +18,49 @@ type Client struct {
 	handlers   map[int]func()
 }
 
+// Returned when SSI answers with a status code of 300 or above
+type StatusError struct {
+	Code   int
+	Status string
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s :: %+v", e.Status, e.Body)
+}
+
 type ClientInterface interface {
 	Get(endpoint string, rv any) error
 	Post(endpoint string, data any, rv any) error
+	Delete(endpoint string, rv any) error
 }
 
 // Do a GET request to an endpoint. rv is used to unmarshal the result to any given GO value
 func (c *Client) Get(endpoint string, rv any) error {
-	for _, i := range c.intercepts {
-		i(c)
-	}
-
-	req, readErr := http.NewRequest("GET", c.host+endpoint, nil)
-	if readErr != nil {
-		return readErr
-	}
-
-	for h := range c.headers {
-		req.Header.Add(h, c.headers[h])
-	}
-
-	res, resErr := c.http.Do(req)
-	if resErr != nil {
-		return resErr
+	resBody, err := c.do("GET", endpoint, nil)
+	if err != nil {
+		return err
 	}
 
-	resBody, readErr := io.ReadAll(res.Body)
-	if readErr != nil {
-		return readErr
+	if rv != nil {
+		jsonErr := json.Unmarshal(resBody, &rv)
+		if jsonErr != nil {
+			return jsonErr
+		}
 	}
+	return nil
+}
 
-	if h, ok := c.handlers[res.StatusCode]; ok {
-		h()
+// Do a POST request to an endpoint. rv is used to unmarshal the result to any given GO value
+func (c *Client) Post(endpoint string, data any, rv any) error {
+	bodyBytes, err := json.Marshal(data)
+	if err != nil {
+		return err
 	}
 
-	if res.StatusCode >= 300 {
-		return fmt.Errorf("%s :: %+v", res.Status, string(resBody[:]))
+	resBody, err := c.do("POST", endpoint, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return err
 	}
 
 	if rv != nil {
@@ -63,20 +72,33 @@ func (c *Client) Get(endpoint string, rv any) error {
 	return nil
 }
 
-// Do a POST request to an endpoint. rv is used to unmarshal the result to any given GO value
-func (c *Client) Post(endpoint string, data any, rv any) error {
-	bodyBytes, err := json.Marshal(data)
+// Do a DELETE request to an endpoint. rv is used to unmarshal the result to any given GO value,
+// unless SSI answers without a body
+func (c *Client) Delete(endpoint string, rv any) error {
+	resBody, err := c.do("DELETE", endpoint, nil)
 	if err != nil {
 		return err
 	}
 
+	if rv != nil && len(resBody) > 0 {
+		jsonErr := json.Unmarshal(resBody, &rv)
+		if jsonErr != nil {
+			return jsonErr
+		}
+	}
+	return nil
+}
+
+// Send a request with the headers of the client and return the body of the response.
+// Status handlers run before a StatusError is returned for status codes of 300 and above
+func (c *Client) do(method string, endpoint string, body io.Reader) ([]byte, error) {
 	for _, i := range c.intercepts {
 		i(c)
 	}
 
-	req, reqErr := http.NewRequest("POST", c.host+endpoint, bytes.NewReader(bodyBytes))
+	req, reqErr := http.NewRequest(method, c.host+endpoint, body)
 	if reqErr != nil {
-		return reqErr
+		return nil, reqErr
 	}
 
 	for h := range c.headers {
@@ -85,12 +107,13 @@ func (c *Client) Post(endpoint string, data any, rv any) error {
 
 	res, resErr := c.http.Do(req)
 	if resErr != nil {
-		return resErr
+		return nil, resErr
 	}
+	defer res.Body.Close()
 
 	resBody, readErr := io.ReadAll(res.Body)
 	if readErr != nil {
-		return readErr
+		return nil, readErr
 	}
 
 	if h, ok := c.handlers[res.StatusCode]; ok {
@@ -98,16 +121,9 @@ func (c *Client) Post(endpoint string, data any, rv any) error {
 	}
 
 	if res.StatusCode >= 300 {
-		return fmt.Errorf("%s :: %+v", res.Status, string(resBody[:]))
-	}
-
-	if rv != nil {
-		jsonErr := json.Unmarshal(resBody, &rv)
-		if jsonErr != nil {
-			return jsonErr
-		}
+		return nil, &StatusError{Code: res.StatusCode, Status: res.Status, Body: string(resBody[:])}
 	}
-	return nil
+	return resBody, nil
 }
 
 // Initialize a new client
@@ -136,6 +152,11 @@ func (c *Client) SetHeader(key string, value string) {
 	c.headers[key] = value
 }
 
+// Stop sending a header set before
+func (c *Client) RemoveHeader(key string) {
+	delete(c.headers, key)
+}
+
 // Add a header to the client that will be applied before every request
 func WithHeader(key string, value string) func(*Client) {
 	return func(c *Client) {
@@ -150,6 +171,25 @@ func WithAuth(value string) func(*Client) {
 	}
 }
 
+// Give up on requests that take longer than d. Zero means no timeout
+func WithTimeout(d time.Duration) func(*Client) {
+	return func(c *Client) {
+		c.http.Timeout = d
+	}
+}
+
+// Use TLS settings of its own, e.g. for a private certificate authority. Nil keeps the defaults
+func WithTLS(config *tls.Config) func(*Client) {
+	return func(c *Client) {
+		if config == nil {
+			return
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = config
+		c.http.Transport = transport
+	}
+}
+
 // Add a function that will run before every method
 //
 // Useful when dealing with headers that cannot be set on initialization
@@ -1,7 +1,11 @@
 package client
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -44,6 +48,10 @@ func TestClient_Get_Non200(t *testing.T) {
 	if err == nil {
 		t.Errorf("expected error, got nil")
 	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusBadRequest || statusErr.Body != `{"error": "bad request"}` {
+		t.Errorf("expected a status error, got %#v", err)
+	}
 }
 
 func TestClient_Post_Success(t *testing.T) {
@@ -126,3 +134,96 @@ func TestClient_Get_WithStatusHandler(t *testing.T) {
 		t.Errorf("expected status handler to be called")
 	}
 }
+
+func TestClient_Delete_Success(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			t.Errorf("expected DELETE, got %s", r.Method)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	var result MockResponse
+	err := client.Delete("/test", &result)
+
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestClient_Delete_Non200(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer mockServer.Close()
+
+	client := NewClient(mockServer.URL)
+	err := client.Delete("/test", nil)
+
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestClient_Get_WithTLS(t *testing.T) {
+	mockServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(MockResponse{Message: "success"})
+	}))
+	defer mockServer.Close()
+
+	if err := NewClient(mockServer.URL).Get("/", nil); err == nil {
+		t.Error("expected error for an unknown certificate authority")
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(mockServer.Certificate())
+	var res MockResponse
+	if err := NewClient(mockServer.URL, WithTLS(&tls.Config{RootCAs: pool})).Get("/", &res); err != nil || res.Message != "success" {
+		t.Errorf("expected success with the server's certificate trusted, got %+v, %v", res, err)
+	}
+}
+
+type trackedBody struct {
+	io.ReadCloser
+	closed *int
+}
+
+func (b trackedBody) Close() error {
+	*b.closed++
+	return b.ReadCloser.Close()
+}
+
+type trackingTransport struct {
+	closed int
+}
+
+func (t *trackingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil {
+		res.Body = trackedBody{res.Body, &t.closed}
+	}
+	return res, err
+}
+
+func TestClient_ClosesBodies(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(MockResponse{Message: "success"})
+	}))
+	defer mockServer.Close()
+
+	transport := &trackingTransport{}
+	client := NewClient(mockServer.URL)
+	client.http.Transport = transport
+	client.Get("/test", nil)
+	client.Post("/test", MockResponse{}, nil)
+	client.Delete("/test", nil)
+	if transport.closed != 3 {
+		t.Errorf("expected every response body to be closed, got %d of 3", transport.closed)
+	}
+}
@@ -0,0 +1,128 @@
+package clip
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/atotto/clipboard"
+	"github.com/aymanbagabas/go-osc52/v2"
+)
+
+type Method int
+
+const (
+	// The clipboard of the machine compass runs on
+	METHOD_SYSTEM Method = iota
+	// An OSC52 escape sequence that asks the terminal to set its clipboard, which works over SSH
+	METHOD_OSC52
+)
+
+func (m Method) String() string {
+	if m == METHOD_OSC52 {
+		return "terminal clipboard"
+	}
+	return "clipboard"
+}
+
+var (
+	systemWrite       = clipboard.WriteAll
+	systemRead        = clipboard.ReadAll
+	systemUnsupported = func() bool { return clipboard.Unsupported }
+	getenv            = os.Getenv
+)
+
+// Over SSH the system clipboard is on the wrong machine
+func remote() bool {
+	return getenv("SSH_TTY") != "" || getenv("SSH_CONNECTION") != ""
+}
+
+func sequence(text string) osc52.Sequence {
+	seq := osc52.New(text)
+	if getenv("TMUX") != "" {
+		seq = seq.Tmux()
+	} else if getenv("STY") != "" {
+		seq = seq.Screen()
+	}
+	return seq
+}
+
+// Copy text to the system clipboard. Over SSH, or when there is no system clipboard,
+// an OSC52 sequence is written to terminal instead
+func Copy(text string, terminal io.Writer) (Method, error) {
+	if !remote() && !systemUnsupported() {
+		if err := systemWrite(text); err == nil {
+			return METHOD_SYSTEM, nil
+		}
+	}
+	if _, err := sequence(text).WriteTo(terminal); err != nil {
+		return METHOD_OSC52, fmt.Errorf("Could not copy to the clipboard :: %+v", err)
+	}
+	return METHOD_OSC52, nil
+}
+
+// Clear the clipboard if it still holds text. The terminal clipboard can't be read, so it is always cleared
+func Clear(method Method, text string, terminal io.Writer) error {
+	if method == METHOD_OSC52 {
+		seq := sequence("").Clear()
+		if _, err := seq.WriteTo(terminal); err != nil {
+			return fmt.Errorf("Could not clear the clipboard :: %+v", err)
+		}
+		return nil
+	}
+	current, err := systemRead()
+	if err != nil || current != text {
+		return nil
+	}
+	if err := systemWrite(""); err != nil {
+		return fmt.Errorf("Could not clear the clipboard :: %+v", err)
+	}
+	return nil
+}
+
+type pendingCopy struct {
+	method   Method
+	text     string
+	terminal io.Writer
+}
+
+var (
+	pendingLock sync.Mutex
+	pending     = map[int]pendingCopy{}
+	nextPending int
+)
+
+// Remember a copy that is cleared later. The returned func clears it, unless ClearPending already did
+func ClearLater(method Method, text string, terminal io.Writer) func() error {
+	pendingLock.Lock()
+	defer pendingLock.Unlock()
+	id := nextPending
+	nextPending++
+	pending[id] = pendingCopy{method, text, terminal}
+	return func() error {
+		pendingLock.Lock()
+		c, ok := pending[id]
+		delete(pending, id)
+		pendingLock.Unlock()
+		if !ok {
+			return nil
+		}
+		return Clear(c.method, c.text, c.terminal)
+	}
+}
+
+// Clear every copy that is still to be cleared, e.g. when compass quits before it is time
+func ClearPending() error {
+	pendingLock.Lock()
+	copies := pending
+	pending = map[int]pendingCopy{}
+	pendingLock.Unlock()
+	var failed error
+	for _, c := range copies {
+		if err := Clear(c.method, c.text, c.terminal); err != nil {
+			failed = err
+		}
+	}
+	return failed
+}
@@ -0,0 +1,92 @@
+package clip
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Replace the system clipboard and environment for a test
+func fake(t *testing.T, env map[string]string, unsupported bool) *string {
+	board := new(string)
+	oldWrite, oldRead, oldUnsupported, oldGetenv := systemWrite, systemRead, systemUnsupported, getenv
+	t.Cleanup(func() {
+		systemWrite, systemRead, systemUnsupported, getenv = oldWrite, oldRead, oldUnsupported, oldGetenv
+	})
+	systemWrite = func(text string) error {
+		if unsupported {
+			return errors.New("no clipboard")
+		}
+		*board = text
+		return nil
+	}
+	systemRead = func() (string, error) {
+		return *board, nil
+	}
+	systemUnsupported = func() bool { return unsupported }
+	getenv = func(key string) string { return env[key] }
+	return board
+}
+
+func TestCopy_System(t *testing.T) {
+	board := fake(t, map[string]string{}, false)
+	terminal := &bytes.Buffer{}
+	method, err := Copy("secret", terminal)
+	if err != nil || method != METHOD_SYSTEM || *board != "secret" {
+		t.Errorf("expected the system clipboard, got %s, %v, %q", method, err, *board)
+	}
+	if terminal.Len() != 0 {
+		t.Errorf("expected nothing on the terminal, got %q", terminal.String())
+	}
+
+	if err := Clear(method, "other", terminal); err != nil || *board != "secret" {
+		t.Errorf("expected a clipboard with other content to be left alone, got %q, %v", *board, err)
+	}
+	if err := Clear(method, "secret", terminal); err != nil || *board != "" {
+		t.Errorf("expected the clipboard to be cleared, got %q, %v", *board, err)
+	}
+}
+
+func TestCopy_OSC52(t *testing.T) {
+	tests := []struct {
+		env         map[string]string
+		unsupported bool
+		prefix      string
+	}{
+		{map[string]string{"SSH_TTY": "/dev/pts/1"}, false, "\x1b]52;c;"},
+		{map[string]string{}, true, "\x1b]52;c;"},
+		{map[string]string{"SSH_CONNECTION": "1", "TMUX": "1"}, false, "\x1bPtmux;\x1b\x1b]52;c;"},
+	}
+	for _, test := range tests {
+		board := fake(t, test.env, test.unsupported)
+		terminal := &bytes.Buffer{}
+		method, err := Copy("secret", terminal)
+		if err != nil || method != METHOD_OSC52 || *board != "" {
+			t.Errorf("%+v: expected osc52, got %s, %v, %q", test.env, method, err, *board)
+		}
+		if !strings.HasPrefix(terminal.String(), test.prefix) || !strings.Contains(terminal.String(), "c2VjcmV0") {
+			t.Errorf("%+v: unexpected sequence %q", test.env, terminal.String())
+		}
+
+		terminal.Reset()
+		if err := Clear(method, "secret", terminal); err != nil || !strings.Contains(terminal.String(), "52;c;!") {
+			t.Errorf("%+v: expected a clear sequence, got %q, %v", test.env, terminal.String(), err)
+		}
+	}
+}
+
+func TestClearPending(t *testing.T) {
+	board := fake(t, map[string]string{}, false)
+	terminal := &bytes.Buffer{}
+	Copy("secret", terminal)
+	clear := ClearLater(METHOD_SYSTEM, "secret", terminal)
+
+	if err := ClearPending(); err != nil || *board != "" {
+		t.Errorf("expected the pending copy to be cleared, got %q, %v", *board, err)
+	}
+	Copy("newer", terminal)
+	if err := clear(); err != nil || *board != "newer" {
+		t.Errorf("expected a copy cleared on quit not to be cleared again, got %q, %v", *board, err)
+	}
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"compass/scope"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A client besides SynkzoneSSI that tokens can be issued for
+type ClientDefinition struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	// Permission options the client understands, e.g. [get, list]. Every option when empty
+	Options []string `yaml:"options,omitempty"`
+}
+
+const everyOption = scope.S_ALL | scope.S_ACCESS | scope.S_CREATE | scope.S_DELETE | scope.S_GET | scope.S_LIST | scope.S_MODIFY
+
+type clientsFile struct {
+	Clients []ClientDefinition `yaml:"clients"`
+}
+
+// Where clients are defined: clients.yaml next to the system config and next to the user config
+func ClientsPaths() ([]string, error) {
+	user, err := UserPath()
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		filepath.Join(filepath.Dir(SystemPath), "clients.yaml"),
+		filepath.Join(filepath.Dir(user), "clients.yaml"),
+	}, nil
+}
+
+// Read client definitions from a file. A missing file defines no clients
+func LoadClients(path string) ([]scope.ClientInfo, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not read clients :: %+v", err)
+	}
+	f := clientsFile{}
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("Could not parse clients %s :: %+v", path, err)
+	}
+	infos := []scope.ClientInfo{}
+	for _, d := range f.Clients {
+		if d.Name == "" {
+			return nil, fmt.Errorf("A client without a name in %s", path)
+		}
+		info := scope.ClientInfo{Client: scope.Client(d.Name), Description: d.Description, Options: everyOption}
+		if len(d.Options) > 0 {
+			info.Options = 0
+			for _, name := range d.Options {
+				flag, ok := scope.PermissionFlag(name)
+				if !ok {
+					return nil, fmt.Errorf("Unknown option %s for client %s in %s", name, d.Name, path)
+				}
+				info.Options |= flag
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Register the clients defined in the system and user clients.yaml, so that tokens can be issued for them
+func RegisterClients() error {
+	paths, err := ClientsPaths()
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		infos, err := LoadClients(path)
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			scope.RegisterClient(info)
+		}
+	}
+	return nil
+}
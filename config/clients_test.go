@@ -0,0 +1,48 @@
+package config
+
+import (
+	"compass/scope"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRegisterClients(t *testing.T) {
+	isolate(t)
+	// Registering a client that is already there only to put the registry back afterwards
+	t.Cleanup(scope.RegisterClient(scope.ClientInfo{Client: scope.ClientSSI, Description: "Synkzone SSI", Options: everyOption}))
+	user, _ := UserPath()
+	write(t, filepath.Join(filepath.Dir(user), "clients.yaml"), "clients:\n  - name: Backup\n    description: Backup agent\n    options: [get, list]\n  - name: Sync\n")
+
+	if err := RegisterClients(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	clients, err := scope.ParseClients("SynkzoneSSI,Backup,Sync")
+	if err != nil || len(clients) != 3 {
+		t.Fatalf("expected the clients to be registered, got %v, %v", clients, err)
+	}
+	backup, _ := scope.LookupClient("Backup")
+	if backup.Description != "Backup agent" || backup.Options != scope.S_GET|scope.S_LIST {
+		t.Errorf("unexpected client %+v", backup)
+	}
+	if sync, _ := scope.LookupClient("Sync"); sync.Options != everyOption {
+		t.Errorf("expected every option without a list, got %+v", sync)
+	}
+}
+
+func TestLoadClients_Invalid(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{"clients:\n  - description: nameless\n", "without a name"},
+		{"clients:\n  - name: Backup\n    options: [read]\n", "Unknown option read"},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "clients.yaml")
+		write(t, path, test.content)
+		if _, err := LoadClients(path); err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%q: expected %q, got %v", test.content, test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,546 @@
+package config
+
+import (
+	"compass/client"
+	"compass/fsutil"
+	"compass/global"
+	"compass/inventory"
+	"compass/output"
+	"compass/scope"
+	"compass/session"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	KEY_HOST            = "host"
+	KEY_OUTPUT_DIR      = "output.dir"
+	KEY_OUTPUT_FORMAT   = "output.format"
+	KEY_VALIDITY        = "validity"
+	KEY_CLIENTS         = "clients"
+	KEY_THEME           = "theme"
+	KEY_REQUEST_TIMEOUT = "timeout.request"
+	KEY_SESSION_TIMEOUT = "timeout.session"
+	KEY_PROFILE         = "profile"
+	KEY_SESSION         = "session"
+	KEY_TLS_CA          = "tls.ca"
+	KEY_TLS_CERT        = "tls.cert"
+	KEY_TLS_KEY         = "tls.key"
+	KEY_TLS_INSECURE    = "tls.insecure"
+)
+
+const (
+	THEME_AUTO  = "auto"
+	THEME_DARK  = "dark"
+	THEME_LIGHT = "light"
+	// No colors at all
+	THEME_PLAIN = "plain"
+)
+
+// How long a session lasts without being used, unless configured otherwise
+const DEFAULT_SESSION_TIMEOUT = 15 * time.Minute
+
+// Where the system wide config is read from
+var SystemPath = "/etc/compass/config.yaml"
+
+type Output struct {
+	Dir    string `yaml:"dir,omitempty"`
+	Format string `yaml:"format,omitempty"`
+}
+
+type Timeout struct {
+	// How long a request to SSI may take
+	Request string `yaml:"request,omitempty"`
+	// How long a session lasts without being used
+	Session string `yaml:"session,omitempty"`
+}
+
+type TLS struct {
+	// PEM file with the certificate authorities SSI's certificate is checked against
+	CA string `yaml:"ca,omitempty"`
+	// PEM files with a client certificate and its key, for hosts that ask for one
+	Cert string `yaml:"cert,omitempty"`
+	Key  string `yaml:"key,omitempty"`
+	// true to skip checking SSI's certificate
+	Insecure string `yaml:"insecure,omitempty"`
+}
+
+// Settings as stored in a config file. Values are kept as text and are empty when unset
+type Config struct {
+	Host   string `yaml:"host,omitempty"`
+	Output Output `yaml:"output,omitempty"`
+	// Validity of new tokens, e.g. 30d
+	Validity string `yaml:"validity,omitempty"`
+	// Comma separated clients new tokens are issued for
+	Clients string  `yaml:"clients,omitempty"`
+	Theme   string  `yaml:"theme,omitempty"`
+	Timeout Timeout `yaml:"timeout,omitempty"`
+	TLS     TLS     `yaml:"tls,omitempty"`
+	// File the session is kept in. Each profile has its own by default
+	Session string `yaml:"session,omitempty"`
+	// The profile used unless another is asked for
+	Profile string `yaml:"profile,omitempty"`
+	// Named settings applied on top of the rest, e.g. for staging and production
+	Profiles map[string]Config `yaml:"profiles,omitempty"`
+}
+
+type key struct {
+	name        string
+	env         string
+	description string
+	field       func(c *Config) *string
+	validate    func(value string) error
+}
+
+func validDuration(value string) error {
+	_, err := inventory.ParseDuration(value)
+	return err
+}
+
+var keys = []key{
+	{KEY_HOST, "COMPASS_HOST", "Where SSI is, like -h", func(c *Config) *string { return &c.Host }, nil},
+	{KEY_OUTPUT_DIR, "COMPASS_OUTPUT_DIR", "Where tokens are written, like -o", func(c *Config) *string { return &c.Output.Dir }, nil},
+	{KEY_OUTPUT_FORMAT, "COMPASS_OUTPUT_FORMAT", "How tokens are written, like -format", func(c *Config) *string { return &c.Output.Format }, func(value string) error {
+		if !output.ValidFormat(value) {
+			return fmt.Errorf("Unknown output format %s, use one of %s", value, strings.Join(output.Formats(), ", "))
+		}
+		return nil
+	}},
+	{KEY_VALIDITY, "COMPASS_VALIDITY", "How long new tokens are valid, e.g. 30d", func(c *Config) *string { return &c.Validity }, validDuration},
+	{KEY_CLIENTS, "COMPASS_CLIENTS", "Comma separated clients new tokens are issued for, like -clients", func(c *Config) *string { return &c.Clients }, func(value string) error {
+		clients, err := scope.ParseClients(value)
+		if err != nil {
+			return err
+		}
+		return scope.SPScope{Clients: clients}.ValidateClients()
+	}},
+	{KEY_THEME, "COMPASS_THEME", "Colors of the TUI, auto, dark, light or plain", func(c *Config) *string { return &c.Theme }, func(value string) error {
+		switch value {
+		case THEME_AUTO, THEME_DARK, THEME_LIGHT, THEME_PLAIN:
+			return nil
+		}
+		return fmt.Errorf("Unknown theme %s, use auto, dark, light or plain", value)
+	}},
+	{KEY_REQUEST_TIMEOUT, "COMPASS_REQUEST_TIMEOUT", "How long a request to SSI may take, e.g. 30s", func(c *Config) *string { return &c.Timeout.Request }, validDuration},
+	{KEY_SESSION_TIMEOUT, "COMPASS_SESSION_TIMEOUT", "How long a session lasts without being used, e.g. 15m", func(c *Config) *string { return &c.Timeout.Session }, validDuration},
+	{KEY_PROFILE, "COMPASS_PROFILE", "The profile used unless -profile is given", func(c *Config) *string { return &c.Profile }, validProfile},
+	{KEY_SESSION, "COMPASS_SESSION", "File the session is kept in, one per profile by default", func(c *Config) *string { return &c.Session }, nil},
+	{KEY_TLS_CA, "COMPASS_TLS_CA", "PEM file with the certificate authorities to trust for SSI", func(c *Config) *string { return &c.TLS.CA }, nil},
+	{KEY_TLS_CERT, "COMPASS_TLS_CERT", "PEM file with a client certificate for SSI", func(c *Config) *string { return &c.TLS.Cert }, nil},
+	{KEY_TLS_KEY, "COMPASS_TLS_KEY", "PEM file with the key of the client certificate", func(c *Config) *string { return &c.TLS.Key }, nil},
+	{KEY_TLS_INSECURE, "COMPASS_TLS_INSECURE", "true to skip checking the certificate of SSI", func(c *Config) *string { return &c.TLS.Insecure }, func(value string) error {
+		_, err := strconv.ParseBool(value)
+		return err
+	}},
+}
+
+// Keys that decide which server is trusted with the session and where it is kept. A .compassrc comes
+// with whatever directory compass is run in, so these are only read from the user and system config
+var userOnlyKeys = map[string]bool{
+	KEY_HOST:         true,
+	KEY_SESSION:      true,
+	KEY_TLS_CA:       true,
+	KEY_TLS_CERT:     true,
+	KEY_TLS_KEY:      true,
+	KEY_TLS_INSECURE: true,
+}
+
+// Whether a key can only be set in the user or system config
+func UserOnly(name string) bool {
+	return userOnlyKeys[name]
+}
+
+func validProfile(value string) error {
+	if filepath.Base(value) != value || strings.HasPrefix(value, ".") {
+		return fmt.Errorf("Invalid profile name %s", value)
+	}
+	return nil
+}
+
+func find(name string) (key, error) {
+	for _, k := range keys {
+		if k.name == name {
+			return k, nil
+		}
+	}
+	return key{}, fmt.Errorf("Unknown config key %s, use one of %s", name, strings.Join(Keys(), ", "))
+}
+
+// Every config key
+func Keys() []string {
+	names := []string{}
+	for _, k := range keys {
+		names = append(names, k.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// What a key is for
+func Describe(name string) string {
+	k, err := find(name)
+	if err != nil {
+		return ""
+	}
+	return k.description
+}
+
+// The environment variable that sets a key
+func Env(name string) string {
+	k, err := find(name)
+	if err != nil {
+		return ""
+	}
+	return k.env
+}
+
+// The value of a key, empty when unset
+func (c *Config) Get(name string) (string, error) {
+	k, err := find(name)
+	if err != nil {
+		return "", err
+	}
+	return *k.field(c), nil
+}
+
+// Set a key. An empty value unsets it
+func (c *Config) Set(name string, value string) error {
+	k, err := find(name)
+	if err != nil {
+		return err
+	}
+	if value != "" && k.validate != nil {
+		if err := k.validate(value); err != nil {
+			return err
+		}
+	}
+	*k.field(c) = value
+	return nil
+}
+
+func (c Config) duration(value string, fallback time.Duration) time.Duration {
+	d, err := inventory.ParseDuration(value)
+	if value == "" || err != nil {
+		return fallback
+	}
+	return d
+}
+
+// The validity of new tokens, or fallback when unset
+func (c Config) ValidityOr(fallback time.Duration) time.Duration {
+	return c.duration(c.Validity, fallback)
+}
+
+// The timeout of requests to SSI. Zero when unset, which means no timeout
+func (c Config) RequestTimeout() time.Duration {
+	return c.duration(c.Timeout.Request, 0)
+}
+
+// How long sessions last, or fallback when unset
+func (c Config) SessionTimeoutOr(fallback time.Duration) time.Duration {
+	return c.duration(c.Timeout.Session, fallback)
+}
+
+// Read a config file. A missing file is an empty config
+func LoadFile(path string) (Config, error) {
+	c := Config{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, fmt.Errorf("Could not read config :: %+v", err)
+	}
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("Could not parse config %s :: %+v", path, err)
+	}
+	if err := c.validate(); err != nil {
+		return c, fmt.Errorf("%+v in %s", err, path)
+	}
+	for name, p := range c.Profiles {
+		if err := validProfile(name); err != nil {
+			return c, fmt.Errorf("%+v in %s", err, path)
+		}
+		if p.Profile != "" || len(p.Profiles) > 0 {
+			return c, fmt.Errorf("Profile %s in %s can't have profiles of its own", name, path)
+		}
+		if err := p.validate(); err != nil {
+			return c, fmt.Errorf("%+v in profile %s in %s", err, name, path)
+		}
+	}
+	return c, nil
+}
+
+// The config without the keys only the user and system config may set, also in profiles, and what was removed
+func (c Config) withoutUserOnly() (Config, []string) {
+	removed := []string{}
+	for _, k := range keys {
+		if userOnlyKeys[k.name] && *k.field(&c) != "" {
+			*k.field(&c) = ""
+			removed = append(removed, k.name)
+		}
+	}
+	if len(c.Profiles) == 0 {
+		return c, removed
+	}
+	profiles := map[string]Config{}
+	for _, name := range c.ProfileNames() {
+		p, inProfile := c.Profiles[name].withoutUserOnly()
+		profiles[name] = p
+		for _, key := range inProfile {
+			removed = append(removed, fmt.Sprintf("%s in profile %s", key, name))
+		}
+	}
+	c.Profiles = profiles
+	return c, removed
+}
+
+func (c Config) validate() error {
+	for _, k := range keys {
+		if value := *k.field(&c); value != "" && k.validate != nil {
+			if err := k.validate(value); err != nil {
+				return fmt.Errorf("Invalid %s :: %+v", k.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Write the config to path, creating its directory if needed
+func (c Config) Save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("Could not write config :: %+v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("Could not write config :: %+v", err)
+	}
+	if err := fsutil.WriteFileAtomic(path, data); err != nil {
+		return fmt.Errorf("Could not write config :: %+v", err)
+	}
+	return nil
+}
+
+// The config in the users config directory, e.g. ~/.config/compass/config.yaml
+func UserPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "compass", "config.yaml"), nil
+}
+
+// The closest .compassrc in the working directory or one of its parents. Empty if there is none
+func ProjectPath() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	name := filepath.Base(global.CONFIG_FILE)
+	for {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Settings from one place
+type Layer struct {
+	// system, user, project or env
+	Name string
+	// The file the layer was read from, empty for the environment
+	Path   string
+	Config Config
+	// Settings that were ignored, e.g. a host in a project config
+	Warnings []string
+}
+
+// Read every layer, lowest precedence first: system, user, project and the environment.
+// A file or environment variable that can't be read is left out and reported in the error, the other layers are still returned
+func Layers() ([]Layer, error) {
+	layers := []Layer{}
+	errs := []error{}
+	add := func(name string, path string) {
+		if path == "" {
+			return
+		}
+		c, err := LoadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		layer := Layer{Name: name, Path: path}
+		if name == "project" {
+			var removed []string
+			c, removed = c.withoutUserOnly()
+			for _, key := range removed {
+				layer.Warnings = append(layer.Warnings, fmt.Sprintf("%s can only be set in the user or system config, ignoring it in %s", key, path))
+			}
+		}
+		layer.Config = c
+		layers = append(layers, layer)
+	}
+
+	add("system", SystemPath)
+	if user, err := UserPath(); err != nil {
+		errs = append(errs, err)
+	} else {
+		add("user", user)
+	}
+	add("project", ProjectPath())
+
+	env := Config{}
+	for _, k := range keys {
+		if value := os.Getenv(k.env); value != "" {
+			if err := env.Set(k.name, value); err != nil {
+				errs = append(errs, fmt.Errorf("Invalid $%s :: %+v", k.env, err))
+			}
+		}
+	}
+	return append(layers, Layer{Name: "env", Config: env}), errors.Join(errs...)
+}
+
+// Combine layers, later layers win. Returns the combined config and the layer each value came from
+func Merge(layers []Layer) (Config, map[string]string) {
+	c := Config{}
+	sources := map[string]string{}
+	for _, l := range layers {
+		for _, k := range keys {
+			if value := *k.field(&l.Config); value != "" {
+				*k.field(&c) = value
+				sources[k.name] = l.Name
+			}
+		}
+		for name, p := range l.Config.Profiles {
+			if c.Profiles == nil {
+				c.Profiles = map[string]Config{}
+			}
+			merged, _ := Merge([]Layer{{Config: c.Profiles[name]}, {Config: p}})
+			c.Profiles[name] = merged
+		}
+	}
+	return c, sources
+}
+
+// Every profile, sorted by name
+func (c Config) ProfileNames() []string {
+	names := []string{}
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Combine layers like Merge, with a profile on top of the files. The environment still wins over the profile.
+// An empty profile means the one in the profile setting, if any
+func Resolve(layers []Layer, profile string) (Config, map[string]string, error) {
+	c, sources := Merge(layers)
+	if profile != "" {
+		c.Profile = profile
+		sources[KEY_PROFILE] = "flag"
+	}
+	if c.Profile == "" {
+		return c, sources, nil
+	}
+	p, ok := c.Profiles[c.Profile]
+	if !ok {
+		if len(c.Profiles) == 0 {
+			return c, sources, fmt.Errorf("Unknown profile %s, there are no profiles in the config", c.Profile)
+		}
+		return c, sources, fmt.Errorf("Unknown profile %s, use one of %s", c.Profile, strings.Join(c.ProfileNames(), ", "))
+	}
+	for _, k := range keys {
+		if value := *k.field(&p); value != "" && sources[k.name] != "env" {
+			*k.field(&c) = value
+			sources[k.name] = "profile"
+		}
+	}
+	return c, sources, nil
+}
+
+// The combined config from every layer and the given profile, see Resolve, and the warnings of the layers.
+// Flags are applied on top by the caller
+func Load(profile string) (Config, []string, error) {
+	layers, err := Layers()
+	if err != nil {
+		return Config{}, nil, err
+	}
+	warnings := []string{}
+	for _, l := range layers {
+		warnings = append(warnings, l.Warnings...)
+	}
+	c, _, err := Resolve(layers, profile)
+	return c, warnings, err
+}
+
+// Where the session is kept. Each profile has its own, unless the session setting says otherwise.
+// The session is tied to the host, so it is never sent to another one
+func (c Config) SessionStorage() (session.HostStorage, error) {
+	var store session.FileStorage
+	var err error
+	switch {
+	case c.Session != "":
+		store, err = session.PathStorage(c.Session)
+	case c.Profile != "":
+		store, err = session.ProfileStorage(c.Profile)
+	default:
+		store, err = session.DefaultStorage()
+	}
+	return session.HostStorage{Store: store, Host: c.Host}, err
+}
+
+// TLS settings for talking to SSI. Nil when nothing is set, which keeps the defaults
+func (c Config) TLSConfig() (*tls.Config, error) {
+	if c.TLS == (TLS{}) {
+		return nil, nil
+	}
+	config := &tls.Config{}
+	if c.TLS.CA != "" {
+		pem, err := os.ReadFile(c.TLS.CA)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read certificate authorities :: %+v", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("Could not find any certificates in %s", c.TLS.CA)
+		}
+	}
+	if c.TLS.Cert != "" || c.TLS.Key != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLS.Cert, c.TLS.Key)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read client certificate :: %+v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	config.InsecureSkipVerify, _ = strconv.ParseBool(c.TLS.Insecure)
+	return config, nil
+}
+
+// Options for clients talking to SSI: the request timeout and TLS settings
+func (c Config) ClientOptions() ([]func(*client.Client), error) {
+	tlsConfig, err := c.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	return []func(*client.Client){
+		client.WithTimeout(c.RequestTimeout()),
+		client.WithTLS(tlsConfig),
+	}, nil
+}
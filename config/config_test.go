@@ -0,0 +1,224 @@
+package config
+
+import (
+	"compass/scope"
+	"compass/session"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Point every layer at temporary files. Returns the directory of the project
+func isolate(t *testing.T) string {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("HOME", dir)
+	for _, k := range keys {
+		t.Setenv(k.env, "")
+	}
+	old := SystemPath
+	SystemPath = filepath.Join(dir, "etc", "config.yaml")
+	t.Cleanup(func() { SystemPath = old })
+
+	project := filepath.Join(dir, "project", "sub")
+	os.MkdirAll(project, 0700)
+	wd, _ := os.Getwd()
+	os.Chdir(project)
+	t.Cleanup(func() { os.Chdir(wd) })
+	return filepath.Dir(project)
+}
+
+func write(t *testing.T, path string, content string) {
+	os.MkdirAll(filepath.Dir(path), 0700)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("unable to write config: %v", err)
+	}
+}
+
+func TestLoad_Layers(t *testing.T) {
+	project := isolate(t)
+	write(t, SystemPath, "host: https://system\nvalidity: 7d\ntheme: dark\n")
+	user, _ := UserPath()
+	write(t, user, "host: https://user\noutput:\n  dir: ./tokens\n")
+	write(t, filepath.Join(project, ".compassrc"), "output:\n  format: env\ntimeout:\n  request: 10s\n")
+	t.Setenv("COMPASS_THEME", "plain")
+
+	layers, err := Layers()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	c, sources := Merge(layers)
+	expected := map[string][2]string{
+		KEY_HOST:            {"https://user", "user"},
+		KEY_OUTPUT_DIR:      {"./tokens", "user"},
+		KEY_OUTPUT_FORMAT:   {"env", "project"},
+		KEY_VALIDITY:        {"7d", "system"},
+		KEY_THEME:           {"plain", "env"},
+		KEY_REQUEST_TIMEOUT: {"10s", "project"},
+	}
+	for name, e := range expected {
+		if value, _ := c.Get(name); value != e[0] || sources[name] != e[1] {
+			t.Errorf("%s: expected %s from %s, got %s from %s", name, e[0], e[1], value, sources[name])
+		}
+	}
+	if c.ValidityOr(time.Hour) != 7*24*time.Hour || c.RequestTimeout() != 10*time.Second || c.SessionTimeoutOr(time.Minute) != time.Minute {
+		t.Errorf("unexpected durations in %+v", c)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	project := isolate(t)
+	write(t, filepath.Join(project, ".compassrc"), "output:\n  format: yaml\n")
+	if _, _, err := Load(""); err == nil || !strings.Contains(err.Error(), "output.format") {
+		t.Errorf("expected error for an unknown format, got %v", err)
+	}
+
+	os.Remove(filepath.Join(project, ".compassrc"))
+	t.Setenv("COMPASS_VALIDITY", "soon")
+	if _, _, err := Load(""); err == nil || !strings.Contains(err.Error(), "COMPASS_VALIDITY") {
+		t.Errorf("expected error for an invalid validity, got %v", err)
+	}
+}
+
+func TestLoad_ProjectUserOnly(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{"host: https://elsewhere\ntheme: dark\n", "host can only"},
+		{"session: /tmp/session.json\ntheme: dark\n", "session can only"},
+		{"tls:\n  insecure: \"true\"\ntheme: dark\n", "tls.insecure can only"},
+		{"theme: dark\nprofiles:\n  prod:\n    tls:\n      ca: ./ca.pem\n", "tls.ca in profile prod can only"},
+	}
+	for _, test := range tests {
+		project := isolate(t)
+		user, _ := UserPath()
+		write(t, user, "host: https://user\nprofiles:\n  prod:\n    validity: 7d\n")
+		write(t, filepath.Join(project, ".compassrc"), test.content)
+		c, warnings, err := Load("prod")
+		if err != nil {
+			t.Errorf("%q: expected no error, got %v", test.content, err)
+			continue
+		}
+		if len(warnings) != 1 || !strings.Contains(warnings[0], test.expected) {
+			t.Errorf("%q: expected a warning containing %q, got %q", test.content, test.expected, warnings)
+		}
+		if c.Host != "https://user" || c.Session != "" || c.TLS != (TLS{}) || c.Theme != "dark" {
+			t.Errorf("%q: expected the key to be ignored and the rest kept, got %+v", test.content, c)
+		}
+	}
+}
+
+func TestConfig_SetAndSave(t *testing.T) {
+	t.Cleanup(scope.RegisterClient(scope.ClientInfo{Client: "Other", Options: scope.S_ALL}))
+	c := Config{}
+	if err := c.Set(KEY_CLIENTS, "SynkzoneSSI,Other"); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := c.Set(KEY_THEME, "neon"); err == nil {
+		t.Error("expected error for an unknown theme")
+	}
+	if err := c.Set("nope", "x"); err == nil {
+		t.Error("expected error for an unknown key")
+	}
+
+	path := filepath.Join(t.TempDir(), "compass", "config.yaml")
+	if err := c.Save(path); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "clients: SynkzoneSSI,Other\n" {
+		t.Errorf("expected only the set key, got %q", data)
+	}
+	loaded, err := LoadFile(path)
+	if err != nil || loaded.Clients != "SynkzoneSSI,Other" {
+		t.Errorf("expected the saved config, got %+v, %v", loaded, err)
+	}
+
+	c.Set(KEY_CLIENTS, "")
+	if value, _ := c.Get(KEY_CLIENTS); value != "" {
+		t.Errorf("expected an empty value to unset the key, got %s", value)
+	}
+}
+
+func TestResolve_Profiles(t *testing.T) {
+	t.Cleanup(scope.RegisterClient(scope.ClientInfo{Client: "Other", Options: scope.S_ALL}))
+	project := isolate(t)
+	user, _ := UserPath()
+	write(t, user, `host: https://default
+validity: 7d
+profile: staging
+profiles:
+  staging:
+    host: https://staging
+  prod:
+    host: https://prod
+    validity: 1d
+    tls:
+      insecure: "true"
+`)
+	write(t, filepath.Join(project, ".compassrc"), "profiles:\n  prod:\n    clients: Other\n")
+
+	tests := []struct {
+		name     string
+		profile  string
+		env      map[string]string
+		expected map[string]string
+	}{
+		{"default profile", "", nil, map[string]string{KEY_HOST: "https://staging", KEY_VALIDITY: "7d", KEY_PROFILE: "staging"}},
+		{"profile asked for", "prod", nil, map[string]string{KEY_HOST: "https://prod", KEY_VALIDITY: "1d", KEY_CLIENTS: "Other", KEY_TLS_INSECURE: "true"}},
+		{"profile from env", "", map[string]string{"COMPASS_PROFILE": "prod"}, map[string]string{KEY_HOST: "https://prod", KEY_PROFILE: "prod"}},
+		{"env wins over profile", "prod", map[string]string{"COMPASS_HOST": "https://env"}, map[string]string{KEY_HOST: "https://env", KEY_VALIDITY: "1d"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+			c, _, err := Load(test.profile)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			for key, expected := range test.expected {
+				if value, _ := c.Get(key); value != expected {
+					t.Errorf("%s: expected %s, got %s", key, expected, value)
+				}
+			}
+		})
+	}
+
+	if _, _, err := Load("customer"); err == nil || !strings.Contains(err.Error(), "prod, staging") {
+		t.Errorf("expected error listing the profiles, got %v", err)
+	}
+}
+
+func TestConfig_SessionStorage(t *testing.T) {
+	isolate(t)
+	def, _ := Config{}.SessionStorage()
+	prod, _ := Config{Profile: "prod"}.SessionStorage()
+	staging, _ := Config{Profile: "staging"}.SessionStorage()
+	if def == prod || prod == staging {
+		t.Errorf("expected a session file per profile, got %s, %s and %s", def, prod, staging)
+	}
+	path := filepath.Join(t.TempDir(), "session.json")
+	if s, _ := (Config{Profile: "prod", Session: path}).SessionStorage(); s.Store != session.FileStorage(path) {
+		t.Errorf("expected the session setting to win, got %s", s.Store)
+	}
+	if s, _ := (Config{Host: "https://staging.example.com", Profile: "prod"}).SessionStorage(); s.Host != "https://staging.example.com" {
+		t.Errorf("expected the session to be tied to the host, got %s", s.Host)
+	}
+}
+
+func TestLoadFile_InvalidProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write(t, path, "profiles:\n  prod:\n    validity: soon\n")
+	if _, err := LoadFile(path); err == nil || !strings.Contains(err.Error(), "profile prod") {
+		t.Errorf("expected error naming the profile, got %v", err)
+	}
+	write(t, path, "profiles:\n  prod:\n    profile: staging\n")
+	if _, err := LoadFile(path); err == nil {
+		t.Error("expected error for a profile within a profile")
+	}
+}
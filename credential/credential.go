@@ -0,0 +1,199 @@
+package credential
+
+import (
+	"bufio"
+	"compass/fsutil"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The key=value attributes of the git credential helper protocol, see git-credential(1)
+type Attributes map[string]string
+
+// Read attributes until an empty line or the end of input
+func Parse(r io.Reader) (Attributes, error) {
+	attrs := Attributes{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return attrs, fmt.Errorf("Invalid credential line %q", line)
+		}
+		attrs[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return attrs, fmt.Errorf("Could not read credential request :: %+v", err)
+	}
+	return attrs, nil
+}
+
+// The attributes git is told about, in the order they are written
+var answerKeys = []string{"protocol", "host", "path", "username", "password", "password_expiry_utc"}
+
+// Write the attributes git is told about
+func (a Attributes) Write(w io.Writer) error {
+	for _, key := range answerKeys {
+		if value, ok := a[key]; ok {
+			if _, err := fmt.Fprintf(w, "%s=%s\n", key, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// How tokens are minted for a git host
+type Host struct {
+	// The git host, with the port if git is given one, e.g. git.example.com:8443
+	Host string `yaml:"host"`
+	// The SSI instance to mint tokens on. Defaults to the one compass was started with
+	SSI string `yaml:"ssi,omitempty"`
+	// A token in the manifest to use as a template
+	Template string `yaml:"template,omitempty"`
+	// The manifest templates are read from
+	Manifest string `yaml:"manifest,omitempty"`
+	// A scope in text form, instead of a template
+	Scope string `yaml:"scope,omitempty"`
+	// How long minted tokens are valid, e.g. 1h or 1d
+	Validity string `yaml:"validity,omitempty"`
+	// The user name git sends. Defaults to the token id
+	Username string `yaml:"username,omitempty"`
+}
+
+// Which git hosts compass mints tokens for
+type Config struct {
+	Hosts []Host `yaml:"hosts"`
+}
+
+// The config in the users config directory, e.g. ~/.config/compass/git-credentials.yaml
+func DefaultConfigPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "git-credentials.yaml"), nil
+}
+
+func LoadConfig(path string) (Config, error) {
+	c := Config{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c, fmt.Errorf("Could not read credential config :: %+v", err)
+	}
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("Could not parse credential config %s :: %+v", path, err)
+	}
+	for _, h := range c.Hosts {
+		if (h.Template == "") == (h.Scope == "") {
+			return c, fmt.Errorf("Host %s needs either a template or a scope", h.Host)
+		}
+	}
+	return c, nil
+}
+
+// The config for a git host
+func (c Config) Find(host string) (Host, bool) {
+	for _, h := range c.Hosts {
+		if strings.EqualFold(h.Host, host) {
+			return h, true
+		}
+	}
+	return Host{}, false
+}
+
+// A token handed to git
+type Entry struct {
+	Username string    `json:"username"`
+	Token    string    `json:"token"`
+	Id       string    `json:"id"`
+	Expires  time.Time `json:"expires"`
+}
+
+// Tokens handed to git, by host, kept in a json file only the user can read
+type Cache struct {
+	path    string
+	Entries map[string]Entry `json:"entries"`
+}
+
+// The cache in the users config directory, e.g. ~/.config/compass/git-tokens.json
+func DefaultCachePath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "git-tokens.json"), nil
+}
+
+// Load the cache at path. A missing file is an empty cache
+func LoadCache(path string) (*Cache, error) {
+	c := &Cache{path: path, Entries: map[string]Entry{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, fmt.Errorf("Could not read credential cache :: %+v", err)
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return c, fmt.Errorf("Could not read credential cache :: %+v", err)
+	}
+	if c.Entries == nil {
+		c.Entries = map[string]Entry{}
+	}
+	return c, nil
+}
+
+func (c *Cache) Save() error {
+	data, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return fmt.Errorf("Could not write credential cache :: %+v", err)
+	}
+	if err := fsutil.WriteFileAtomic(c.path, data); err != nil {
+		return fmt.Errorf("Could not write credential cache :: %+v", err)
+	}
+	return nil
+}
+
+// The token for host, unless it expires within margin of now
+func (c *Cache) Get(host string, now time.Time, margin time.Duration) (Entry, bool) {
+	e, ok := c.Entries[host]
+	if !ok || !e.Expires.After(now.Add(margin)) {
+		return Entry{}, false
+	}
+	return e, true
+}
+
+func (c *Cache) Put(host string, e Entry) {
+	c.Entries[host] = e
+}
+
+// Remove the token for host. Returns the removed entry, if there was one
+func (c *Cache) Remove(host string) (Entry, bool) {
+	e, ok := c.Entries[host]
+	delete(c.Entries, host)
+	return e, ok
+}
+
+func configDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "compass")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
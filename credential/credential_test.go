@@ -0,0 +1,92 @@
+package credential
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func TestParse(t *testing.T) {
+	attrs, err := Parse(strings.NewReader("protocol=https\nhost=git.example.com\npath=team/repo.git\n\nignored=yes\n"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(attrs) != 3 || attrs["host"] != "git.example.com" || attrs["path"] != "team/repo.git" {
+		t.Errorf("unexpected attributes %v", attrs)
+	}
+	if _, err := Parse(strings.NewReader("host\n")); err == nil {
+		t.Error("expected error for a line without =")
+	}
+}
+
+func TestAttributes_Write(t *testing.T) {
+	buf := &bytes.Buffer{}
+	Attributes{"password": "secret", "username": "id", "unknown": "x", "password_expiry_utc": "1717243200"}.Write(buf)
+	expected := "username=id\npassword=secret\npassword_expiry_utc=1717243200\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "git-credentials.yaml")
+	os.WriteFile(path, []byte(`
+hosts:
+  - host: git.example.com
+    scope: "zone:Repos get,list"
+    validity: 1h
+  - host: Other.example.com:8443
+    template: git
+    manifest: compass.yaml
+`), 0600)
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if h, ok := c.Find("other.example.com:8443"); !ok || h.Template != "git" {
+		t.Errorf("expected the second host, got %+v, %v", h, ok)
+	}
+	if _, ok := c.Find("nope.example.com"); ok {
+		t.Error("expected no config for an unknown host")
+	}
+
+	os.WriteFile(path, []byte("hosts:\n  - host: git.example.com\n"), 0600)
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("expected error for a host without template or scope")
+	}
+}
+
+func TestCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "git-tokens.json")
+	c, err := LoadCache(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	c.Put("git.example.com", Entry{Token: "secret", Id: "1", Expires: now.Add(time.Hour)})
+	c.Put("old.example.com", Entry{Token: "old", Id: "2", Expires: now.Add(30 * time.Second)})
+	if err := c.Save(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	c, err = LoadCache(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if e, ok := c.Get("git.example.com", now, time.Minute); !ok || e.Token != "secret" {
+		t.Errorf("expected the cached token, got %+v, %v", e, ok)
+	}
+	if _, ok := c.Get("old.example.com", now, time.Minute); ok {
+		t.Error("expected a token that is about to expire to be ignored")
+	}
+	if e, ok := c.Remove("git.example.com"); !ok || e.Id != "1" {
+		t.Errorf("expected the entry to be removed, got %+v, %v", e, ok)
+	}
+	if _, ok := c.Get("git.example.com", now, 0); ok {
+		t.Error("expected no token after removing it")
+	}
+}
@@ -0,0 +1,166 @@
+package crypt
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// The environment variable passphrases are read from
+const PASSPHRASE_ENV = "COMPASS_PASSPHRASE"
+
+// The extension of encrypted files
+const EXTENSION = ".age"
+
+// How expensive it is to derive a key from a passphrase, see age.ScryptRecipient
+var scryptWorkFactor = 18
+
+// A private key that can decrypt files, e.g. an age X25519 identity
+type Identity = age.Identity
+
+// Who can decrypt a token file. Either a passphrase or age public keys, not both
+type Options struct {
+	Passphrase string
+	// age X25519 public keys, age1...
+	Recipients []string
+}
+
+func (o Options) Enabled() bool {
+	return o.Passphrase != "" || len(o.Recipients) > 0
+}
+
+func (o Options) recipients() ([]age.Recipient, error) {
+	if o.Passphrase != "" && len(o.Recipients) > 0 {
+		return nil, fmt.Errorf("Encrypt with either a passphrase or public keys, not both")
+	}
+	if o.Passphrase != "" {
+		r, err := age.NewScryptRecipient(o.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+		r.SetWorkFactor(scryptWorkFactor)
+		return []age.Recipient{r}, nil
+	}
+	recipients := []age.Recipient{}
+	for _, key := range o.Recipients {
+		r, err := age.ParseX25519Recipient(key)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid public key %s :: %+v", key, err)
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, nil
+}
+
+// Encrypt data in the armored age format, so it can be pasted as text
+func Encrypt(data []byte, o Options) ([]byte, error) {
+	recipients, err := o.recipients()
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("No passphrase or public key to encrypt with")
+	}
+
+	buf := bytes.Buffer{}
+	armored := armor.NewWriter(&buf)
+	w, err := age.Encrypt(armored, recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("Could not encrypt :: %+v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("Could not encrypt :: %+v", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("Could not encrypt :: %+v", err)
+	}
+	if err := armored.Close(); err != nil {
+		return nil, fmt.Errorf("Could not encrypt :: %+v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Decrypt armored or binary age data with a passphrase or X25519 identities
+func Decrypt(data []byte, passphrase string, identities []Identity) ([]byte, error) {
+	if passphrase != "" {
+		id, err := age.NewScryptIdentity(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, id)
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("No passphrase or identity to decrypt with")
+	}
+
+	var src io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header)) {
+		src = armor.NewReader(bytes.NewReader(bytes.TrimSpace(data)))
+	}
+	r, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("Could not decrypt :: %+v", err)
+	}
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("Could not decrypt :: %+v", err)
+	}
+	return plain, nil
+}
+
+// Read X25519 identities, AGE-SECRET-KEY-1..., one per line. Comments start with #
+func ParseIdentities(r io.Reader) ([]Identity, error) {
+	identities, err := age.ParseIdentities(r)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read identities :: %+v", err)
+	}
+	return identities, nil
+}
+
+// Read identities from a file
+func LoadIdentities(path string) ([]Identity, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read identities :: %+v", err)
+	}
+	defer file.Close()
+	return ParseIdentities(file)
+}
+
+// Public keys given directly, age1..., or as a file with one key per line
+func ReadRecipients(ref string) ([]string, error) {
+	if strings.HasPrefix(ref, "age1") {
+		return []string{ref}, nil
+	}
+	file, err := os.Open(ref)
+	if err != nil {
+		return nil, fmt.Errorf("%s is neither a public key nor a readable file :: %+v", ref, err)
+	}
+	defer file.Close()
+
+	keys := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	return keys, scanner.Err()
+}
+
+// The passphrase from the environment
+func EnvPassphrase() (string, error) {
+	passphrase := os.Getenv(PASSPHRASE_ENV)
+	if passphrase == "" {
+		return "", fmt.Errorf("Set %s to the passphrase", PASSPHRASE_ENV)
+	}
+	return passphrase, nil
+}
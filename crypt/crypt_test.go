@@ -0,0 +1,86 @@
+package crypt
+
+import (
+	"compass/crypt/crypttest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var plain = []byte(`{"token": "secret"}`)
+
+func init() {
+	// Keep passphrase tests fast
+	scryptWorkFactor = 10
+}
+
+func TestEncrypt_Recipients(t *testing.T) {
+	data, err := Encrypt(plain, Options{Recipients: []string{crypttest.Recipient}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.HasPrefix(string(data), "-----BEGIN AGE ENCRYPTED FILE-----") || strings.Contains(string(data), "secret") {
+		t.Errorf("expected armored ciphertext, got %s", data)
+	}
+
+	identities, err := ParseIdentities(strings.NewReader("# test key\n" + crypttest.Identity + "\n"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	got, err := Decrypt(data, "", identities)
+	if err != nil || string(got) != string(plain) {
+		t.Errorf("expected %s, got %s, %v", plain, got, err)
+	}
+
+	others, _ := ParseIdentities(strings.NewReader(crypttest.OtherIdentity))
+	if _, err := Decrypt(data, "", others); err == nil {
+		t.Error("expected error for the wrong identity")
+	}
+}
+
+func TestEncrypt_Passphrase(t *testing.T) {
+	data, err := Encrypt(plain, Options{Passphrase: "correct horse"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got, err := Decrypt(data, "correct horse", nil); err != nil || string(got) != string(plain) {
+		t.Errorf("expected %s, got %s, %v", plain, got, err)
+	}
+	if _, err := Decrypt(data, "battery staple", nil); err == nil {
+		t.Error("expected error for the wrong passphrase")
+	}
+}
+
+func TestEncrypt_Errors(t *testing.T) {
+	tests := []Options{
+		{},
+		{Passphrase: "pass", Recipients: []string{crypttest.Recipient}},
+		{Recipients: []string{"age1nope"}},
+	}
+	for _, o := range tests {
+		if _, err := Encrypt(plain, o); err == nil {
+			t.Errorf("expected error for %+v", o)
+		}
+	}
+	if _, err := Decrypt([]byte("not encrypted"), "", nil); err == nil {
+		t.Error("expected error without identities")
+	}
+}
+
+func TestReadRecipients(t *testing.T) {
+	if keys, err := ReadRecipients(crypttest.Recipient); err != nil || len(keys) != 1 {
+		t.Errorf("expected the key itself, got %v, %v", keys, err)
+	}
+
+	file := filepath.Join(t.TempDir(), "recipients.txt")
+	os.WriteFile(file, []byte("# team\n"+crypttest.Recipient+"\n\n"+crypttest.OtherRecipient+"\n"), 0600)
+	keys, err := ReadRecipients(file)
+	if err != nil || len(keys) != 2 || keys[1] != crypttest.OtherRecipient {
+		t.Errorf("expected two keys, got %v, %v", keys, err)
+	}
+
+	if _, err := ReadRecipients(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected error for a missing file")
+	}
+}
@@ -0,0 +1,11 @@
+// Age key pairs for tests. Never use them for real tokens
+package crypttest
+
+const (
+	Identity  = "AGE-SECRET-KEY-1XTAW7S55AU2SC4ECDE0E36J6TEPFPFXQT2MN8SKZCPDHR7P33WCSF7V2ZS"
+	Recipient = "age1l55mflhe7l4pa4jl99tzf6nm9cc0jlzkdjgwurghtjlhpg6vsqvqx46fe5"
+
+	// A second key pair that can't decrypt what was encrypted to Recipient
+	OtherIdentity  = "AGE-SECRET-KEY-1V2KCWZVKSR8WGU47SH2LEF5C5XD9JK25VNCLKF7D2Q864E9VQDZQR929P4"
+	OtherRecipient = "age18chhgqux35pljwprug59r7a9hjf6nypt9l5lve5lyapj8xxepgvqe3znhx"
+)
@@ -0,0 +1,73 @@
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Write data to a temporary file next to path and rename it into place, so path is never half written.
+// The file is only readable by the user
+func WriteFileAtomic(path string, data []byte) error {
+	handleErr := func(err error) error {
+		return fmt.Errorf("Could not write %s :: %+v", path, err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return handleErr(err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return handleErr(err)
+	}
+	if err := file.Chmod(0600); err != nil {
+		file.Close()
+		return handleErr(err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return handleErr(err)
+	}
+	if err := file.Close(); err != nil {
+		return handleErr(err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return handleErr(err)
+	}
+	return nil
+}
+
+// How long Lock waits for another process to let go of a file
+var LockTimeout = 5 * time.Second
+
+// A lock file older than this is left over from a process that died, and is taken over
+const staleLock = 30 * time.Second
+
+// Lock path against other compass processes by creating path.lock next to it, for read-modify-write updates.
+// Waits up to LockTimeout while another process holds the lock. Call the returned function to release it
+func Lock(path string) (func(), error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(LockTimeout)
+	for {
+		file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("Could not lock %s :: %+v", path, err)
+		}
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("Could not lock %s, %s is held by another process", path, lockPath)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
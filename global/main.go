@@ -1,9 +1,5 @@
 package global
 
-var (
-	SSIHost string
-)
-
 const (
 	CONFIG_FILE string = "./.compassrc"
 )
@@ -0,0 +1,305 @@
+package inventory
+
+import (
+	"compass/scope"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Status int
+
+const (
+	STATUS_UNKNOWN Status = iota
+	STATUS_VALID
+	STATUS_EXPIRING
+	STATUS_EXPIRED
+)
+
+func (s Status) String() string {
+	switch s {
+	case STATUS_VALID:
+		return "valid"
+	case STATUS_EXPIRING:
+		return "expiring"
+	case STATUS_EXPIRED:
+		return "expired"
+	}
+	return "unknown"
+}
+
+type SortKey string
+
+const (
+	SORT_NAME    SortKey = "name"
+	SORT_CREATED SortKey = "created"
+	SORT_EXPIRES SortKey = "expires"
+)
+
+var SortKeys = []SortKey{SORT_NAME, SORT_CREATED, SORT_EXPIRES}
+
+// How long before expiry a token counts as expiring, unless told otherwise
+const DefaultSoon = 7 * 24 * time.Hour
+
+// Parse a timestamp from SSI
+func ParseTime(value string) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	return t, err == nil
+}
+
+// Parse a duration like time.ParseDuration, with d for days, e.g. 7d or 1d12h
+func ParseDuration(value string) (time.Duration, error) {
+	days, rest, found := strings.Cut(value, "d")
+	if !found {
+		return time.ParseDuration(value)
+	}
+	n, err := strconv.ParseInt(days, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	d := time.Duration(n) * 24 * time.Hour
+	if rest == "" {
+		return d, nil
+	}
+	r, err := time.ParseDuration(rest)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return d + r, nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func Name(t scope.TokenInformation) string {
+	if name := deref(t.TokenName); name != "" {
+		return name
+	}
+	if t.Scope != nil {
+		return t.Scope.Name
+	}
+	return ""
+}
+
+// The id used to manage a token, preferring TokenId over Id
+func Id(t scope.TokenInformation) string {
+	if t.TokenId != nil {
+		return t.TokenId.String()
+	}
+	if t.Id != nil {
+		return t.Id.String()
+	}
+	return ""
+}
+
+func Created(t scope.TokenInformation) (time.Time, bool) {
+	return ParseTime(deref(t.Created))
+}
+
+func Expires(t scope.TokenInformation) (time.Time, bool) {
+	return ParseTime(deref(t.ValidUntil))
+}
+
+// Whether a token has expired, or expires within soon of now
+func StatusOf(t scope.TokenInformation, now time.Time, soon time.Duration) Status {
+	expires, ok := Expires(t)
+	switch {
+	case !ok:
+		return STATUS_UNKNOWN
+	case !expires.After(now):
+		return STATUS_EXPIRED
+	case expires.Before(now.Add(soon)):
+		return STATUS_EXPIRING
+	}
+	return STATUS_VALID
+}
+
+// Sort tokens in place. Tokens without a time sort last
+func Sort(tokens []scope.TokenInformation, key SortKey, reverse bool) {
+	less := func(a, b scope.TokenInformation) bool {
+		switch key {
+		case SORT_CREATED:
+			at, aOk := Created(a)
+			bt, bOk := Created(b)
+			return timeLess(at, aOk, bt, bOk)
+		case SORT_EXPIRES:
+			at, aOk := Expires(a)
+			bt, bOk := Expires(b)
+			return timeLess(at, aOk, bt, bOk)
+		}
+		return strings.ToLower(Name(a)) < strings.ToLower(Name(b))
+	}
+	sort.SliceStable(tokens, func(i, j int) bool {
+		if reverse {
+			return less(tokens[j], tokens[i])
+		}
+		return less(tokens[i], tokens[j])
+	})
+}
+
+func timeLess(a time.Time, aOk bool, b time.Time, bOk bool) bool {
+	if aOk != bOk {
+		return aOk
+	}
+	return a.Before(b)
+}
+
+// Tokens where the name, id, client or scope summary contains the query, ignoring case
+func Filter(tokens []scope.TokenInformation, query string, zones []scope.ZoneData) []scope.TokenInformation {
+	query = strings.ToLower(strings.TrimSpace(query))
+	res := []scope.TokenInformation{}
+	for _, t := range tokens {
+		fields := []string{Name(t), Id(t), deref(t.ClientIdentifier), Summary(t.Scope, zones)}
+		if strings.Contains(strings.ToLower(strings.Join(fields, "\n")), query) {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// A one line summary of a scope, with zone names resolved
+func Summary(s *scope.SPScope, zones []scope.ZoneData) string {
+	if s == nil || len(s.Permissions) == 0 {
+		return ""
+	}
+	return strings.ReplaceAll(scope.FormatScope(s.Permissions, zones), "\n", "; ")
+}
+
+// Format a time for display, or - if it is missing
+func FormatTime(t time.Time, ok bool) string {
+	if !ok {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// Find a token by id or name. Ids are matched first, names must be unique
+func Find(tokens []scope.TokenInformation, ref string) (scope.TokenInformation, error) {
+	for _, t := range tokens {
+		if strings.EqualFold(Id(t), ref) || (t.Id != nil && strings.EqualFold(t.Id.String(), ref)) {
+			return t, nil
+		}
+	}
+	matches := []scope.TokenInformation{}
+	for _, t := range tokens {
+		if Name(t) == ref {
+			matches = append(matches, t)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return scope.TokenInformation{}, fmt.Errorf("no token with id or name %s", ref)
+	case 1:
+		return matches[0], nil
+	}
+	return scope.TokenInformation{}, fmt.Errorf("%d tokens are named %s, use the id", len(matches), ref)
+}
+
+// Validity of a rotated token when the lifetime of the old token is unknown
+const DefaultRotateValidity = 30 * 24 * time.Hour
+
+// How long a token was valid for when it was created
+func Lifetime(t scope.TokenInformation) (time.Duration, bool) {
+	created, cOk := Created(t)
+	expires, eOk := Expires(t)
+	if !cOk || !eOk || !expires.After(created) {
+		return 0, false
+	}
+	return expires.Sub(created), true
+}
+
+// A request for a new token with the same name and scope as t, valid for validity
+func RotateRequest(t scope.TokenInformation, validity time.Duration) (scope.NewTokenRequest, error) {
+	if t.Scope == nil {
+		return scope.NewTokenRequest{}, fmt.Errorf("token %s has no scope to rotate", Name(t))
+	}
+	if validity < time.Second {
+		return scope.NewTokenRequest{}, fmt.Errorf("validity should be at least a second")
+	}
+	name := Name(t)
+	seconds := int64(validity / time.Second)
+	s := *t.Scope
+	if s.Name == "" {
+		s.Name = name
+	}
+	return scope.NewTokenRequest{
+		TokenName: &name,
+		Validity:  &seconds,
+		Scope:     s,
+	}, nil
+}
+
+// Format how long is left of a token, e.g. 3d 4h, or how long ago it expired
+func FormatRemaining(d time.Duration) string {
+	suffix := ""
+	if d < 0 {
+		d, suffix = -d, " ago"
+	}
+	days := d / (24 * time.Hour)
+	hours := (d % (24 * time.Hour)) / time.Hour
+	minutes := (d % time.Hour) / time.Minute
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours) + suffix
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes) + suffix
+	}
+	return fmt.Sprintf("%dm", minutes) + suffix
+}
+
+// Describe the limitations of a scope, one line each, sorted by kind
+func Limitations(s scope.SPScope) []string {
+	keys := make([]string, 0, len(s.Limitations))
+	for k := range s.Limitations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	lines := []string{}
+	for _, key := range keys {
+		lines = append(lines, describeLimitation(s, key))
+	}
+	return lines
+}
+
+func describeLimitation(s scope.SPScope, key string) string {
+	switch key {
+	case scope.IPRangeLimitation{}.LimitationKey():
+		l := scope.IPRangeLimitation{}
+		if _, err := s.GetLimitation(&l); err == nil {
+			return "IP ranges: " + strings.Join(l.Ranges, ", ")
+		}
+	case scope.TimeWindowLimitation{}.LimitationKey():
+		l := scope.TimeWindowLimitation{}
+		if _, err := s.GetLimitation(&l); err == nil {
+			return strings.TrimSpace("Time window: " + l.Start + "-" + l.End + " " + l.Timezone)
+		}
+	case scope.MaxUsesLimitation{}.LimitationKey():
+		l := scope.MaxUsesLimitation{}
+		if _, err := s.GetLimitation(&l); err == nil {
+			return fmt.Sprintf("Max uses: %d", l.Uses)
+		}
+	case scope.CollaboratorsLimitation{}.LimitationKey():
+		l := scope.CollaboratorsLimitation{}
+		if _, err := s.GetLimitation(&l); err == nil {
+			names := []string{}
+			for _, m := range l.Members {
+				name := m.DisplayName
+				if name == "" {
+					name = m.MemberId
+				}
+				names = append(names, name)
+			}
+			return "Only together with: " + strings.Join(names, ", ")
+		}
+	}
+	jsonData, _ := json.Marshal(s.Limitations[key])
+	return key + ": " + string(jsonData)
+}
@@ -0,0 +1,221 @@
+package inventory
+
+import (
+	"compass/scope"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var now = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func token(name string, created string, validUntil string) scope.TokenInformation {
+	id := uuid.New()
+	t := scope.TokenInformation{TokenName: &name, TokenId: &id}
+	if created != "" {
+		t.Created = &created
+	}
+	if validUntil != "" {
+		t.ValidUntil = &validUntil
+	}
+	return t
+}
+
+func names(tokens []scope.TokenInformation) []string {
+	res := []string{}
+	for _, t := range tokens {
+		res = append(res, Name(t))
+	}
+	return res
+}
+
+func TestStatusOf(t *testing.T) {
+	tests := []struct {
+		validUntil string
+		expected   Status
+	}{
+		{"", STATUS_UNKNOWN},
+		{"not a time", STATUS_UNKNOWN},
+		{"2024-06-01T11:00:00Z", STATUS_EXPIRED},
+		{"2024-06-01T12:00:00Z", STATUS_EXPIRED},
+		{"2024-06-03T12:00:00Z", STATUS_EXPIRING},
+		{"2024-07-01T12:00:00Z", STATUS_VALID},
+	}
+	for _, tt := range tests {
+		if status := StatusOf(token("a", "", tt.validUntil), now, DefaultSoon); status != tt.expected {
+			t.Errorf("expected %q to be %s, got %s", tt.validUntil, tt.expected, status)
+		}
+	}
+}
+
+func TestSort(t *testing.T) {
+	tokens := []scope.TokenInformation{
+		token("beta", "2024-01-02T00:00:00Z", ""),
+		token("Alpha", "2024-01-03T00:00:00Z", "2024-08-01T00:00:00Z"),
+		token("gamma", "", "2024-07-01T00:00:00Z"),
+	}
+
+	tests := []struct {
+		key      SortKey
+		reverse  bool
+		expected string
+	}{
+		{SORT_NAME, false, "Alpha,beta,gamma"},
+		{SORT_NAME, true, "gamma,beta,Alpha"},
+		{SORT_CREATED, false, "beta,Alpha,gamma"},
+		{SORT_EXPIRES, false, "gamma,Alpha,beta"},
+	}
+	for _, tt := range tests {
+		Sort(tokens, tt.key, tt.reverse)
+		got := ""
+		for i, n := range names(tokens) {
+			if i > 0 {
+				got += ","
+			}
+			got += n
+		}
+		if got != tt.expected {
+			t.Errorf("sorting by %s (reverse %v): expected %s, got %s", tt.key, tt.reverse, tt.expected, got)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	zoneId := uuid.New()
+	withScope := token("backup", "", "")
+	withScope.Scope = &scope.SPScope{Permissions: map[string]scope.Permission{scope.ZoneKey(zoneId): {Get: true}}}
+	tokens := []scope.TokenInformation{withScope, token("audit", "", "")}
+	zones := []scope.ZoneData{{Name: "Finance", Id: zoneId}}
+
+	if res := Filter(tokens, "AUD", zones); len(res) != 1 || Name(res[0]) != "audit" {
+		t.Errorf("expected audit, got %v", names(res))
+	}
+	if res := Filter(tokens, "finance", zones); len(res) != 1 || Name(res[0]) != "backup" {
+		t.Errorf("expected backup through its zone name, got %v", names(res))
+	}
+	if res := Filter(tokens, "", zones); len(res) != 2 {
+		t.Errorf("expected every token for an empty query, got %v", names(res))
+	}
+}
+
+func TestSummary(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	s := &scope.SPScope{Permissions: map[string]scope.Permission{
+		scope.ZoneKey(a): {Get: true, List: true},
+		scope.ZoneKey(b): {All: true},
+	}}
+	summary := Summary(s, []scope.ZoneData{{Name: "Finance", Id: a}, {Name: "HR", Id: b}})
+	if summary != "zone:Finance get,list; zone:HR all" && summary != "zone:HR all; zone:Finance get,list" {
+		t.Errorf("unexpected summary %q", summary)
+	}
+	if Summary(nil, nil) != "" {
+		t.Error("expected empty summary without scope")
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"7d":    7 * 24 * time.Hour,
+		"1d12h": 36 * time.Hour,
+		"90m":   90 * time.Minute,
+	}
+	for input, expected := range tests {
+		d, err := ParseDuration(input)
+		if err != nil || d != expected {
+			t.Errorf("expected %s to be %s, got %s, %v", input, expected, d, err)
+		}
+	}
+	for _, input := range []string{"xd", "1dx", "-1d", "soon"} {
+		if _, err := ParseDuration(input); err == nil {
+			t.Errorf("expected error for %s", input)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	backup := token("backup", "", "")
+	tokens := []scope.TokenInformation{backup, token("audit", "", ""), token("audit", "", "")}
+
+	if found, err := Find(tokens, "backup"); err != nil || Id(found) != Id(backup) {
+		t.Errorf("expected backup by name, got %+v, %v", found, err)
+	}
+	if found, err := Find(tokens, strings.ToUpper(Id(backup))); err != nil || Name(found) != "backup" {
+		t.Errorf("expected backup by id, got %+v, %v", found, err)
+	}
+	if _, err := Find(tokens, "audit"); err == nil {
+		t.Error("expected error for an ambiguous name")
+	}
+	if _, err := Find(tokens, "nope"); err == nil {
+		t.Error("expected error for an unknown token")
+	}
+}
+
+func TestLifetime(t *testing.T) {
+	if d, ok := Lifetime(token("a", "2024-05-01T12:00:00Z", "2024-05-31T12:00:00Z")); !ok || d != 30*24*time.Hour {
+		t.Errorf("expected 30 days, got %s, %v", d, ok)
+	}
+	if _, ok := Lifetime(token("a", "", "2024-05-31T12:00:00Z")); ok {
+		t.Error("expected no lifetime without a creation time")
+	}
+}
+
+func TestRotateRequest(t *testing.T) {
+	backup := token("backup", "", "")
+	if _, err := RotateRequest(backup, time.Hour); err == nil {
+		t.Error("expected error for a token without scope")
+	}
+
+	backup.Scope = &scope.SPScope{
+		Clients:     []scope.Client{scope.ClientSSI},
+		Permissions: map[string]scope.Permission{"Zone=abc": {Get: true}},
+	}
+	req, err := RotateRequest(backup, 36*time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if *req.TokenName != "backup" || req.Scope.Name != "backup" || *req.Validity != 36*60*60 {
+		t.Errorf("unexpected request %+v", req)
+	}
+	if !req.Scope.Permissions["Zone=abc"].Get || len(req.Scope.Clients) != 1 {
+		t.Errorf("expected the scope to be kept, got %+v", req.Scope)
+	}
+	if _, err := RotateRequest(backup, 0); err == nil {
+		t.Error("expected error for a zero validity")
+	}
+}
+
+func TestFormatRemaining(t *testing.T) {
+	tests := map[time.Duration]string{
+		76 * time.Hour:    "3d 4h",
+		90 * time.Minute:  "1h 30m",
+		5 * time.Minute:   "5m",
+		-26 * time.Hour:   "1d 2h ago",
+		-30 * time.Second: "0m ago",
+	}
+	for d, expected := range tests {
+		if got := FormatRemaining(d); got != expected {
+			t.Errorf("expected %s to be %q, got %q", d, expected, got)
+		}
+	}
+}
+
+func TestLimitations(t *testing.T) {
+	s := scope.SPScope{}
+	s.SetLimitation(scope.IPRangeLimitation{Ranges: []string{"10.0.0.0/8", "192.168.1.10"}})
+	s.SetLimitation(scope.MaxUsesLimitation{Uses: 3})
+	s.SetLimitation(scope.NewCollaboratorsLimitation([]scope.ZoneMember{{MemberId: "1", DisplayName: "Ada"}}))
+	s.Limitations["geo"] = "SE"
+
+	expected := []string{
+		"Only together with: Ada",
+		"geo: \"SE\"",
+		"IP ranges: 10.0.0.0/8, 192.168.1.10",
+		"Max uses: 3",
+	}
+	got := Limitations(s)
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
@@ -1,14 +1,24 @@
 package main
 
 import (
-	"compass/client"
-	"compass/global"
+	"bytes"
+	"compass/agent"
+	"compass/api"
+	"compass/app"
+	"compass/cli"
+	"compass/clip"
+	"compass/config"
+	"compass/inventory"
+	"compass/output"
+	"compass/registry"
 	"compass/scope"
 	"compass/session"
+	"compass/views/agentapproval"
 	"compass/views/login"
 	"compass/views/tokencreate"
+	"compass/views/tokenlist"
+	"compass/views/tokenresult"
 	"compass/views/zoneselector"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -18,22 +28,89 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
 )
 
 var SSIHost string
+var Output = output.New()
+var ScopeText string
+var ClientList string
+var ClearClipboard time.Duration
+var Profile string
 
 func init() {
 	flag.StringVar(&SSIHost, "h", "", "Where is SSI?")
+	flag.StringVar(&ClientList, "clients", string(scope.ClientSSI), "Comma separated clients that are selected by default when creating tokens")
+	Output.Flags(flag.CommandLine)
+	flag.StringVar(&ScopeText, "scope", "", "Skip zone selection and use a scope in text form, e.g. \"zone:Finance get,list\"")
+	flag.DurationVar(&ClearClipboard, "clear-clipboard", 0, "Clear a token copied from the result screen after this long, e.g. 30s. Zero keeps it")
+	flag.StringVar(&Profile, "profile", "", "Use the settings of a profile from the config, like $COMPASS_PROFILE")
 }
 
 func main() {
 	flag.Parse()
+	if err := config.RegisterClients(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+	}
+	// Settings from config files and the environment are the defaults, flags override them.
+	// Commands that work offline read the config on their own, and may be what fixes it
+	offline := cli.Offline(flag.Args())
+	cfg, warnings, err := config.Load(Profile)
+	if err != nil && !offline {
+		log.Fatal(err)
+	}
+	if !offline {
+		for _, w := range warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+		}
+	}
+	cfg = applyConfig(cfg)
+	if _, err := scope.ParseRules(ScopeText); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := scope.ParseClients(ClientList); err != nil {
+		log.Fatal(err)
+	}
+	if !output.ValidFormat(Output.Format) {
+		log.Fatalf("Unknown output format %s, use one of %s", Output.Format, strings.Join(output.Formats(), ", "))
+	}
+	Output.Host = SSIHost
+	if flag.NArg() > 0 {
+		env := cli.NewEnv(SSIHost)
+		env.Output = Output
+		env.Config = cfg
+		env.Profile = Profile
+		env.Approval = func() (agent.Approver, func() error) {
+			a := agentapproval.New()
+			return a, a.Run
+		}
+		os.Exit(cli.Run(env, flag.Args()))
+	}
 	if SSIHost == "" {
-		log.Fatal("No host provided")
+		log.Fatal("No host provided, use -h or compass config set host <url>")
+	}
+	applyTheme(cfg.Theme)
+
+	// Tokens for stdout are held back until the TUI is done, which then draws on stderr instead
+	tokens := &bytes.Buffer{}
+	writer := Output
+	writer.Stdout = tokens
+	options := []tea.ProgramOption{}
+	if writer.ToStdout() {
+		options = append(options, tea.WithOutput(os.Stderr))
+	}
+	model, err := newModel(cfg, writer)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p := tea.NewProgram(model, options...)
+	_, err = p.Run()
+	// Don't leave a copied token on the clipboard when quitting before it is cleared
+	if err := clip.ClearPending(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
 	}
-	global.SSIHost = SSIHost
-	p := tea.NewProgram(initialModel())
-	if _, err := p.Run(); err != nil {
+	os.Stdout.Write(tokens.Bytes())
+	if err != nil {
 		fmt.Printf("We ran into an error: %v", err)
 		os.Exit(1)
 	}
@@ -43,12 +120,27 @@ type Model struct {
 	loginView    tea.Model
 	resourceView tea.Model
 	tokenForm    tea.Model
+	tokenList    tea.Model
+	resultView   tea.Model
 
 	zonesList []scope.ZoneData
-	client    *client.Client
-	session   session.SessionInterface
+	// The settings, client and session shared with the views
+	ctx *app.Context
+
+	mode     int
+	prevMode int
+
+	// Where created tokens are written
+	writer output.Writer
+
+	// Warns about tracked tokens that expire soon
+	banner string
 
-	mode int
+	// The scope of the token being cloned, if any
+	source *tokenlist.Clone
+
+	// Every profile in the config, to switch between in the header
+	profiles []string
 
 	output []string
 }
@@ -57,20 +149,107 @@ const (
 	ModeLogin = iota
 	ModeSelectResources
 	ModeCreateToken
+	ModeTokenList
+	ModeResult
 )
 
-func initialModel() tea.Model {
-	c := client.NewClient(SSIHost,
-		client.WithHeader("Content-Type", "application/json"),
-	)
+// Use config values for flags that aren't given. Returns the config with the flag values in it
+func applyConfig(cfg config.Config) config.Config {
+	given := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	settings := []struct {
+		flag    string
+		value   *string
+		setting *string
+	}{
+		{"h", &SSIHost, &cfg.Host},
+		{"clients", &ClientList, &cfg.Clients},
+		{"o", &Output.Path, &cfg.Output.Dir},
+		{"format", &Output.Format, &cfg.Output.Format},
+	}
+	for _, s := range settings {
+		if !given[s.flag] && *s.setting != "" {
+			*s.value = *s.setting
+		}
+		*s.setting = *s.value
+	}
+	return cfg
+}
+
+func applyTheme(theme string) {
+	switch theme {
+	case config.THEME_DARK:
+		lipgloss.SetHasDarkBackground(true)
+	case config.THEME_LIGHT:
+		lipgloss.SetHasDarkBackground(false)
+	case config.THEME_PLAIN:
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+}
+
+func newModel(cfg config.Config, writer output.Writer) (Model, error) {
+	// Every token created in the TUI would overwrite the one before it
+	if !writer.ToStdout() && !writer.ToDir() {
+		return Model{}, fmt.Errorf("Output %s is a file, use a directory or - to create tokens in the TUI", writer.Path)
+	}
+	ctx, err := app.New(cfg)
+	if err != nil {
+		return Model{}, err
+	}
 	return Model{
-		client:    c,
-		loginView: login.New(),
+		ctx:       ctx,
+		writer:    writer,
+		banner:    expiryBanner(),
+		loginView: login.New(ctx),
 		zonesList: []scope.ZoneData{},
 		mode:      ModeLogin,
+		profiles:  cfg.ProfileNames(),
+	}, nil
+}
+
+// Sent once another profile is in use, so the login view gets going
+type profileSwitched string
+
+// Start over with the settings of another profile. Flags only apply to the profile compass started with
+func switchProfile(m Model, name string) (tea.Model, tea.Cmd) {
+	cfg, _, err := config.Load(name)
+	if err == nil && cfg.Host == "" {
+		err = fmt.Errorf("Profile %s has no host, set one with compass config set -profile %s host <url>", name, name)
+	}
+	if err != nil {
+		m.output = append(m.output, "Error: \n"+err.Error())
+		return m, nil
+	}
+	writer := m.writer
+	writer.Host = cfg.Host
+	if cfg.Output.Dir != "" {
+		writer.Path = cfg.Output.Dir
+	}
+	if cfg.Output.Format != "" {
+		writer.Format = cfg.Output.Format
+	}
+	next, err := newModel(cfg, writer)
+	if err != nil {
+		m.output = append(m.output, "Error: \n"+err.Error())
+		return m, nil
+	}
+	return next, func() tea.Msg {
+		return profileSwitched(name)
 	}
 }
 
+// The profile after current, wrapping around
+func nextProfile(profiles []string, current string) string {
+	for i, name := range profiles {
+		if name == current {
+			return profiles[(i+1)%len(profiles)]
+		}
+	}
+	return profiles[0]
+}
+
 func (m Model) Init() tea.Cmd {
 	return nil
 }
@@ -83,53 +262,88 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch msg.String() {
 		case "ctrl+q", "ctrl+c":
 			return m, tea.Quit
+		case "ctrl+t":
+			if m.mode == ModeTokenList {
+				m.mode = m.prevMode
+				return m, nil
+			}
+			if m.mode != ModeLogin {
+				m.prevMode = m.mode
+				m.mode = ModeTokenList
+				m.tokenList = tokenlist.New(m.ctx)
+				return m, m.tokenList.Init()
+			}
+		case "ctrl+p":
+			if len(m.profiles) > 1 {
+				return switchProfile(m, nextProfile(m.profiles, m.ctx.Config.Profile))
+			}
 		}
 	}
 
 	switch msg := msg.(type) {
-	case scope.TokenResult:
-		fileName := "token~"+time.Now().Format(time.RFC3339)+".json"
-		file, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY, 0600)
+	case tokencreate.Created:
+		m.source = nil
+		fileName, err := m.writer.WriteAs(time.Now().Format(time.RFC3339), msg.Name, msg.Result)
+		if m.mode == ModeCreateToken {
+			m.resultView = resultView(m, msg, fileName, err)
+			m.mode = ModeResult
+			if err == nil {
+				if err := registry.Record(registry.NewEntry(msg.Name, msg.Result, fileName)); err != nil {
+					m.output = append(m.output, fmt.Sprintf("Error: %+v", err))
+				}
+			}
+			return m, nil
+		}
 		if err != nil {
 			m.output = append(m.output, fmt.Sprintf("Error: %+v", err))
+			break
 		}
-		jsonData, jerr := json.MarshalIndent(msg, "", "\t")
-		if jerr != nil {
-			m.output = append(m.output, fmt.Sprintf("Error: %+v", jerr))
-		}
-		file.Write(jsonData)
 		m.output = append(m.output, fmt.Sprintf("Wrote token to %s", fileName))
+		if err := registry.Record(registry.NewEntry(msg.Name, msg.Result, fileName)); err != nil {
+			m.output = append(m.output, fmt.Sprintf("Error: %+v", err))
+		}
+
+	case tokenresult.Done:
+		return m, createZonesView(m)
+
+	case tokenlist.Clone:
+		zones, err := api.GetZones(m.ctx.Client)
+		if err != nil {
+			m.output = append(m.output, "Error: \n"+err.Error())
+			return m, nil
+		}
+		m.zonesList = zones
+		m.source = &msg
+		m.resourceView = zoneselector.New(m.ctx, zones, zoneselector.WithPermissions(msg.Scope.Permissions))
+		m.mode = ModeSelectResources
+		return m, nil
 
 	case zoneselector.PermissionCollection:
-		m.tokenForm = tokencreate.New(m.client, msg)
+		options := []func(*tokencreate.Model){}
+		if m.source != nil {
+			options = append(options,
+				tokencreate.WithSource(m.source.Scope),
+				tokencreate.WithZones(m.zonesList),
+			)
+			if m.source.Validity > 0 {
+				options = append(options, tokencreate.WithValidity(int64(m.source.Validity/time.Second)))
+			}
+		}
+		m.tokenForm = tokencreate.New(m.ctx, msg, options...)
 		m.mode = ModeCreateToken
 
 	case zoneselector.Model:
 		m.resourceView = msg
 		m.mode = ModeSelectResources
 
-	case *client.Client:
-		m.client = msg
-
 	case *session.Session:
-		m.session = msg
-		m.client.Config(
-			client.WithInterceptor(func(c *client.Client) {
-				c.SetHeader("Authorization", m.session.GetToken())
-			}),
-			client.WithStatusHandler(401, func() {
-				fmt.Print("Session was invalid. Next call will prompt a sign in")
-				defer m.session.Save()
-				m.session.SetToken("")
-			}),
-		)
 		return m, createZonesView(m)
 
 	case error:
 		m.output = append(m.output, "Error: \n"+msg.Error())
 	}
 
-	if m.session == nil || m.session.GetToken() == "" {
+	if m.ctx.Session.GetToken() == "" {
 		m.mode = ModeLogin
 	}
 
@@ -153,6 +367,26 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, cmd
 	}
 
+	if m.mode == ModeTokenList {
+		newTokenList, cmd := m.tokenList.Update(msg)
+		tokenListModel, ok := newTokenList.(tokenlist.Model)
+		if !ok {
+			panic("Could not assert token list view")
+		}
+		m.tokenList = tokenListModel
+		return m, cmd
+	}
+
+	if m.mode == ModeResult {
+		newResultView, cmd := m.resultView.Update(msg)
+		resultViewModel, ok := newResultView.(tokenresult.Model)
+		if !ok {
+			panic("Could not assert result view")
+		}
+		m.resultView = resultViewModel
+		return m, cmd
+	}
+
 	if m.mode == ModeCreateToken {
 		newTokenForm, cmd := m.tokenForm.Update(msg)
 		tokenFormModel, ok := newTokenForm.(tokencreate.Model)
@@ -166,7 +400,70 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+var bannerStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("214")).MarginBottom(1)
+
+var headerStyle = lipgloss.NewStyle().MarginBottom(1)
+var activeProfileStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("63"))
+var profileStyle = lipgloss.NewStyle().Faint(true)
+
+// The profiles in the config with the one in use highlighted. Empty when there are none
+func (m Model) header() string {
+	if len(m.profiles) == 0 {
+		return ""
+	}
+	names := []string{}
+	for _, name := range m.profiles {
+		if name == m.ctx.Config.Profile {
+			names = append(names, activeProfileStyle.Render("["+name+"]"))
+		} else {
+			names = append(names, profileStyle.Render(name))
+		}
+	}
+	header := "Profile: " + strings.Join(names, " ")
+	if len(m.profiles) > 1 {
+		header += profileStyle.Render("  ctrl+p to switch")
+	}
+	return headerStyle.Render(header)
+}
+
+// Tell the user about tokens created with compass that have expired or expire soon
+func expiryBanner() string {
+	r, err := registry.LoadDefault()
+	if err != nil {
+		return ""
+	}
+	now := time.Now()
+	expiring := r.Expiring(now, inventory.DefaultSoon)
+	if len(expiring) == 0 {
+		return ""
+	}
+	names := []string{}
+	for _, e := range expiring {
+		name := e.Name
+		if name == "" {
+			name = e.Id
+		}
+		left := e.Expires.Sub(now)
+		if left > 0 {
+			names = append(names, fmt.Sprintf("%s (%s left)", name, inventory.FormatRemaining(left)))
+		} else {
+			names = append(names, fmt.Sprintf("%s (expired)", name))
+		}
+	}
+	return bannerStyle.Render(fmt.Sprintf("%d token(s) expire soon: %s", len(expiring), strings.Join(names, ", ")))
+}
+
 func (m Model) View() string {
+	parts := []string{}
+	for _, part := range []string{m.header(), m.banner, m.view()} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return lipgloss.JoinVertical(lipgloss.Top, parts...)
+}
+
+func (m Model) view() string {
 	if m.mode == ModeLogin {
 		return lipgloss.JoinVertical(
 			lipgloss.Top,
@@ -188,26 +485,64 @@ func (m Model) View() string {
 			strings.Join(m.output, ",\n"),
 		)
 	}
+	if m.mode == ModeTokenList {
+		return lipgloss.JoinVertical(
+			lipgloss.Top,
+			m.tokenList.View(),
+			strings.Join(m.output, ",\n"),
+		)
+	}
+	if m.mode == ModeResult {
+		return lipgloss.JoinVertical(
+			lipgloss.Top,
+			m.resultView.View(),
+			strings.Join(m.output, ",\n"),
+		)
+	}
 	return "Loading..."
 }
 
-func getZones(c client.ClientInterface) ([]scope.ZoneData, error) {
-	zones := []scope.ZoneData{}
-	err := c.Get("/zones", &zones)
+// The result screen for a token created in the form
+func resultView(m Model, created tokencreate.Created, where string, err error) tokenresult.Model {
+	// The TUI is drawn on stderr when tokens go to stdout, so that is where the terminal is
+	terminal := os.Stdout
+	if m.writer.ToStdout() {
+		terminal = os.Stderr
+	}
+	options := []func(*tokenresult.Model){
+		tokenresult.WithTerminal(terminal),
+		tokenresult.WithClearAfter(ClearClipboard),
+	}
 	if err != nil {
-		return zones, err
+		options = append(options, tokenresult.WithError(err))
 	}
-
-	return zones, nil
+	return tokenresult.New(created.Name, created.Result, where, options...)
 }
+
 func createZonesView(m Model) tea.Cmd {
-	zones, err := getZones(m.client)
+	zones, err := api.GetZones(m.ctx.Client)
 	if err != nil {
 		return func() tea.Msg {
 			return err
 		}
 	}
-	zonesView := zoneselector.New(zones)
+	zonesView := zoneselector.New(m.ctx, zones)
+	if ScopeText != "" {
+		perms, err := scope.ParseScope(ScopeText, zones)
+		if err != nil {
+			return tea.Sequence(
+				func() tea.Msg {
+					return zonesView
+				},
+				func() tea.Msg {
+					return err
+				},
+			)
+		}
+		return func() tea.Msg {
+			return zoneselector.PermissionCollection(perms)
+		}
+	}
 	return func() tea.Msg {
 		return zonesView
 	}
@@ -0,0 +1,185 @@
+package manifest
+
+import (
+	"compass/scope"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A declarative description of the tokens to create
+type Manifest struct {
+	Tokens []Token `json:"tokens" yaml:"tokens"`
+}
+
+type Token struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Validity in seconds
+	Validity int64 `json:"validity" yaml:"validity"`
+	// Clients the token is issued for. Defaults to SynkzoneSSI
+	Clients []string `json:"clients,omitempty" yaml:"clients,omitempty"`
+	Zones   []Zone   `json:"zones" yaml:"zones"`
+}
+
+// A zone referenced by either name or id, and the permissions to grant in it
+type Zone struct {
+	Name        string   `json:"name,omitempty" yaml:"name,omitempty"`
+	Id          string   `json:"id,omitempty" yaml:"id,omitempty"`
+	Permissions []string `json:"permissions" yaml:"permissions"`
+}
+
+// A token from the manifest, resolved against the zones available in SSI
+type Plan struct {
+	Token   Token
+	Zones   []scope.ZoneData
+	Request scope.NewTokenRequest
+}
+
+// Read a manifest from a file. Files ending in .json are parsed as json, everything else as yaml
+func Load(path string) (Manifest, error) {
+	m := Manifest{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &m)
+	} else {
+		err = yaml.Unmarshal(data, &m)
+	}
+	if err != nil {
+		return m, fmt.Errorf("Could not parse manifest %s :: %+v", path, err)
+	}
+	return m, nil
+}
+
+// Resolve zone references and build a token request for every token in the manifest
+func (m Manifest) Resolve(zones []scope.ZoneData) ([]Plan, error) {
+	plans := []Plan{}
+	errs := []string{}
+	names := map[string]bool{}
+
+	for i, t := range m.Tokens {
+		if t.Name == "" {
+			errs = append(errs, fmt.Sprintf("token %d: missing name", i+1))
+			continue
+		}
+		if names[t.Name] {
+			errs = append(errs, fmt.Sprintf("token %s: duplicate name", t.Name))
+			continue
+		}
+		names[t.Name] = true
+
+		plan, err := t.Resolve(zones)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("token %s: %s", t.Name, err))
+			continue
+		}
+		plans = append(plans, plan)
+	}
+
+	if len(errs) > 0 {
+		return plans, fmt.Errorf("Invalid manifest ::\n%s", strings.Join(errs, "\n"))
+	}
+	return plans, nil
+}
+
+// Find the token named name, e.g. to use it as a template
+func (m Manifest) Find(name string) (Token, error) {
+	for _, t := range m.Tokens {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return Token{}, fmt.Errorf("No token named %s in the manifest", name)
+}
+
+// Resolve zone references and build the token request
+func (t Token) Resolve(zones []scope.ZoneData) (Plan, error) {
+	plan := Plan{Token: t}
+	if t.Validity <= 0 {
+		return plan, fmt.Errorf("validity must be a positive number of seconds")
+	}
+	if len(t.Zones) == 0 {
+		return plan, fmt.Errorf("no zones")
+	}
+
+	s := scope.SPScope{
+		Name:        t.Name,
+		Description: t.Description,
+		Clients:     []scope.Client{scope.ClientSSI},
+		Permissions: map[string]scope.Permission{},
+	}
+	for _, z := range t.Zones {
+		zone, err := z.find(zones)
+		if err != nil {
+			return plan, err
+		}
+		conf := byte(0)
+		for _, p := range z.Permissions {
+			flag, ok := scope.PermissionFlag(strings.ToLower(p))
+			if !ok {
+				return plan, fmt.Errorf("unknown permission %q in zone %s", p, zone.Name)
+			}
+			conf |= flag
+		}
+		if conf == 0 {
+			return plan, fmt.Errorf("no permissions in zone %s", zone.Name)
+		}
+		s.Permissions[scope.ZoneKey(zone.Id)] = scope.CreatePermission(conf)
+		plan.Zones = append(plan.Zones, zone)
+	}
+	if len(t.Clients) > 0 {
+		clients, err := scope.ParseClients(strings.Join(t.Clients, ","))
+		if err != nil {
+			return plan, err
+		}
+		s.Clients = clients
+	}
+	if err := s.ValidateClients(); err != nil {
+		return plan, err
+	}
+
+	name := t.Name
+	validity := t.Validity
+	plan.Request = scope.NewTokenRequest{
+		Scope:     s,
+		TokenName: &name,
+		Validity:  &validity,
+	}
+	return plan, nil
+}
+
+func (z Zone) find(zones []scope.ZoneData) (scope.ZoneData, error) {
+	if z.Id != "" {
+		for _, zone := range zones {
+			if strings.EqualFold(zone.Id.String(), z.Id) {
+				return zone, nil
+			}
+		}
+		return scope.ZoneData{}, fmt.Errorf("no zone with id %s", z.Id)
+	}
+	if z.Name == "" {
+		return scope.ZoneData{}, fmt.Errorf("zone without name or id")
+	}
+
+	matches := []scope.ZoneData{}
+	for _, zone := range zones {
+		if zone.Name == z.Name {
+			matches = append(matches, zone)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return scope.ZoneData{}, fmt.Errorf("no zone named %s", z.Name)
+	case 1:
+		return matches[0], nil
+	default:
+		return scope.ZoneData{}, fmt.Errorf("zone name %s is ambiguous, use its id", z.Name)
+	}
+}
@@ -0,0 +1,178 @@
+package manifest
+
+import (
+	"compass/scope"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+var (
+	financeId = uuid.MustParse("7c9e6679-7425-40de-944b-e07fc1f90ae7")
+	hrId      = uuid.MustParse("4f1c2a8e-3b7d-4e5f-9a6b-1c2d3e4f5a6b")
+	testZones = []scope.ZoneData{
+		{Name: "Finance", Id: financeId},
+		{Name: "HR", Id: hrId},
+	}
+)
+
+func writeTemp(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("unable to write manifest: %v", err)
+	}
+	return path
+}
+
+func TestLoad_Yaml(t *testing.T) {
+	path := writeTemp(t, "manifest.yaml", `
+tokens:
+  - name: backup
+    description: Nightly backup
+    validity: 3600
+    zones:
+      - name: Finance
+        permissions: [get, list]
+`)
+	m, err := Load(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(m.Tokens) != 1 || m.Tokens[0].Name != "backup" || m.Tokens[0].Validity != 3600 {
+		t.Errorf("unexpected manifest %+v", m)
+	}
+	if len(m.Tokens[0].Zones) != 1 || m.Tokens[0].Zones[0].Permissions[1] != "list" {
+		t.Errorf("unexpected zones %+v", m.Tokens[0].Zones)
+	}
+}
+
+func TestLoad_Json(t *testing.T) {
+	path := writeTemp(t, "manifest.json", `{"tokens": [{"name": "backup", "validity": 60, "zones": [{"id": "`+hrId.String()+`", "permissions": ["all"]}]}]}`)
+	m, err := Load(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if m.Tokens[0].Zones[0].Id != hrId.String() {
+		t.Errorf("expected zone id %s, got %s", hrId, m.Tokens[0].Zones[0].Id)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	path := writeTemp(t, "manifest.json", `{"tokens": `)
+	if _, err := Load(path); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	m := Manifest{Tokens: []Token{{
+		Name:     "backup",
+		Validity: 3600,
+		Zones: []Zone{
+			{Name: "Finance", Permissions: []string{"get", "List"}},
+			{Id: hrId.String(), Permissions: []string{"all"}},
+		},
+	}}}
+
+	plans, err := m.Resolve(testZones)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(plans) != 1 {
+		t.Fatalf("expected one plan, got %d", len(plans))
+	}
+
+	req := plans[0].Request
+	if *req.TokenName != "backup" || req.Scope.Name != "backup" || *req.Validity != 3600 {
+		t.Errorf("unexpected request %+v", req)
+	}
+	finance := req.Scope.Permissions["Zone="+financeId.String()]
+	if !finance.Get || !finance.List || finance.All {
+		t.Errorf("unexpected finance permission %+v", finance)
+	}
+	if !req.Scope.Permissions["Zone="+hrId.String()].All {
+		t.Errorf("expected all permission in HR")
+	}
+	if len(req.Scope.Clients) != 1 || req.Scope.Clients[0] != scope.ClientSSI {
+		t.Errorf("expected SSI client, got %v", req.Scope.Clients)
+	}
+}
+
+func TestResolve_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    Token
+		expected string
+	}{
+		{"Missing name", Token{Validity: 1}, "missing name"},
+		{"No validity", Token{Name: "a", Zones: []Zone{{Name: "HR", Permissions: []string{"get"}}}}, "validity"},
+		{"No zones", Token{Name: "a", Validity: 1}, "no zones"},
+		{"Unknown zone", Token{Name: "a", Validity: 1, Zones: []Zone{{Name: "Legal", Permissions: []string{"get"}}}}, "no zone named Legal"},
+		{"Unknown id", Token{Name: "a", Validity: 1, Zones: []Zone{{Id: "nope", Permissions: []string{"get"}}}}, "no zone with id"},
+		{"Unknown permission", Token{Name: "a", Validity: 1, Zones: []Zone{{Name: "HR", Permissions: []string{"read"}}}}, "unknown permission"},
+		{"No permissions", Token{Name: "a", Validity: 1, Zones: []Zone{{Name: "HR"}}}, "no permissions"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Manifest{Tokens: []Token{tt.token}}.Resolve(testZones)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestResolve_AmbiguousAndDuplicate(t *testing.T) {
+	zones := append(testZones, scope.ZoneData{Name: "HR", Id: uuid.New()})
+	tok := Token{Name: "a", Validity: 1, Zones: []Zone{{Name: "HR", Permissions: []string{"get"}}}}
+
+	_, err := Manifest{Tokens: []Token{tok}}.Resolve(zones)
+	if err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("expected ambiguous error, got %v", err)
+	}
+
+	_, err = Manifest{Tokens: []Token{tok, tok}}.Resolve(testZones)
+	if err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Errorf("expected duplicate error, got %v", err)
+	}
+}
+
+func TestResolve_Clients(t *testing.T) {
+	t.Cleanup(scope.RegisterClient(scope.ClientInfo{Client: "ManifestTestClient", Options: scope.S_GET}))
+	m := Manifest{Tokens: []Token{{
+		Name:     "backup",
+		Validity: 60,
+		Clients:  []string{"SynkzoneSSI", "ManifestTestClient"},
+		Zones:    []Zone{{Name: "HR", Permissions: []string{"get"}}},
+	}}}
+
+	plans, err := m.Resolve(testZones)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	clients := plans[0].Request.Scope.Clients
+	if len(clients) != 2 || clients[1] != "ManifestTestClient" {
+		t.Errorf("unexpected clients %v", clients)
+	}
+
+	t.Cleanup(scope.RegisterClient(scope.ClientInfo{Client: "ManifestReadOnly", Options: scope.S_GET}))
+	m.Tokens[0].Clients = []string{"ManifestReadOnly"}
+	m.Tokens[0].Zones[0].Permissions = []string{"delete"}
+	if _, err := m.Resolve(testZones); err == nil {
+		t.Error("expected error for a permission the client doesn't understand")
+	}
+}
+
+func TestFind(t *testing.T) {
+	m := Manifest{Tokens: []Token{{Name: "backup"}, {Name: "audit"}}}
+	if token, err := m.Find("audit"); err != nil || token.Name != "audit" {
+		t.Errorf("expected audit, got %+v, %v", token, err)
+	}
+	if _, err := m.Find("nope"); err == nil {
+		t.Error("expected error for an unknown token")
+	}
+}
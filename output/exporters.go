@@ -0,0 +1,224 @@
+package output
+
+import (
+	"bytes"
+	"compass/scope"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Labels and annotations compass puts on Kubernetes secrets
+const (
+	LABEL_MANAGED_BY      = "app.kubernetes.io/managed-by"
+	LABEL_TOKEN_ID        = "compass/token-id"
+	LABEL_EXPIRES         = "compass/expires"
+	ANNOTATION_EXPIRES_AT = "compass/expires-at"
+	DEFAULT_SECRET_KEY    = "token"
+)
+
+// How tokens are written as Kubernetes secrets
+type SecretOptions struct {
+	// Defaults to the token name, made into a valid Kubernetes name
+	Name      string
+	Namespace string
+	// The key in the secret that holds the token. Defaults to token
+	Key    string
+	Labels Labels
+}
+
+// Labels given as key=value, e.g. on the command line
+type Labels map[string]string
+
+func (l Labels) String() string {
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + l[k]
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (l Labels) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("labels should look like key=value")
+	}
+	l[key] = val
+	return nil
+}
+
+type secretManifest struct {
+	ApiVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   secretMetadata    `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	StringData map[string]string `yaml:"stringData"`
+}
+
+type secretMetadata struct {
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace,omitempty"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// Turn a token name into a name Kubernetes accepts for secrets
+func secretName(name string) string {
+	name = invalidNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	name = strings.Trim(name, "-")
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-")
+	}
+	if name == "" {
+		return "compass-token"
+	}
+	return name
+}
+
+func renderKubernetes(w Writer, name string, res scope.TokenResult) ([]byte, error) {
+	secret := secretManifest{
+		ApiVersion: "v1",
+		Kind:       "Secret",
+		Type:       "Opaque",
+		Metadata: secretMetadata{
+			Name:      w.Secret.Name,
+			Namespace: w.Secret.Namespace,
+			Labels:    map[string]string{},
+		},
+	}
+	// Extra labels can't replace the labels compass finds its secrets by
+	for k, v := range w.Secret.Labels {
+		secret.Metadata.Labels[k] = v
+	}
+	secret.Metadata.Labels[LABEL_MANAGED_BY] = "compass"
+	secret.Metadata.Labels[LABEL_TOKEN_ID] = res.Id
+	if secret.Metadata.Name == "" {
+		secret.Metadata.Name = secretName(name)
+	}
+	key := w.Secret.Key
+	if key == "" {
+		key = DEFAULT_SECRET_KEY
+	}
+	secret.StringData = map[string]string{key: res.Token}
+
+	// Label values can't hold a timestamp, so the expiry is a label in unix time and an annotation as is
+	if expires, err := time.Parse(time.RFC3339Nano, res.ExpiresAt); err == nil {
+		secret.Metadata.Labels[LABEL_EXPIRES] = strconv.FormatInt(expires.Unix(), 10)
+	}
+	if res.ExpiresAt != "" {
+		secret.Metadata.Annotations = map[string]string{ANNOTATION_EXPIRES_AT: res.ExpiresAt}
+	}
+
+	// Start a new document so that several secrets can be written to stdout and applied together
+	buf := bytes.Buffer{}
+	buf.WriteString("---\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(secret); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type dockerAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Auth     string `json:"auth"`
+}
+
+type dockerConfig struct {
+	Auths map[string]dockerAuth `json:"auths"`
+}
+
+// A docker config.json that logs in to the SSI host with the token id and token
+func renderDocker(w Writer, name string, res scope.TokenResult) ([]byte, error) {
+	registry := w.Host
+	if u, err := url.Parse(w.Host); err == nil && u.Host != "" {
+		registry = u.Host
+	}
+	if registry == "" {
+		return nil, fmt.Errorf("docker configs need a host")
+	}
+	config := dockerConfig{Auths: map[string]dockerAuth{
+		registry: {
+			Username: res.Id,
+			Password: res.Token,
+			Auth:     base64.StdEncoding.EncodeToString([]byte(res.Id + ":" + res.Token)),
+		},
+	}}
+	jsonData, err := json.MarshalIndent(config, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(jsonData, '\n'), nil
+}
+
+// What templates of the template format are executed with
+type TemplateData struct {
+	Name      string
+	Token     string
+	Id        string
+	ExpiresAt string
+	Host      string
+}
+
+var templateFuncs = template.FuncMap{
+	"base64": func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	},
+	"json": func(v any) (string, error) {
+		jsonData, err := json.Marshal(v)
+		return string(jsonData), err
+	},
+	"shellquote": shellQuote,
+}
+
+// Render a token with a text/template
+func RenderTemplate(text string, data TemplateData) ([]byte, error) {
+	tmpl, err := template.New("token").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.Buffer{}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func renderTemplate(w Writer, name string, res scope.TokenResult) ([]byte, error) {
+	if w.TemplateFile == "" {
+		return nil, fmt.Errorf("the template format needs a template file")
+	}
+	text, err := os.ReadFile(w.TemplateFile)
+	if err != nil {
+		return nil, err
+	}
+	return RenderTemplate(string(text), TemplateData{
+		Name:      name,
+		Token:     res.Token,
+		Id:        res.Id,
+		ExpiresAt: res.ExpiresAt,
+		Host:      w.Host,
+	})
+}
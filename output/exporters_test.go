@@ -0,0 +1,133 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func render(t *testing.T, w Writer, name string) string {
+	t.Helper()
+	stdout := &bytes.Buffer{}
+	w.Path = STDOUT
+	w.Stdout = stdout
+	if _, err := w.Write(name, testResult); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return stdout.String()
+}
+
+func TestKubernetes(t *testing.T) {
+	w := New(WithFormat(FORMAT_KUBERNETES), WithSecret(SecretOptions{
+		Namespace: "backups",
+		Key:       "SSI_TOKEN",
+		Labels:    Labels{"team": "storage"},
+	}))
+	expected := `---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: nightly-backup-2024
+  namespace: backups
+  labels:
+    app.kubernetes.io/managed-by: compass
+    compass/expires: "1717243200"
+    compass/token-id: 11111111-1111-1111-1111-111111111111
+    team: storage
+  annotations:
+    compass/expires-at: "2024-06-01T12:00:00Z"
+type: Opaque
+stringData:
+  SSI_TOKEN: se'cret
+`
+	if got := render(t, w, "Nightly backup (2024)"); got != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestKubernetes_Defaults(t *testing.T) {
+	got := render(t, New(WithFormat(FORMAT_KUBERNETES)), "!!!")
+	secret := secretManifest{}
+	if err := yaml.Unmarshal([]byte(got), &secret); err != nil {
+		t.Fatalf("expected yaml, got %v", err)
+	}
+	if secret.Metadata.Name != "compass-token" || secret.Metadata.Namespace != "" {
+		t.Errorf("unexpected metadata %+v", secret.Metadata)
+	}
+	if secret.StringData[DEFAULT_SECRET_KEY] != testResult.Token {
+		t.Errorf("expected the token under the default key, got %+v", secret.StringData)
+	}
+}
+
+func TestKubernetes_ManagedLabels(t *testing.T) {
+	w := New(WithFormat(FORMAT_KUBERNETES), WithSecret(SecretOptions{
+		Labels: Labels{LABEL_TOKEN_ID: "other", LABEL_MANAGED_BY: "helm", "team": "storage"},
+	}))
+	secret := secretManifest{}
+	if err := yaml.Unmarshal([]byte(render(t, w, "backup")), &secret); err != nil {
+		t.Fatalf("expected yaml, got %v", err)
+	}
+	labels := secret.Metadata.Labels
+	if labels[LABEL_TOKEN_ID] != testResult.Id || labels[LABEL_MANAGED_BY] != "compass" || labels["team"] != "storage" {
+		t.Errorf("expected the labels of compass to win, got %+v", labels)
+	}
+}
+
+func TestDocker(t *testing.T) {
+	got := render(t, New(WithFormat(FORMAT_DOCKER), WithHost("https://ssi.example.com:8443/api")), "backup")
+	config := dockerConfig{}
+	if err := json.Unmarshal([]byte(got), &config); err != nil {
+		t.Fatalf("expected json, got %v", err)
+	}
+	auth, ok := config.Auths["ssi.example.com:8443"]
+	if !ok || auth.Password != testResult.Token || auth.Auth != "MTExMTExMTEtMTExMS0xMTExLTExMTEtMTExMTExMTExMTExOnNlJ2NyZXQ=" {
+		t.Errorf("unexpected config %+v", config)
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "token.tmpl")
+	os.WriteFile(file, []byte(`{{.Name}} {{.Id}} {{shellquote .Token}} {{base64 .Token}} {{json .ExpiresAt}}`), 0600)
+
+	got := render(t, New(WithFormat(FORMAT_TEMPLATE), WithTemplateFile(file)), "backup")
+	expected := `backup 11111111-1111-1111-1111-111111111111 'se'\''cret' c2UnY3JldA== "2024-06-01T12:00:00Z"`
+	if got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	if _, err := RenderTemplate("{{.Missing}}", TemplateData{}); err == nil {
+		t.Error("expected error for an unknown field")
+	}
+	if _, err := New(WithPath(STDOUT), WithFormat(FORMAT_TEMPLATE)).Write("backup", testResult); err == nil {
+		t.Error("expected error without a template file")
+	}
+}
+
+func TestWriter_Flags(t *testing.T) {
+	defaults := New(WithSecret(SecretOptions{Labels: Labels{"team": "storage"}}))
+	w := defaults
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	w.Flags(fs)
+	err := fs.Parse([]string{"-format", "kubernetes", "-namespace", "backups", "-label", "env=prod", "-o", "-"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if w.Format != FORMAT_KUBERNETES || w.Secret.Namespace != "backups" || !w.ToStdout() {
+		t.Errorf("unexpected writer %+v", w)
+	}
+	if w.Secret.Labels.String() != "env=prod,team=storage" {
+		t.Errorf("unexpected labels %s", w.Secret.Labels)
+	}
+	if len(defaults.Secret.Labels) != 1 {
+		t.Errorf("expected the defaults to be left alone, got %s", defaults.Secret.Labels)
+	}
+	if err := fs.Parse([]string{"-label", "nope"}); err == nil || !strings.Contains(err.Error(), "key=value") {
+		t.Errorf("expected error for a label without a value, got %v", err)
+	}
+}
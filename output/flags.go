@@ -0,0 +1,35 @@
+package output
+
+import (
+	"compass/crypt"
+	"flag"
+	"strings"
+)
+
+// Add flags for the settings of the writer to fs. The current settings are the defaults
+func (w *Writer) Flags(fs *flag.FlagSet) {
+	labels := Labels{}
+	for k, v := range w.Secret.Labels {
+		labels[k] = v
+	}
+	w.Secret.Labels = labels
+	w.Encryption.Recipients = append([]string{}, w.Encryption.Recipients...)
+
+	fs.StringVar(&w.Path, "o", w.Path, "Directory or file to write tokens to, - for stdout")
+	fs.StringVar(&w.Format, "format", w.Format, "Token format, one of "+strings.Join(Formats(), ", "))
+	fs.StringVar(&w.Secret.Name, "secret-name", w.Secret.Name, "Name of the kubernetes secret, defaults to the token name")
+	fs.StringVar(&w.Secret.Namespace, "namespace", w.Secret.Namespace, "Namespace of the kubernetes secret")
+	fs.StringVar(&w.Secret.Key, "secret-key", w.Secret.Key, "Key of the token in the kubernetes secret, defaults to "+DEFAULT_SECRET_KEY)
+	fs.Var(w.Secret.Labels, "label", "Extra label on the kubernetes secret as key=value, can be repeated")
+	fs.StringVar(&w.TemplateFile, "output-template", w.TemplateFile, "text/template file for the template format")
+	fs.Func("encrypt-to", "Encrypt tokens to an age public key, or a file of them, can be repeated", func(ref string) error {
+		keys, err := crypt.ReadRecipients(ref)
+		w.Encryption.Recipients = append(w.Encryption.Recipients, keys...)
+		return err
+	})
+	fs.BoolFunc("encrypt-passphrase", "Encrypt tokens with the passphrase in $"+crypt.PASSPHRASE_ENV, func(string) error {
+		passphrase, err := crypt.EnvPassphrase()
+		w.Encryption.Passphrase = passphrase
+		return err
+	})
+}
@@ -0,0 +1,251 @@
+package output
+
+import (
+	"compass/crypt"
+	"compass/fsutil"
+	"compass/scope"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	FORMAT_JSON       = "json"
+	FORMAT_ENV        = "env"
+	FORMAT_SHELL      = "shell"
+	FORMAT_NETRC      = "netrc"
+	FORMAT_KUBERNETES = "kubernetes"
+	FORMAT_DOCKER     = "docker"
+	FORMAT_TEMPLATE   = "template"
+)
+
+// Where a token goes when it should be printed instead of written to a file
+const STDOUT = "-"
+
+type format struct {
+	extension string
+	render    func(w Writer, name string, res scope.TokenResult) ([]byte, error)
+}
+
+var formats = map[string]format{
+	FORMAT_JSON:       {".json", renderJson},
+	FORMAT_ENV:        {".env", renderEnv},
+	FORMAT_SHELL:      {".sh", renderShell},
+	FORMAT_NETRC:      {".netrc", renderNetrc},
+	FORMAT_KUBERNETES: {".yaml", renderKubernetes},
+	FORMAT_DOCKER:     {".json", renderDocker},
+	FORMAT_TEMPLATE:   {".txt", renderTemplate},
+}
+
+// The names of the formats a token can be written in
+func Formats() []string {
+	return []string{FORMAT_JSON, FORMAT_ENV, FORMAT_SHELL, FORMAT_NETRC, FORMAT_KUBERNETES, FORMAT_DOCKER, FORMAT_TEMPLATE}
+}
+
+func ValidFormat(name string) bool {
+	_, ok := formats[name]
+	return ok
+}
+
+// Writes created tokens to a directory, a file or stdout
+type Writer struct {
+	// A directory, a file, or - for stdout. Defaults to the current directory
+	Path   string
+	Format string
+	// The SSI host, used as the machine of netrc entries and the registry of docker configs
+	Host   string
+	Stdout io.Writer
+	Secret SecretOptions
+	// A text/template file, used by the template format
+	TemplateFile string
+	// Encrypt tokens before they are written
+	Encryption crypt.Options
+}
+
+func WithPath(path string) func(*Writer) {
+	return func(w *Writer) {
+		w.Path = path
+	}
+}
+
+func WithFormat(name string) func(*Writer) {
+	return func(w *Writer) {
+		w.Format = name
+	}
+}
+
+func WithHost(host string) func(*Writer) {
+	return func(w *Writer) {
+		w.Host = host
+	}
+}
+
+func WithStdout(stdout io.Writer) func(*Writer) {
+	return func(w *Writer) {
+		w.Stdout = stdout
+	}
+}
+
+func WithSecret(secret SecretOptions) func(*Writer) {
+	return func(w *Writer) {
+		w.Secret = secret
+	}
+}
+
+func WithTemplateFile(path string) func(*Writer) {
+	return func(w *Writer) {
+		w.TemplateFile = path
+	}
+}
+
+func WithEncryption(o crypt.Options) func(*Writer) {
+	return func(w *Writer) {
+		w.Encryption = o
+	}
+}
+
+func New(options ...func(*Writer)) Writer {
+	w := Writer{
+		Path:   ".",
+		Format: FORMAT_JSON,
+		Stdout: os.Stdout,
+	}
+	for _, o := range options {
+		o(&w)
+	}
+	return w
+}
+
+// Whether tokens are written to a directory, one file per token
+func (w Writer) ToDir() bool {
+	if w.Path == "" || strings.HasSuffix(w.Path, string(os.PathSeparator)) {
+		return true
+	}
+	info, err := os.Stat(w.Path)
+	return err == nil && info.IsDir()
+}
+
+func (w Writer) ToStdout() bool {
+	return w.Path == STDOUT
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// The file a token is written to. In a directory the file is named token~<name> with the extension of the format
+func (w Writer) Target(name string) string {
+	if w.ToStdout() {
+		return STDOUT
+	}
+	if !w.ToDir() {
+		return w.Path
+	}
+	dir := w.Path
+	if dir == "" {
+		dir = "."
+	}
+	extension := formats[w.Format].extension
+	if w.Encryption.Enabled() {
+		extension += crypt.EXTENSION
+	}
+	return filepath.Join(dir, "token~"+unsafeFileChars.ReplaceAllString(name, "_")+extension)
+}
+
+// Write a token and return where it was written
+func (w Writer) Write(name string, res scope.TokenResult) (string, error) {
+	return w.WriteAs(name, name, res)
+}
+
+// Write a token named name to token~<file> when writing to a directory
+func (w Writer) WriteAs(file string, name string, res scope.TokenResult) (string, error) {
+	f, ok := formats[w.Format]
+	if !ok {
+		return "", fmt.Errorf("Unknown output format %s, use one of %s", w.Format, strings.Join(Formats(), ", "))
+	}
+	data, err := f.render(w, name, res)
+	if err != nil {
+		return "", fmt.Errorf("Could not format token :: %+v", err)
+	}
+	if w.Encryption.Enabled() {
+		if data, err = crypt.Encrypt(data, w.Encryption); err != nil {
+			return "", err
+		}
+	}
+
+	target := w.Target(file)
+	if target == STDOUT {
+		if _, err := w.Stdout.Write(data); err != nil {
+			return "stdout", fmt.Errorf("Could not write token :: %+v", err)
+		}
+		return "stdout", nil
+	}
+	if err := fsutil.WriteFileAtomic(target, data); err != nil {
+		return target, err
+	}
+	return target, nil
+}
+
+func renderJson(w Writer, name string, res scope.TokenResult) ([]byte, error) {
+	jsonData, err := json.MarshalIndent(res, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(jsonData, '\n'), nil
+}
+
+// The environment variables a token is exported as
+func variables(res scope.TokenResult) [][2]string {
+	return [][2]string{
+		{"COMPASS_TOKEN", res.Token},
+		{"COMPASS_TOKEN_ID", res.Id},
+		{"COMPASS_TOKEN_EXPIRES_AT", res.ExpiresAt},
+	}
+}
+
+func renderEnv(w Writer, name string, res scope.TokenResult) ([]byte, error) {
+	doc := strings.Builder{}
+	for _, v := range variables(res) {
+		if strings.ContainsAny(v[1], "\n\r") {
+			return nil, fmt.Errorf("%s contains a line break", v[0])
+		}
+		doc.WriteString(v[0] + "=" + v[1] + "\n")
+	}
+	return []byte(doc.String()), nil
+}
+
+func renderShell(w Writer, name string, res scope.TokenResult) ([]byte, error) {
+	doc := strings.Builder{}
+	for _, v := range variables(res) {
+		doc.WriteString("export " + v[0] + "=" + shellQuote(v[1]) + "\n")
+	}
+	return []byte(doc.String()), nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// The host name of the SSI host, which may be a url
+func hostname(host string) string {
+	if u, err := url.Parse(host); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	return host
+}
+
+func renderNetrc(w Writer, name string, res scope.TokenResult) ([]byte, error) {
+	machine := hostname(w.Host)
+	if machine == "" {
+		return nil, fmt.Errorf("netrc entries need a host")
+	}
+	for _, field := range []string{machine, res.Id, res.Token} {
+		if field == "" || strings.ContainsAny(field, " \t\n\r") {
+			return nil, fmt.Errorf("%q can't be written to a netrc entry", field)
+		}
+	}
+	return []byte(fmt.Sprintf("machine %s login %s password %s\n", machine, res.Id, res.Token)), nil
+}
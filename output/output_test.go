@@ -0,0 +1,114 @@
+package output
+
+import (
+	"bytes"
+	"compass/crypt"
+	"compass/crypt/crypttest"
+	"compass/scope"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testResult = scope.TokenResult{
+	Token:     "se'cret",
+	ExpiresAt: "2024-06-01T12:00:00Z",
+	Id:        "11111111-1111-1111-1111-111111111111",
+}
+
+func TestWriter_Formats(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{FORMAT_JSON, "{\n\t\"token\": \"se'cret\",\n\t\"expiresAt\": \"2024-06-01T12:00:00Z\",\n\t\"id\": \"11111111-1111-1111-1111-111111111111\"\n}\n"},
+		{FORMAT_ENV, "COMPASS_TOKEN=se'cret\nCOMPASS_TOKEN_ID=11111111-1111-1111-1111-111111111111\nCOMPASS_TOKEN_EXPIRES_AT=2024-06-01T12:00:00Z\n"},
+		{FORMAT_SHELL, "export COMPASS_TOKEN='se'\\''cret'\nexport COMPASS_TOKEN_ID='11111111-1111-1111-1111-111111111111'\nexport COMPASS_TOKEN_EXPIRES_AT='2024-06-01T12:00:00Z'\n"},
+		{FORMAT_NETRC, "machine ssi.example.com login 11111111-1111-1111-1111-111111111111 password se'cret\n"},
+	}
+	for _, test := range tests {
+		stdout := &bytes.Buffer{}
+		w := New(WithPath(STDOUT), WithFormat(test.format), WithHost("https://ssi.example.com/api"), WithStdout(stdout))
+		where, err := w.Write("backup", testResult)
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", test.format, err)
+			continue
+		}
+		if where != "stdout" || stdout.String() != test.expected {
+			t.Errorf("%s: expected %q, got %q to %s", test.format, test.expected, stdout.String(), where)
+		}
+	}
+}
+
+func TestWriter_Errors(t *testing.T) {
+	if _, err := New(WithPath(STDOUT), WithFormat("xml"), WithStdout(&bytes.Buffer{})).Write("backup", testResult); err == nil {
+		t.Error("expected error for an unknown format")
+	}
+	if _, err := New(WithPath(STDOUT), WithFormat(FORMAT_NETRC), WithStdout(&bytes.Buffer{})).Write("backup", testResult); err == nil {
+		t.Error("expected error for netrc without a host")
+	}
+	if _, err := New(WithPath(filepath.Join(t.TempDir(), "missing", "token.json"))).Write("backup", testResult); err == nil {
+		t.Error("expected error for a missing directory")
+	}
+}
+
+func TestWriter_Dir(t *testing.T) {
+	dir := t.TempDir()
+	w := New(WithPath(dir), WithFormat(FORMAT_ENV))
+	where, err := w.Write("audit log", testResult)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if where != filepath.Join(dir, "token~audit_log.env") {
+		t.Errorf("unexpected file %s", where)
+	}
+	info, err := os.Stat(where)
+	if err != nil {
+		t.Fatalf("expected the file to exist, got %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %s", info.Mode())
+	}
+}
+
+func TestWriter_FileIsReplaced(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	if err := os.WriteFile(path, bytes.Repeat([]byte("#"), 1024), 0644); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+	if _, err := New(WithPath(path)).Write("backup", testResult); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if bytes.Contains(data, []byte("#")) {
+		t.Errorf("expected the old content to be gone, got %q", data)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("expected no temporary files to be left, got %d files", len(entries))
+	}
+}
+
+func TestWriter_Encrypted(t *testing.T) {
+	identity, recipient := crypttest.Identity, crypttest.Recipient
+	dir := t.TempDir()
+	w := New(WithPath(dir), WithEncryption(crypt.Options{Recipients: []string{recipient}}))
+	where, err := w.Write("backup", testResult)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if where != filepath.Join(dir, "token~backup.json.age") {
+		t.Errorf("unexpected file %s", where)
+	}
+
+	data, _ := os.ReadFile(where)
+	if bytes.Contains(data, []byte(testResult.Token)) {
+		t.Fatal("expected the token to be encrypted")
+	}
+	identities, _ := crypt.ParseIdentities(strings.NewReader(identity))
+	plain, err := crypt.Decrypt(data, "", identities)
+	if err != nil || !bytes.Contains(plain, []byte(`"token": "se'cret"`)) {
+		t.Errorf("expected the token json, got %s, %v", plain, err)
+	}
+}
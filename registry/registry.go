@@ -0,0 +1,213 @@
+package registry
+
+import (
+	"compass/fsutil"
+	"compass/scope"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// A token that compass created
+type Entry struct {
+	Id      string    `json:"id"`
+	Name    string    `json:"name,omitempty"`
+	Expires time.Time `json:"expires"`
+	Path    string    `json:"path,omitempty"`
+	Created time.Time `json:"created"`
+	// When the token is to be revoked, e.g. after it was rotated with a grace period. Zero when it isn't
+	RevokeAt time.Time `json:"revokeAt,omitempty"`
+}
+
+// Record a newly created token. Path is where the token was written, if anywhere
+func NewEntry(name string, res scope.TokenResult, path string) Entry {
+	e := Entry{
+		Id:      res.Id,
+		Name:    name,
+		Path:    path,
+		Created: time.Now().UTC(),
+	}
+	if expires, err := time.Parse(time.RFC3339Nano, res.ExpiresAt); err == nil {
+		e.Expires = expires
+	}
+	return e
+}
+
+// Whether the entry expires before t. Entries without an expiry never do
+func (e Entry) ExpiresBefore(t time.Time) bool {
+	return !e.Expires.IsZero() && e.Expires.Before(t)
+}
+
+// The tokens compass created, kept in a json file
+type Registry struct {
+	path    string
+	Entries []Entry `json:"entries"`
+}
+
+// The registry in the users config directory, e.g. ~/.config/compass/tokens.json.
+// The directory is created if it doesn't exist.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "compass")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tokens.json"), nil
+}
+
+// Load the registry at path. A missing file is an empty registry
+func Load(path string) (*Registry, error) {
+	r := &Registry{path: path, Entries: []Entry{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return r, fmt.Errorf("Could not read token registry :: %+v", err)
+	}
+	if err := json.Unmarshal(data, r); err != nil {
+		return r, fmt.Errorf("Could not read token registry :: %+v", err)
+	}
+	return r, nil
+}
+
+// Load the registry at the default path
+func LoadDefault() (*Registry, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return Load(path)
+}
+
+// Write the registry in one go, so a reader never sees it half written.
+// Use Update to change the default registry, so changes made by other processes in the meantime aren't lost
+func (r *Registry) Save() error {
+	data, err := json.MarshalIndent(r, "", "\t")
+	if err != nil {
+		return fmt.Errorf("Could not write token registry :: %+v", err)
+	}
+	if err := fsutil.WriteFileAtomic(r.path, data); err != nil {
+		return fmt.Errorf("Could not write token registry :: %+v", err)
+	}
+	return nil
+}
+
+// Change the registry at path. It is locked and read again first, so entries other compass processes added are kept.
+// It is only saved when change returns true
+func Update(path string, change func(r *Registry) bool) error {
+	unlock, err := fsutil.Lock(path)
+	if err != nil {
+		return fmt.Errorf("Could not write token registry :: %+v", err)
+	}
+	defer unlock()
+
+	r, err := Load(path)
+	if err != nil {
+		return err
+	}
+	if !change(r) {
+		return nil
+	}
+	return r.Save()
+}
+
+// Change the registry at the default path, see Update
+func UpdateDefault(change func(r *Registry) bool) error {
+	path, err := DefaultPath()
+	if err != nil {
+		return err
+	}
+	return Update(path, change)
+}
+
+// Add an entry, replacing any entry with the same id
+func (r *Registry) Add(e Entry) {
+	r.Forget(e.Id)
+	r.Entries = append(r.Entries, e)
+}
+
+// Remove the entry with the given id. Returns false if there was none
+func (r *Registry) Forget(id string) bool {
+	for i, e := range r.Entries {
+		if e.Id == id {
+			r.Entries = append(r.Entries[:i], r.Entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Entries that have expired or expire within the given duration of now, soonest first
+func (r *Registry) Expiring(now time.Time, within time.Duration) []Entry {
+	res := []Entry{}
+	for _, e := range r.Entries {
+		if e.ExpiresBefore(now.Add(within)) {
+			res = append(res, e)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Expires.Before(res[j].Expires)
+	})
+	return res
+}
+
+// Entries that are due to be revoked at now, soonest first
+func (r *Registry) Due(now time.Time) []Entry {
+	res := []Entry{}
+	for _, e := range r.Entries {
+		if !e.RevokeAt.IsZero() && !e.RevokeAt.After(now) {
+			res = append(res, e)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].RevokeAt.Before(res[j].RevokeAt)
+	})
+	return res
+}
+
+// Whether the token with the given id is waiting to be revoked
+func (r *Registry) Pending(id string) bool {
+	for _, e := range r.Entries {
+		if e.Id == id {
+			return !e.RevokeAt.IsZero()
+		}
+	}
+	return false
+}
+
+// Remember to revoke a token at the given time in the default registry. Tokens compass didn't create are added
+func ScheduleRevoke(id string, name string, at time.Time) error {
+	return UpdateDefault(func(r *Registry) bool {
+		for i := range r.Entries {
+			if r.Entries[i].Id == id {
+				r.Entries[i].RevokeAt = at
+				return true
+			}
+		}
+		r.Add(Entry{Id: id, Name: name, RevokeAt: at})
+		return true
+	})
+}
+
+// Add a newly created token to the default registry
+func Record(e Entry) error {
+	return UpdateDefault(func(r *Registry) bool {
+		r.Add(e)
+		return true
+	})
+}
+
+// Remove a token from the default registry, e.g. when it has been revoked
+func Forget(id string) error {
+	return UpdateDefault(func(r *Registry) bool {
+		return r.Forget(id)
+	})
+}
@@ -0,0 +1,138 @@
+package registry
+
+import (
+	"compass/scope"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func entry(id string, expires time.Time) Entry {
+	return Entry{Id: id, Name: id, Expires: expires}
+}
+
+func TestNewEntry(t *testing.T) {
+	e := NewEntry("backup", scope.TokenResult{Id: "1", ExpiresAt: "2024-06-08T12:00:00Z"}, "token~backup.json")
+	if e.Id != "1" || e.Name != "backup" || e.Path != "token~backup.json" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if !e.Expires.Equal(now.Add(7 * 24 * time.Hour)) {
+		t.Errorf("expected expiry to be parsed, got %s", e.Expires)
+	}
+	if e := NewEntry("backup", scope.TokenResult{Id: "1"}, ""); !e.Expires.IsZero() || e.ExpiresBefore(now) {
+		t.Errorf("expected no expiry, got %+v", e)
+	}
+}
+
+func TestLoad_Missing(t *testing.T) {
+	r, err := Load(filepath.Join(t.TempDir(), "tokens.json"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(r.Entries) != 0 {
+		t.Errorf("expected an empty registry, got %+v", r.Entries)
+	}
+}
+
+func TestRegistry_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	r, _ := Load(path)
+	r.Add(entry("1", now))
+	r.Add(entry("2", now))
+	r.Add(Entry{Id: "1", Name: "renamed"})
+	if err := r.Save(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(loaded.Entries) != 2 || loaded.Entries[1].Name != "renamed" {
+		t.Errorf("expected the entry to be replaced, got %+v", loaded.Entries)
+	}
+	if !loaded.Forget("2") || loaded.Forget("2") {
+		t.Error("expected 2 to be forgotten once")
+	}
+}
+
+func TestRegistry_Expiring(t *testing.T) {
+	r := &Registry{Entries: []Entry{
+		entry("later", now.Add(30*24*time.Hour)),
+		entry("soon", now.Add(2*24*time.Hour)),
+		entry("expired", now.Add(-time.Hour)),
+		{Id: "unknown"},
+	}}
+	expiring := r.Expiring(now, 7*24*time.Hour)
+	if len(expiring) != 2 || expiring[0].Id != "expired" || expiring[1].Id != "soon" {
+		t.Errorf("expected expired and soon, got %+v", expiring)
+	}
+}
+
+func TestRecordAndForget(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	if err := Record(entry("1", now)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := Forget("1"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	r, err := LoadDefault()
+	if err != nil || len(r.Entries) != 0 {
+		t.Errorf("expected an empty registry, got %+v, %v", r, err)
+	}
+}
+
+func TestScheduleRevoke(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	Record(entry("tracked", now.Add(24*time.Hour)))
+	if err := ScheduleRevoke("tracked", "backup", now.Add(time.Hour)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := ScheduleRevoke("untracked", "audit", now.Add(-time.Hour)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	r, _ := LoadDefault()
+	if len(r.Entries) != 2 || !r.Pending("tracked") || !r.Pending("untracked") {
+		t.Errorf("expected both tokens to be pending, got %+v", r.Entries)
+	}
+	if due := r.Due(now); len(due) != 1 || due[0].Id != "untracked" {
+		t.Errorf("expected only the untracked token to be due, got %+v", due)
+	}
+	if due := r.Due(now.Add(2 * time.Hour)); len(due) != 2 || due[0].Id != "untracked" {
+		t.Errorf("expected both tokens to be due, soonest first, got %+v", due)
+	}
+}
+
+func TestUpdate_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			if err := Update(path, func(r *Registry) bool {
+				r.Add(entry(id, now))
+				return true
+			}); err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(loaded.Entries) != 20 {
+		t.Errorf("expected every update to be kept, 20 entries, got %d", len(loaded.Entries))
+	}
+}
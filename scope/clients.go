@@ -0,0 +1,101 @@
+package scope
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A client that tokens can be issued for, and the permission options it understands
+type ClientInfo struct {
+	Client      Client
+	Description string
+	Options     byte
+}
+
+var clientRegistry = []ClientInfo{
+	{
+		Client:      ClientSSI,
+		Description: "Synkzone SSI",
+		Options:     S_ALL | allOptions,
+	},
+}
+
+// Add a client to the registry, or replace the one with the same name.
+// Returns a function that puts the registry back the way it was
+func RegisterClient(info ClientInfo) (restore func()) {
+	previous := Clients()
+	restore = func() {
+		clientRegistry = previous
+	}
+	for i, c := range clientRegistry {
+		if c.Client == info.Client {
+			clientRegistry[i] = info
+			return restore
+		}
+	}
+	clientRegistry = append(clientRegistry, info)
+	return restore
+}
+
+// Every registered client, in registration order
+func Clients() []ClientInfo {
+	return append([]ClientInfo{}, clientRegistry...)
+}
+
+func LookupClient(c Client) (ClientInfo, bool) {
+	for _, info := range clientRegistry {
+		if info.Client == c {
+			return info, true
+		}
+	}
+	return ClientInfo{}, false
+}
+
+// Parse a comma separated list of clients. Every client has to be registered
+func ParseClients(list string) ([]Client, error) {
+	clients := []Client{}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		c := Client(name)
+		if _, ok := LookupClient(c); !ok {
+			return clients, fmt.Errorf("Unknown client %s, use one of %s", name, strings.Join(clientNames(), ", "))
+		}
+		clients = append(clients, c)
+	}
+	return clients, nil
+}
+
+func clientNames() []string {
+	names := []string{}
+	for _, info := range clientRegistry {
+		names = append(names, string(info.Client))
+	}
+	return names
+}
+
+// Check that the scope has at least one registered client, and that every
+// permission option it grants is understood by one of its clients
+func (s SPScope) ValidateClients() error {
+	if len(s.Clients) == 0 {
+		return fmt.Errorf("Choose at least one client")
+	}
+	options := byte(0)
+	for _, c := range s.Clients {
+		info, ok := LookupClient(c)
+		if !ok {
+			return fmt.Errorf("Unknown client %s", c)
+		}
+		options |= info.Options
+	}
+	for _, r := range RulesFromPermissions(s.Permissions) {
+		unsupported := PermissionConf(r.Permission) &^ options
+		if unsupported != 0 {
+			names := PermissionNames(CreatePermission(unsupported))
+			return fmt.Errorf("%s grants %s, which none of the clients understand", r.Key(), strings.Join(names, ","))
+		}
+	}
+	return nil
+}
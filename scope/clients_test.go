@@ -0,0 +1,83 @@
+package scope_test
+
+import (
+	"compass/scope"
+	"reflect"
+	"testing"
+)
+
+func TestParseClients(t *testing.T) {
+	t.Cleanup(scope.RegisterClient(scope.ClientInfo{Client: "ParseClientsTest", Options: scope.S_GET}))
+	clients, err := scope.ParseClients(" SynkzoneSSI, ParseClientsTest ,")
+	if err != nil || !reflect.DeepEqual(clients, []scope.Client{scope.ClientSSI, "ParseClientsTest"}) {
+		t.Errorf("unexpected clients %v, %v", clients, err)
+	}
+
+	if _, err := scope.ParseClients("SynkzonSSI"); err == nil {
+		t.Error("expected error for an unknown client")
+	}
+	if _, ok := scope.LookupClient("SynkzonSSI"); ok {
+		t.Error("expected an unknown client not to be registered")
+	}
+}
+
+func TestRegisterClient_Restore(t *testing.T) {
+	restore := scope.RegisterClient(scope.ClientInfo{Client: "RestoreTest"})
+	if _, ok := scope.LookupClient("RestoreTest"); !ok {
+		t.Error("expected the client to be registered")
+	}
+	restore()
+	if _, ok := scope.LookupClient("RestoreTest"); ok {
+		t.Error("expected restore to remove the client")
+	}
+}
+
+func TestSPScope_ValidateClients(t *testing.T) {
+	t.Cleanup(scope.RegisterClient(scope.ClientInfo{Client: "ReadOnly", Options: scope.S_GET | scope.S_LIST}))
+
+	financeKey := "Zone=" + financeId.String()
+	tests := []struct {
+		name    string
+		scope   scope.SPScope
+		isValid bool
+	}{
+		{"No clients", scope.SPScope{}, false},
+		{"Unknown client", scope.SPScope{Clients: []scope.Client{"Nope"}}, false},
+		{
+			"Supported permission",
+			scope.SPScope{
+				Clients:     []scope.Client{"ReadOnly"},
+				Permissions: map[string]scope.Permission{financeKey: {Get: true}},
+			},
+			true,
+		},
+		{
+			"Unsupported permission",
+			scope.SPScope{
+				Clients:     []scope.Client{"ReadOnly"},
+				Permissions: map[string]scope.Permission{financeKey: {Get: true, Delete: true}},
+			},
+			false,
+		},
+		{
+			"Supported by one of the clients",
+			scope.SPScope{
+				Clients:     []scope.Client{"ReadOnly", scope.ClientSSI},
+				Permissions: map[string]scope.Permission{financeKey: {All: true}},
+			},
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.scope.ValidateClients()
+			if tt.isValid && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if !tt.isValid && err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package scope
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// How the permission for a single key changed between two scopes
+type RuleChange struct {
+	Kind string
+	Ref  string
+	Old  Permission
+	New  Permission
+}
+
+// Names of the options set in New but not in Old
+func (c RuleChange) Granted() []string {
+	return subtractNames(PermissionNames(c.New), PermissionNames(c.Old))
+}
+
+// Names of the options set in Old but not in New
+func (c RuleChange) Revoked() []string {
+	return subtractNames(PermissionNames(c.Old), PermissionNames(c.New))
+}
+
+func subtractNames(a []string, b []string) []string {
+	res := []string{}
+	for _, n := range a {
+		found := false
+		for _, m := range b {
+			found = found || n == m
+		}
+		if !found {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+// How a limitation or extension changed. Old is nil when it was added and New is nil when it was removed
+type EntryChange struct {
+	Key string
+	Old interface{}
+	New interface{}
+}
+
+// The difference between two scopes, as seen from the first one
+type ScopeDiff struct {
+	Added          []Rule
+	Removed        []Rule
+	Changed        []RuleChange
+	AddedClients   []Client
+	RemovedClients []Client
+	Limitations    []EntryChange
+	Extensions     []EntryChange
+}
+
+func (d ScopeDiff) Empty() bool {
+	return len(d.Added) == 0 &&
+		len(d.Removed) == 0 &&
+		len(d.Changed) == 0 &&
+		len(d.AddedClients) == 0 &&
+		len(d.RemovedClients) == 0 &&
+		len(d.Limitations) == 0 &&
+		len(d.Extensions) == 0
+}
+
+// Compare two scopes. Rules and changes are sorted by key
+func Diff(a, b SPScope) ScopeDiff {
+	d := ScopeDiff{
+		Added:          []Rule{},
+		Removed:        []Rule{},
+		Changed:        []RuleChange{},
+		AddedClients:   []Client{},
+		RemovedClients: []Client{},
+	}
+
+	for _, r := range RulesFromPermissions(a.Permissions) {
+		newPerm, ok := b.Permissions[r.Key()]
+		if !ok {
+			d.Removed = append(d.Removed, r)
+			continue
+		}
+		if newPerm != r.Permission {
+			d.Changed = append(d.Changed, RuleChange{
+				Kind: r.Kind,
+				Ref:  r.Ref,
+				Old:  r.Permission,
+				New:  newPerm,
+			})
+		}
+	}
+	for _, r := range RulesFromPermissions(b.Permissions) {
+		if _, ok := a.Permissions[r.Key()]; !ok {
+			d.Added = append(d.Added, r)
+		}
+	}
+
+	d.AddedClients = subtractClients(b.Clients, a.Clients)
+	d.RemovedClients = subtractClients(a.Clients, b.Clients)
+	d.Limitations = diffEntries(a.Limitations, b.Limitations)
+	d.Extensions = diffEntries(a.Extensions, b.Extensions)
+	return d
+}
+
+// Compare limitations or extensions, sorted by key. Values are compared as json,
+// as the same entry may be decoded from json or set from a typed value
+func diffEntries(a map[string]interface{}, b map[string]interface{}) []EntryChange {
+	keys := []string{}
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	changes := []EntryChange{}
+	for _, k := range keys {
+		old, inA := a[k]
+		new, inB := b[k]
+		if inA && inB && sameEntry(old, new) {
+			continue
+		}
+		changes = append(changes, EntryChange{Key: k, Old: old, New: new})
+	}
+	return changes
+}
+
+func sameEntry(a interface{}, b interface{}) bool {
+	aJson, aErr := json.Marshal(a)
+	bJson, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && string(aJson) == string(bJson)
+}
+
+func subtractClients(a []Client, b []Client) []Client {
+	res := []Client{}
+	for _, c := range a {
+		found := false
+		for _, o := range b {
+			found = found || c == o
+		}
+		if !found {
+			res = append(res, c)
+		}
+	}
+	return res
+}
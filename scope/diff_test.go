@@ -0,0 +1,82 @@
+package scope_test
+
+import (
+	"compass/scope"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	legalKey := "Zone=0b9a2f1e-5c3d-4b2a-8e7f-6d5c4b3a2f1e"
+	a := scope.SPScope{
+		Clients: []scope.Client{scope.ClientSSI},
+		Permissions: map[string]scope.Permission{
+			"Zone=" + financeId.String(): {Get: true, Delete: true},
+			"Zone=" + hrId.String():      {All: true},
+		},
+	}
+	b := scope.SPScope{
+		Clients: []scope.Client{scope.ClientSSI, "Other"},
+		Permissions: map[string]scope.Permission{
+			"Zone=" + financeId.String(): {Get: true, Modify: true},
+			legalKey:                     {List: true},
+		},
+	}
+
+	d := scope.Diff(a, b)
+	if d.Empty() {
+		t.Fatal("expected a difference")
+	}
+	if len(d.Added) != 1 || d.Added[0].Key() != legalKey {
+		t.Errorf("expected %s to be added, got %+v", legalKey, d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Ref != hrId.String() {
+		t.Errorf("expected HR to be removed, got %+v", d.Removed)
+	}
+	if len(d.Changed) != 1 {
+		t.Fatalf("expected one change, got %+v", d.Changed)
+	}
+	if !reflect.DeepEqual(d.Changed[0].Granted(), []string{"modify"}) {
+		t.Errorf("expected modify to be granted, got %v", d.Changed[0].Granted())
+	}
+	if !reflect.DeepEqual(d.Changed[0].Revoked(), []string{"delete"}) {
+		t.Errorf("expected delete to be revoked, got %v", d.Changed[0].Revoked())
+	}
+	if !reflect.DeepEqual(d.AddedClients, []scope.Client{"Other"}) || len(d.RemovedClients) != 0 {
+		t.Errorf("unexpected client changes %+v, %+v", d.AddedClients, d.RemovedClients)
+	}
+}
+
+func TestDiff_Equal(t *testing.T) {
+	s := scope.SPScope{
+		Clients:     []scope.Client{scope.ClientSSI},
+		Permissions: map[string]scope.Permission{"Zone=" + financeId.String(): {Get: true}},
+	}
+	if d := scope.Diff(s, s); !d.Empty() {
+		t.Errorf("expected no difference, got %+v", d)
+	}
+}
+
+func TestDiff_LimitationsAndExtensions(t *testing.T) {
+	a := scope.SPScope{Limitations: map[string]interface{}{}}
+	a.SetLimitation(scope.MaxUsesLimitation{Uses: 3})
+	a.SetLimitation(scope.IPRangeLimitation{Ranges: []string{"10.0.0.0/8"}})
+	b := scope.SPScope{
+		Limitations: map[string]interface{}{
+			"ipRange": map[string]interface{}{"ranges": []interface{}{"10.0.0.0/8"}},
+			"maxUses": map[string]interface{}{"uses": 5},
+		},
+		Extensions: map[string]interface{}{"audit": true},
+	}
+
+	d := scope.Diff(a, b)
+	if len(d.Limitations) != 1 || d.Limitations[0].Key != "maxUses" || d.Limitations[0].Old == nil || d.Limitations[0].New == nil {
+		t.Errorf("expected only max uses to change, got %+v", d.Limitations)
+	}
+	if len(d.Extensions) != 1 || d.Extensions[0].Key != "audit" || d.Extensions[0].Old != nil {
+		t.Errorf("expected the extension to be added, got %+v", d.Extensions)
+	}
+	if d := scope.Diff(b, scope.SPScope{Extensions: b.Extensions}); len(d.Limitations) != 2 || d.Limitations[0].New != nil {
+		t.Errorf("expected both limitations to be removed, got %+v", d.Limitations)
+	}
+}
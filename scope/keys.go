@@ -0,0 +1,58 @@
+package scope
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// The permission key for a whole zone, Zone=<id>
+func ZoneKey(zoneId UUID) string {
+	return "Zone=" + zoneId.String()
+}
+
+// The permission key for a file or folder in a zone, Path=<zone id>:<path>
+func PathKey(zoneId UUID, path string) string {
+	return "Path=" + zoneId.String() + ":" + path
+}
+
+// Split a path permission key into its zone id and path
+func ParsePathKey(key string) (string, string, bool) {
+	ref, ok := strings.CutPrefix(key, "Path=")
+	if !ok {
+		return "", "", false
+	}
+	return splitPathRef(ref)
+}
+
+// Split the reference of a path rule, <zone>:/<path>, where zone is an id or a name
+func splitPathRef(ref string) (string, string, bool) {
+	zone, path, ok := strings.Cut(ref, ":/")
+	if !ok || zone == "" {
+		return "", "", false
+	}
+	return zone, "/" + path, true
+}
+
+// The ids of every zone that the permissions refer to, through zone or path keys
+func PermissionZones(perms map[string]Permission) []UUID {
+	ids := []UUID{}
+	seen := map[UUID]bool{}
+	for _, r := range RulesFromPermissions(perms) {
+		ref := r.Ref
+		switch r.Kind {
+		case "Zone":
+		case "Path":
+			ref, _, _ = splitPathRef(r.Ref)
+		default:
+			continue
+		}
+		id, err := uuid.Parse(ref)
+		if err != nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
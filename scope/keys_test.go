@@ -0,0 +1,36 @@
+package scope_test
+
+import (
+	"compass/scope"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParsePathKey(t *testing.T) {
+	zone, path, ok := scope.ParsePathKey(scope.PathKey(financeId, "/a/b.txt"))
+	if !ok || zone != financeId.String() || path != "/a/b.txt" {
+		t.Errorf("unexpected zone %s and path %s", zone, path)
+	}
+	if _, _, ok := scope.ParsePathKey(scope.ZoneKey(financeId)); ok {
+		t.Error("expected zone key not to be a path key")
+	}
+}
+
+func TestPermissionZones(t *testing.T) {
+	perms := map[string]scope.Permission{
+		scope.ZoneKey(financeId):        {Get: true},
+		scope.PathKey(financeId, "/a"):  {Get: true},
+		scope.PathKey(hrId, "/Payroll"): {List: true},
+		"Other=" + uuid.New().String():  {All: true},
+	}
+	ids := scope.PermissionZones(perms)
+	if len(ids) != 2 {
+		t.Fatalf("expected two zones, got %v", ids)
+	}
+	for _, id := range ids {
+		if id != financeId && id != hrId {
+			t.Errorf("unexpected zone %s", id)
+		}
+	}
+}
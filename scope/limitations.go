@@ -0,0 +1,193 @@
+package scope
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// A typed entry in SPScope.Limitations
+type Limitation interface {
+	// The key the limitation is stored under in SPScope.Limitations
+	LimitationKey() string
+	Validate() error
+}
+
+// A typed entry in SPScope.Extensions
+type Extension interface {
+	// The key the extension is stored under in SPScope.Extensions
+	ExtensionKey() string
+	Validate() error
+}
+
+// Only allow the token to be used from these addresses. Entries are CIDR prefixes or single addresses
+type IPRangeLimitation struct {
+	Ranges []string `json:"ranges"`
+}
+
+func (l IPRangeLimitation) LimitationKey() string {
+	return "ipRange"
+}
+
+func (l IPRangeLimitation) Validate() error {
+	if len(l.Ranges) == 0 {
+		return fmt.Errorf("IP range needs at least one address or prefix")
+	}
+	for _, r := range l.Ranges {
+		if _, err := netip.ParsePrefix(r); err == nil {
+			continue
+		}
+		if _, err := netip.ParseAddr(r); err != nil {
+			return fmt.Errorf("%q is not an address or CIDR prefix", r)
+		}
+	}
+	return nil
+}
+
+// Only allow the token to be used between Start and End every day. Times are written as 15:04.
+// A window where End is before Start spans midnight
+type TimeWindowLimitation struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+const TimeWindowLayout = "15:04"
+
+func (l TimeWindowLimitation) LimitationKey() string {
+	return "timeWindow"
+}
+
+func (l TimeWindowLimitation) Validate() error {
+	start, err := time.Parse(TimeWindowLayout, l.Start)
+	if err != nil {
+		return fmt.Errorf("time window start %q is not a time like 08:00", l.Start)
+	}
+	end, err := time.Parse(TimeWindowLayout, l.End)
+	if err != nil {
+		return fmt.Errorf("time window end %q is not a time like 17:00", l.End)
+	}
+	if start.Equal(end) {
+		return fmt.Errorf("time window start and end can't be the same")
+	}
+	if l.Timezone != "" {
+		if _, err := time.LoadLocation(l.Timezone); err != nil {
+			return fmt.Errorf("unknown time zone %q", l.Timezone)
+		}
+	}
+	return nil
+}
+
+// Only allow the token to be used a number of times
+type MaxUsesLimitation struct {
+	Uses int64 `json:"uses"`
+}
+
+func (l MaxUsesLimitation) LimitationKey() string {
+	return "maxUses"
+}
+
+func (l MaxUsesLimitation) Validate() error {
+	if l.Uses <= 0 {
+		return fmt.Errorf("max uses must be a positive number")
+	}
+	return nil
+}
+
+// Validate a limitation and store it in the scope
+func (s *SPScope) SetLimitation(l Limitation) error {
+	if s.Limitations == nil {
+		s.Limitations = map[string]interface{}{}
+	}
+	return setEntry(s.Limitations, l.LimitationKey(), l)
+}
+
+// Read a limitation from the scope into l. Returns false if the scope doesn't have one of that kind
+func (s SPScope) GetLimitation(l Limitation) (bool, error) {
+	return getEntry(s.Limitations, l.LimitationKey(), l)
+}
+
+// Validate an extension and store it in the scope
+func (s *SPScope) SetExtension(e Extension) error {
+	if s.Extensions == nil {
+		s.Extensions = map[string]interface{}{}
+	}
+	return setEntry(s.Extensions, e.ExtensionKey(), e)
+}
+
+// Read an extension from the scope into e. Returns false if the scope doesn't have one of that kind
+func (s SPScope) GetExtension(e Extension) (bool, error) {
+	return getEntry(s.Extensions, e.ExtensionKey(), e)
+}
+
+func setEntry(entries map[string]interface{}, key string, v interface{ Validate() error }) error {
+	if err := v.Validate(); err != nil {
+		return err
+	}
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var value interface{}
+	if err := json.Unmarshal(jsonData, &value); err != nil {
+		return err
+	}
+	entries[key] = value
+	return nil
+}
+
+func getEntry(entries map[string]interface{}, key string, v any) (bool, error) {
+	value, ok := entries[key]
+	if !ok {
+		return false, nil
+	}
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		return true, err
+	}
+	if err := json.Unmarshal(jsonData, v); err != nil {
+		return true, fmt.Errorf("Could not read %s :: %+v", key, err)
+	}
+	return true, nil
+}
+
+// Only allow the token to be used together with these members, for short lived collaboration
+type CollaboratorsLimitation struct {
+	Members []Collaborator `json:"members"`
+}
+
+type Collaborator struct {
+	MemberId    string `json:"memberId"`
+	DisplayName string `json:"displayName,omitempty"`
+	MemberType  string `json:"memberType,omitempty"`
+}
+
+func (l CollaboratorsLimitation) LimitationKey() string {
+	return "collaborators"
+}
+
+func (l CollaboratorsLimitation) Validate() error {
+	if len(l.Members) == 0 {
+		return fmt.Errorf("choose at least one coworker")
+	}
+	for _, m := range l.Members {
+		if m.MemberId == "" {
+			return fmt.Errorf("coworker %s has no member id", m.DisplayName)
+		}
+	}
+	return nil
+}
+
+// Create a collaborators limitation from zone members
+func NewCollaboratorsLimitation(members []ZoneMember) CollaboratorsLimitation {
+	l := CollaboratorsLimitation{Members: []Collaborator{}}
+	for _, m := range members {
+		l.Members = append(l.Members, Collaborator{
+			MemberId:    m.MemberId,
+			DisplayName: m.DisplayName,
+			MemberType:  m.MemberType,
+		})
+	}
+	return l
+}
@@ -0,0 +1,83 @@
+package scope_test
+
+import (
+	"compass/scope"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLimitations_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		limitation scope.Limitation
+		valid      bool
+	}{
+		{"IP prefix", scope.IPRangeLimitation{Ranges: []string{"10.0.0.0/8", "2001:db8::/32"}}, true},
+		{"Single IP", scope.IPRangeLimitation{Ranges: []string{"192.168.1.10"}}, true},
+		{"No IP ranges", scope.IPRangeLimitation{}, false},
+		{"Invalid IP", scope.IPRangeLimitation{Ranges: []string{"10.0.0/8"}}, false},
+		{"Time window", scope.TimeWindowLimitation{Start: "08:00", End: "17:00"}, true},
+		{"Time window over midnight", scope.TimeWindowLimitation{Start: "22:00", End: "06:00", Timezone: "UTC"}, true},
+		{"Invalid time", scope.TimeWindowLimitation{Start: "8am", End: "17:00"}, false},
+		{"Empty time window", scope.TimeWindowLimitation{Start: "08:00", End: "08:00"}, false},
+		{"Unknown time zone", scope.TimeWindowLimitation{Start: "08:00", End: "17:00", Timezone: "Mars/Olympus"}, false},
+		{"Max uses", scope.MaxUsesLimitation{Uses: 5}, true},
+		{"Zero max uses", scope.MaxUsesLimitation{}, false},
+		{"Collaborators", scope.NewCollaboratorsLimitation([]scope.ZoneMember{{MemberId: "1", DisplayName: "Ada"}}), true},
+		{"No collaborators", scope.NewCollaboratorsLimitation(nil), false},
+		{"Collaborator without id", scope.NewCollaboratorsLimitation([]scope.ZoneMember{{DisplayName: "Ada"}}), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.limitation.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestSPScope_Limitations(t *testing.T) {
+	s := scope.SPScope{}
+	if err := s.SetLimitation(scope.IPRangeLimitation{Ranges: []string{"10.0.0.0/8"}}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := s.SetLimitation(scope.MaxUsesLimitation{Uses: 3}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := s.SetLimitation(scope.MaxUsesLimitation{Uses: -1}); err == nil {
+		t.Error("expected invalid limitation to be rejected")
+	}
+
+	jsonData, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.Contains(string(jsonData), `"limitations":{"ipRange":{"ranges":["10.0.0.0/8"]},"maxUses":{"uses":3}}`) {
+		t.Errorf("unexpected json %s", jsonData)
+	}
+
+	decoded := scope.SPScope{}
+	json.Unmarshal(jsonData, &decoded)
+
+	ipRange := scope.IPRangeLimitation{}
+	if ok, err := decoded.GetLimitation(&ipRange); !ok || err != nil {
+		t.Fatalf("expected ip range, got %v, %v", ok, err)
+	}
+	if !reflect.DeepEqual(ipRange.Ranges, []string{"10.0.0.0/8"}) {
+		t.Errorf("unexpected ip range %+v", ipRange)
+	}
+	maxUses := scope.MaxUsesLimitation{}
+	if ok, _ := decoded.GetLimitation(&maxUses); !ok || maxUses.Uses != 3 {
+		t.Errorf("unexpected max uses %+v", maxUses)
+	}
+	if ok, _ := decoded.GetLimitation(&scope.TimeWindowLimitation{}); ok {
+		t.Error("expected no time window")
+	}
+}
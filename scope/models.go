@@ -75,3 +75,11 @@ type ZoneData struct {
 	TypeName             string      `json:"typeName,omitempty"`
 	WebAccessible        bool        `json:"webAccessible,omitempty"`
 }
+
+type FileEntry struct {
+	Name     string  `json:"name"`
+	Path     string  `json:"path"`
+	IsFolder bool    `json:"isFolder,omitempty"`
+	Size     *int64  `json:"size,omitempty"`
+	Modified *string `json:"modified,omitempty"`
+}
@@ -27,3 +27,48 @@ func CreatePermission(conf byte) Permission {
 	}
 	return data
 }
+
+var permissionNames = []struct {
+	name string
+	flag uint8
+}{
+	{"all", S_ALL},
+	{"access", S_ACCESS},
+	{"create", S_CREATE},
+	{"delete", S_DELETE},
+	{"get", S_GET},
+	{"list", S_LIST},
+	{"modify", S_MODIFY},
+}
+
+// Look up the flag for a permission by its lower case name, e.g. "get"
+func PermissionFlag(name string) (uint8, bool) {
+	for _, p := range permissionNames {
+		if p.name == name {
+			return p.flag, true
+		}
+	}
+	return 0, false
+}
+
+// Get the flags that are set on a permission. The inverse of CreatePermission
+func PermissionConf(p Permission) byte {
+	conf := byte(0)
+	for _, n := range PermissionNames(p) {
+		flag, _ := PermissionFlag(n)
+		conf |= flag
+	}
+	return conf
+}
+
+// Get the names of the options that are set on a permission, in flag order
+func PermissionNames(p Permission) []string {
+	set := []bool{p.All, p.Access, p.Create, p.Delete, p.Get, p.List, p.Modify}
+	names := []string{}
+	for i, n := range permissionNames {
+		if set[i] {
+			names = append(names, n.name)
+		}
+	}
+	return names
+}
@@ -2,6 +2,7 @@ package scope_test
 
 import (
 	"compass/scope"
+	"strings"
 	"testing"
 )
 
@@ -106,3 +107,39 @@ func TestCreatePermission(t *testing.T) {
 		})
 	}
 }
+
+func TestPermissionNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     byte
+		expected []string
+	}{
+		{"No permissions set", 0, []string{}},
+		{"Get and List", scope.S_GET | scope.S_LIST, []string{"get", "list"}},
+		{"All and Modify", scope.S_ALL | scope.S_MODIFY, []string{"all", "modify"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := scope.CreatePermission(tt.conf)
+			names := scope.PermissionNames(p)
+			if strings.Join(names, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("expected %v, got %v", tt.expected, names)
+			}
+			for _, n := range names {
+				if _, ok := scope.PermissionFlag(n); !ok {
+					t.Errorf("expected %s to be a known permission", n)
+				}
+			}
+			if conf := scope.PermissionConf(p); conf != tt.conf {
+				t.Errorf("expected conf %b, got %b", tt.conf, conf)
+			}
+		})
+	}
+}
+
+func TestPermissionFlag_Unknown(t *testing.T) {
+	if _, ok := scope.PermissionFlag("read"); ok {
+		t.Error("expected read to be an unknown permission")
+	}
+}
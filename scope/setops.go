@@ -0,0 +1,181 @@
+package scope
+
+// Every option that All stands for
+const allOptions = S_ACCESS | S_CREATE | S_DELETE | S_GET | S_LIST | S_MODIFY
+
+// The options of a permission with All expanded into the options it stands for
+func expandedConf(p Permission) byte {
+	conf := PermissionConf(p)
+	if ConfHasOpt(conf, S_ALL) {
+		conf |= allOptions
+	}
+	return conf
+}
+
+// Everything that either permission allows
+func (p Permission) Union(o Permission) Permission {
+	return CreatePermission(PermissionConf(p) | PermissionConf(o))
+}
+
+// Everything that both permissions allow
+func (p Permission) Intersect(o Permission) Permission {
+	if p.All && o.All {
+		return CreatePermission(PermissionConf(p)&PermissionConf(o) | S_ALL)
+	}
+	return CreatePermission(expandedConf(p) & expandedConf(o) &^ S_ALL)
+}
+
+// Everything that p allows but o doesn't. All is expanded when o takes something away from it
+func (p Permission) Subtract(o Permission) Permission {
+	if o.All {
+		return Permission{}
+	}
+	if PermissionConf(o) == 0 {
+		return p
+	}
+	return CreatePermission(expandedConf(p) &^ PermissionConf(o) &^ S_ALL)
+}
+
+func (p Permission) Empty() bool {
+	return p == Permission{}
+}
+
+// A scope with everything that either scope allows.
+//
+// Clients are combined. A limitation is only kept when both scopes have it, widened to allow what either allows:
+// IP ranges and collaborators are combined and the larger number of uses is kept. Other limitations are
+// dropped when they differ. Extensions from both scopes are kept, s wins when both set the same key
+func (s SPScope) Union(o SPScope) SPScope {
+	res := s.derive(o)
+	res.Clients = unionClients(s.Clients, o.Clients)
+	res.Limitations = unionLimitations(s, o)
+	for k, p := range s.Permissions {
+		res.Permissions[k] = p
+	}
+	for k, p := range o.Permissions {
+		res.Permissions[k] = res.Permissions[k].Union(p)
+	}
+	return res
+}
+
+// A scope with what both scopes allow.
+//
+// Only clients in both scopes are kept. Limitations and extensions from both scopes are kept, s wins when both set the same key
+func (s SPScope) Intersect(o SPScope) SPScope {
+	res := s.derive(o)
+	res.Clients = subtractClients(s.Clients, subtractClients(s.Clients, o.Clients))
+	for k, p := range s.Permissions {
+		if op, ok := o.Permissions[k]; ok {
+			if i := p.Intersect(op); !i.Empty() {
+				res.Permissions[k] = i
+			}
+		}
+	}
+	return res
+}
+
+// A scope with everything s allows but o doesn't.
+//
+// Clients, limitations and extensions are the ones from s
+func (s SPScope) Subtract(o SPScope) SPScope {
+	res := s.derive(SPScope{})
+	res.Clients = append([]Client{}, s.Clients...)
+	for k, p := range s.Permissions {
+		if d := p.Subtract(o.Permissions[k]); !d.Empty() {
+			res.Permissions[k] = d
+		}
+	}
+	return res
+}
+
+// A new scope with the name and description of s, and the limitations and extensions of both
+func (s SPScope) derive(o SPScope) SPScope {
+	return SPScope{
+		Name:        s.Name,
+		Description: s.Description,
+		Permissions: map[string]Permission{},
+		Limitations: mergeMaps(s.Limitations, o.Limitations),
+		Extensions:  mergeMaps(s.Extensions, o.Extensions),
+	}
+}
+
+func mergeMaps(a map[string]interface{}, b map[string]interface{}) map[string]interface{} {
+	if a == nil && b == nil {
+		return nil
+	}
+	res := map[string]interface{}{}
+	for k, v := range b {
+		res[k] = v
+	}
+	for k, v := range a {
+		res[k] = v
+	}
+	return res
+}
+
+func unionClients(a []Client, b []Client) []Client {
+	return append(append([]Client{}, a...), subtractClients(b, a)...)
+}
+
+// The limitations both scopes have, widened to allow what either of them allows. See Union
+func unionLimitations(s SPScope, o SPScope) map[string]interface{} {
+	if s.Limitations == nil && o.Limitations == nil {
+		return nil
+	}
+	res := SPScope{Limitations: map[string]interface{}{}}
+	for key, value := range s.Limitations {
+		other, ok := o.Limitations[key]
+		if !ok {
+			continue
+		}
+		var widened Limitation
+		switch key {
+		case IPRangeLimitation{}.LimitationKey():
+			a, b := IPRangeLimitation{}, IPRangeLimitation{}
+			if readBoth(s, o, &a, &b) && len(a.Ranges) > 0 && len(b.Ranges) > 0 {
+				a.Ranges = append(a.Ranges, subtractNames(b.Ranges, a.Ranges)...)
+				widened = a
+			}
+		case MaxUsesLimitation{}.LimitationKey():
+			a, b := MaxUsesLimitation{}, MaxUsesLimitation{}
+			if readBoth(s, o, &a, &b) && a.Uses > 0 && b.Uses > 0 {
+				widened = MaxUsesLimitation{Uses: max(a.Uses, b.Uses)}
+			}
+		case CollaboratorsLimitation{}.LimitationKey():
+			a, b := CollaboratorsLimitation{}, CollaboratorsLimitation{}
+			if readBoth(s, o, &a, &b) && len(a.Members) > 0 && len(b.Members) > 0 {
+				for _, m := range b.Members {
+					if !hasCollaborator(a.Members, m.MemberId) {
+						a.Members = append(a.Members, m)
+					}
+				}
+				widened = a
+			}
+		}
+		if widened != nil {
+			if res.SetLimitation(widened) == nil {
+				continue
+			}
+		}
+		if sameEntry(value, other) {
+			res.Limitations[key] = value
+		}
+	}
+	return res.Limitations
+}
+
+// Read the same kind of limitation from both scopes
+func readBoth(s SPScope, o SPScope, a Limitation, b Limitation) bool {
+	_, errA := s.GetLimitation(a)
+	_, errB := o.GetLimitation(b)
+	return errA == nil && errB == nil
+}
+
+func hasCollaborator(members []Collaborator, id string) bool {
+	for _, m := range members {
+		if m.MemberId == id {
+			return true
+		}
+	}
+	return false
+}
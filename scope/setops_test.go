@@ -0,0 +1,129 @@
+package scope_test
+
+import (
+	"compass/scope"
+	"reflect"
+	"testing"
+)
+
+func TestPermission_SetOperations(t *testing.T) {
+	all := scope.Permission{All: true}
+	getList := scope.Permission{Get: true, List: true}
+	listModify := scope.Permission{List: true, Modify: true}
+
+	tests := []struct {
+		name     string
+		result   scope.Permission
+		expected scope.Permission
+	}{
+		{"Union", getList.Union(listModify), scope.Permission{Get: true, List: true, Modify: true}},
+		{"Union with All", getList.Union(all), scope.Permission{All: true, Get: true, List: true}},
+		{"Intersect", getList.Intersect(listModify), scope.Permission{List: true}},
+		{"Intersect with All", all.Intersect(getList), getList},
+		{"Intersect All with All", all.Intersect(all), all},
+		{"Subtract", getList.Subtract(listModify), scope.Permission{Get: true}},
+		{"Subtract All", getList.Subtract(all), scope.Permission{}},
+		{"Subtract from All", all.Subtract(getList), scope.Permission{Access: true, Create: true, Delete: true, Modify: true}},
+		{"Subtract nothing from All", all.Subtract(scope.Permission{}), all},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.result != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, tt.result)
+			}
+		})
+	}
+}
+
+func TestSPScope_SetOperations(t *testing.T) {
+	financeKey := "Zone=" + financeId.String()
+	hrKey := "Zone=" + hrId.String()
+	a := scope.SPScope{
+		Name:        "a",
+		Clients:     []scope.Client{scope.ClientSSI},
+		Limitations: map[string]interface{}{"ip": "10.0.0.0/8"},
+		Permissions: map[string]scope.Permission{
+			financeKey: {All: true},
+			hrKey:      {Get: true},
+		},
+	}
+	b := scope.SPScope{
+		Name:       "b",
+		Clients:    []scope.Client{scope.ClientSSI, "Other"},
+		Extensions: map[string]interface{}{"note": "b"},
+		Permissions: map[string]scope.Permission{
+			financeKey: {Get: true},
+		},
+	}
+
+	union := a.Union(b)
+	if union.Name != "a" || !union.Permissions[financeKey].All || !union.Permissions[hrKey].Get {
+		t.Errorf("unexpected union %+v", union)
+	}
+	if !reflect.DeepEqual(union.Clients, []scope.Client{scope.ClientSSI, "Other"}) {
+		t.Errorf("expected clients to be combined, got %v", union.Clients)
+	}
+	if _, ok := union.Limitations["ip"]; ok || union.Extensions["note"] != "b" {
+		t.Errorf("expected a limitation of one scope to be dropped and extensions to be kept, got %+v, %+v", union.Limitations, union.Extensions)
+	}
+
+	intersection := a.Intersect(b)
+	expected := map[string]scope.Permission{financeKey: {Get: true}}
+	if !reflect.DeepEqual(intersection.Permissions, expected) {
+		t.Errorf("expected %+v, got %+v", expected, intersection.Permissions)
+	}
+	if !reflect.DeepEqual(intersection.Clients, []scope.Client{scope.ClientSSI}) {
+		t.Errorf("expected only shared clients, got %v", intersection.Clients)
+	}
+
+	difference := a.Subtract(b)
+	if _, ok := difference.Permissions[hrKey]; !ok || difference.Permissions[financeKey].Get || difference.Permissions[financeKey].All {
+		t.Errorf("unexpected difference %+v", difference.Permissions)
+	}
+	if !difference.Permissions[financeKey].Modify {
+		t.Errorf("expected All in a to be expanded, got %+v", difference.Permissions[financeKey])
+	}
+	if difference.Limitations["ip"] != "10.0.0.0/8" || difference.Extensions != nil {
+		t.Errorf("expected limitations and extensions from a, got %+v, %+v", difference.Limitations, difference.Extensions)
+	}
+
+	if d := b.Subtract(a); len(d.Permissions) != 0 {
+		t.Errorf("expected nothing left, got %+v", d.Permissions)
+	}
+}
+
+func TestSPScope_UnionLimitations(t *testing.T) {
+	a, b := scope.SPScope{}, scope.SPScope{}
+	a.SetLimitation(scope.IPRangeLimitation{Ranges: []string{"10.0.0.0/8"}})
+	b.SetLimitation(scope.IPRangeLimitation{Ranges: []string{"192.168.1.0/24", "10.0.0.0/8"}})
+	a.SetLimitation(scope.MaxUsesLimitation{Uses: 3})
+	b.SetLimitation(scope.MaxUsesLimitation{Uses: 5})
+	a.SetLimitation(scope.CollaboratorsLimitation{Members: []scope.Collaborator{{MemberId: "alice"}}})
+	b.SetLimitation(scope.CollaboratorsLimitation{Members: []scope.Collaborator{{MemberId: "bob"}, {MemberId: "alice"}}})
+	a.SetLimitation(scope.TimeWindowLimitation{Start: "08:00", End: "17:00"})
+	b.SetLimitation(scope.TimeWindowLimitation{Start: "12:00", End: "20:00"})
+
+	union := a.Union(b)
+	ranges := scope.IPRangeLimitation{}
+	if _, err := union.GetLimitation(&ranges); err != nil || !reflect.DeepEqual(ranges.Ranges, []string{"10.0.0.0/8", "192.168.1.0/24"}) {
+		t.Errorf("expected the ranges of both, got %+v, %v", ranges, err)
+	}
+	uses := scope.MaxUsesLimitation{}
+	if _, err := union.GetLimitation(&uses); err != nil || uses.Uses != 5 {
+		t.Errorf("expected the larger number of uses, got %+v, %v", uses, err)
+	}
+	collaborators := scope.CollaboratorsLimitation{}
+	if _, err := union.GetLimitation(&collaborators); err != nil || len(collaborators.Members) != 2 {
+		t.Errorf("expected the members of both, got %+v, %v", collaborators, err)
+	}
+	if ok, _ := union.GetLimitation(&scope.TimeWindowLimitation{}); ok {
+		t.Errorf("expected differing time windows to be dropped, got %+v", union.Limitations)
+	}
+
+	same := scope.SPScope{}
+	same.SetLimitation(scope.TimeWindowLimitation{Start: "08:00", End: "17:00"})
+	if ok, _ := same.Union(same).GetLimitation(&scope.TimeWindowLimitation{}); !ok {
+		t.Error("expected an equal time window to be kept")
+	}
+}
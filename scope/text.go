@@ -0,0 +1,274 @@
+package scope
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// One entry in the text form of a scope, e.g. zone:Finance get,list
+//
+// Kind and Ref map to the permission key Kind=Ref. Zones can be referenced by name instead of id,
+// both in zone rules and in path rules like path:Finance:/Reports
+type Rule struct {
+	Kind       string
+	Ref        string
+	Permission Permission
+}
+
+// Text form names of the permission key kinds SSI knows about
+var ruleKinds = map[string]string{
+	"zone": "Zone",
+	"path": "Path",
+}
+
+const noPermissions = "none"
+
+func (r Rule) Key() string {
+	return r.Kind + "=" + r.Ref
+}
+
+func (r Rule) String() string {
+	kind := r.Kind
+	if _, ok := ruleKinds[strings.ToLower(kind)]; ok {
+		kind = strings.ToLower(kind)
+	}
+	perms := strings.Join(PermissionNames(r.Permission), ",")
+	if perms == "" {
+		perms = noPermissions
+	}
+	return kind + ":" + quoteRef(r.Ref) + " " + perms
+}
+
+func quoteRef(ref string) string {
+	quoted := strconv.Quote(ref)
+	if ref == "" || quoted != `"`+ref+`"` || strings.ContainsAny(ref, " ;") {
+		return quoted
+	}
+	return ref
+}
+
+// Format rules with one rule per line
+func FormatRules(rules []Rule) string {
+	lines := make([]string, len(rules))
+	for i, r := range rules {
+		lines[i] = r.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Parse the text form of a scope. Rules are separated by newlines or semicolons.
+//
+//	zone:Finance get,list
+//	zone:"Human Resources" all; zone:7c9e6679-7425-40de-944b-e07fc1f90ae7 get
+//	path:Finance:/Reports/2024 get
+func ParseRules(text string) ([]Rule, error) {
+	p := ruleParser{text: text}
+	rules := []Rule{}
+	for {
+		p.skip(func(r rune) bool { return unicode.IsSpace(r) || r == ';' })
+		if p.done() {
+			return rules, nil
+		}
+		rule, err := p.rule()
+		if err != nil {
+			return rules, fmt.Errorf("Could not parse scope at %d :: %+v", p.pos, err)
+		}
+		rules = append(rules, rule)
+	}
+}
+
+type ruleParser struct {
+	text string
+	pos  int
+}
+
+func (p *ruleParser) done() bool {
+	return p.pos >= len(p.text)
+}
+
+func (p *ruleParser) skip(match func(rune) bool) string {
+	start := p.pos
+	for !p.done() {
+		r, size := utf8.DecodeRuneInString(p.text[p.pos:])
+		if !match(r) {
+			break
+		}
+		p.pos += size
+	}
+	return p.text[start:p.pos]
+}
+
+func (p *ruleParser) rule() (Rule, error) {
+	rule := Rule{}
+	kind := p.skip(unicode.IsLetter)
+	if kind == "" || p.done() || p.text[p.pos] != ':' {
+		return rule, fmt.Errorf("expected kind:reference")
+	}
+	p.pos++
+	rule.Kind = kind
+	if canonical, ok := ruleKinds[strings.ToLower(kind)]; ok {
+		rule.Kind = canonical
+	}
+
+	if !p.done() && p.text[p.pos] == '"' {
+		quoted, err := strconv.QuotedPrefix(p.text[p.pos:])
+		if err != nil {
+			return rule, fmt.Errorf("unterminated quote")
+		}
+		p.pos += len(quoted)
+		rule.Ref, _ = strconv.Unquote(quoted)
+	} else {
+		rule.Ref = p.skip(func(r rune) bool { return !unicode.IsSpace(r) && r != ';' })
+		if rule.Ref == "" {
+			return rule, fmt.Errorf("missing reference for %s", kind)
+		}
+	}
+
+	p.skip(func(r rune) bool { return r == ' ' || r == '\t' })
+	perms := p.skip(func(r rune) bool { return r != '\n' && r != ';' })
+	conf, err := parsePermissionList(perms)
+	if err != nil {
+		return rule, err
+	}
+	rule.Permission = CreatePermission(conf)
+	return rule, nil
+}
+
+func parsePermissionList(list string) (byte, error) {
+	list = strings.TrimSpace(list)
+	if list == "" {
+		return 0, fmt.Errorf("missing permissions")
+	}
+	if strings.EqualFold(list, noPermissions) {
+		return 0, nil
+	}
+	conf := byte(0)
+	for _, name := range strings.Split(list, ",") {
+		flag, ok := PermissionFlag(strings.ToLower(strings.TrimSpace(name)))
+		if !ok {
+			return 0, fmt.Errorf("unknown permission %q", strings.TrimSpace(name))
+		}
+		conf |= flag
+	}
+	return conf, nil
+}
+
+// Turn scope permissions into rules, sorted by key. References are the ids from the keys
+func RulesFromPermissions(perms map[string]Permission) []Rule {
+	keys := make([]string, 0, len(perms))
+	for k := range perms {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	rules := make([]Rule, len(keys))
+	for i, k := range keys {
+		kind, ref, _ := strings.Cut(k, "=")
+		rules[i] = Rule{Kind: kind, Ref: ref, Permission: perms[k]}
+	}
+	return rules
+}
+
+// Turn rules into scope permissions. Zone references that aren't ids are looked up by name in zones
+func RulesToPermissions(rules []Rule, zones []ZoneData) (map[string]Permission, error) {
+	perms := map[string]Permission{}
+	for _, r := range rules {
+		switch r.Kind {
+		case "Zone":
+			zone, err := findZone(r.Ref, zones)
+			if err != nil {
+				return perms, err
+			}
+			r.Ref = zone
+		case "Path":
+			zoneRef, path, ok := splitPathRef(r.Ref)
+			if !ok {
+				return perms, fmt.Errorf("path %s should look like <zone>:/<path>", r.Ref)
+			}
+			zone, err := findZone(zoneRef, zones)
+			if err != nil {
+				return perms, err
+			}
+			r.Ref = zone + ":" + path
+		}
+		if _, ok := perms[r.Key()]; ok {
+			return perms, fmt.Errorf("%s is listed more than once", r.Key())
+		}
+		perms[r.Key()] = r.Permission
+	}
+	return perms, nil
+}
+
+func findZone(ref string, zones []ZoneData) (string, error) {
+	for _, z := range zones {
+		if strings.EqualFold(z.Id.String(), ref) {
+			return z.Id.String(), nil
+		}
+	}
+	found := ""
+	for _, z := range zones {
+		if z.Name == ref {
+			if found != "" {
+				return "", fmt.Errorf("zone name %s is ambiguous, use its id", ref)
+			}
+			found = z.Id.String()
+		}
+	}
+	if found == "" {
+		if id, err := uuid.Parse(ref); err == nil {
+			return id.String(), nil
+		}
+		return "", fmt.Errorf("no zone named %s", ref)
+	}
+	return found, nil
+}
+
+// Replace zone ids in rules with the names of the zones, where the name is unique
+func NameRules(rules []Rule, zones []ZoneData) []Rule {
+	count := map[string]int{}
+	for _, z := range zones {
+		count[z.Name]++
+	}
+	named := make([]Rule, len(rules))
+	for i, r := range rules {
+		named[i] = r
+		zoneRef, path := r.Ref, ""
+		switch r.Kind {
+		case "Zone":
+		case "Path":
+			var ok bool
+			if zoneRef, path, ok = splitPathRef(r.Ref); !ok {
+				continue
+			}
+			path = ":" + path
+		default:
+			continue
+		}
+		for _, z := range zones {
+			if strings.EqualFold(z.Id.String(), zoneRef) && z.Name != "" && count[z.Name] == 1 {
+				named[i].Ref = z.Name + path
+			}
+		}
+	}
+	return named
+}
+
+// Parse the text form of a scope into permissions, resolving zone names against zones
+func ParseScope(text string, zones []ZoneData) (map[string]Permission, error) {
+	rules, err := ParseRules(text)
+	if err != nil {
+		return nil, err
+	}
+	return RulesToPermissions(rules, zones)
+}
+
+// Format permissions in the text form, naming zones found in zones
+func FormatScope(perms map[string]Permission, zones []ZoneData) string {
+	return FormatRules(NameRules(RulesFromPermissions(perms), zones))
+}
@@ -0,0 +1,184 @@
+package scope_test
+
+import (
+	"compass/scope"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+var (
+	financeId = uuid.MustParse("7c9e6679-7425-40de-944b-e07fc1f90ae7")
+	hrId      = uuid.MustParse("4f1c2a8e-3b7d-4e5f-9a6b-1c2d3e4f5a6b")
+	testZones = []scope.ZoneData{
+		{Name: "Finance", Id: financeId},
+		{Name: "Human Resources", Id: hrId},
+	}
+)
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected []scope.Rule
+	}{
+		{
+			name: "Single rule",
+			text: "zone:Finance get,list",
+			expected: []scope.Rule{
+				{Kind: "Zone", Ref: "Finance", Permission: scope.Permission{Get: true, List: true}},
+			},
+		},
+		{
+			name: "Quoted reference and separators",
+			text: "zone:\"Human Resources\" all;\n\nZONE:" + financeId.String() + " Get, Modify ",
+			expected: []scope.Rule{
+				{Kind: "Zone", Ref: "Human Resources", Permission: scope.Permission{All: true}},
+				{Kind: "Zone", Ref: financeId.String(), Permission: scope.Permission{Get: true, Modify: true}},
+			},
+		},
+		{
+			name: "No permissions",
+			text: "zone:Finance none",
+			expected: []scope.Rule{
+				{Kind: "Zone", Ref: "Finance"},
+			},
+		},
+		{
+			name:     "Empty",
+			text:     " ; \n",
+			expected: []scope.Rule{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := scope.ParseRules(tt.text)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(rules, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, rules)
+			}
+		})
+	}
+}
+
+func TestParseRules_Errors(t *testing.T) {
+	tests := []string{
+		"Finance get",
+		"zone: get",
+		"zone:Finance",
+		"zone:Finance read",
+		"zone:\"Finance get",
+		":Finance get",
+	}
+	for _, text := range tests {
+		if _, err := scope.ParseRules(text); err == nil {
+			t.Errorf("expected error for %q, got nil", text)
+		}
+	}
+}
+
+func TestFormatRules(t *testing.T) {
+	rules := []scope.Rule{
+		{Kind: "Zone", Ref: "Human Resources", Permission: scope.Permission{All: true}},
+		{Kind: "Zone", Ref: "Finance", Permission: scope.Permission{Get: true, List: true}},
+		{Kind: "Zone", Ref: "Empty"},
+	}
+	expected := "zone:\"Human Resources\" all\nzone:Finance get,list\nzone:Empty none"
+	if text := scope.FormatRules(rules); text != expected {
+		t.Errorf("expected %q, got %q", expected, text)
+	}
+}
+
+func TestParseScope(t *testing.T) {
+	perms, err := scope.ParseScope("zone:Finance get; zone:\"Human Resources\" all", testZones)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !perms["Zone="+financeId.String()].Get || !perms["Zone="+hrId.String()].All {
+		t.Errorf("unexpected permissions %+v", perms)
+	}
+
+	if _, err := scope.ParseScope("zone:Legal get", testZones); err == nil {
+		t.Error("expected error for unknown zone, got nil")
+	}
+	if _, err := scope.ParseScope("zone:Finance get; zone:"+financeId.String()+" all", testZones); err == nil {
+		t.Error("expected error for duplicate zone, got nil")
+	}
+	unknown := uuid.New()
+	perms, err = scope.ParseScope("zone:"+unknown.String()+" get", nil)
+	if err != nil || !perms["Zone="+unknown.String()].Get {
+		t.Errorf("expected ids to be accepted without zones, got %+v, %v", perms, err)
+	}
+}
+
+func TestScope_RoundTrip(t *testing.T) {
+	perms := map[string]scope.Permission{
+		"Zone=" + financeId.String(): {Get: true, List: true},
+		"Zone=" + hrId.String():      {All: true, Delete: true},
+	}
+
+	text := scope.FormatScope(perms, testZones)
+	if !strings.Contains(text, "zone:Finance get,list") || !strings.Contains(text, "zone:\"Human Resources\" all,delete") {
+		t.Errorf("expected zone names in %q", text)
+	}
+
+	parsed, err := scope.ParseScope(text, testZones)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(parsed, perms) {
+		t.Errorf("expected %+v, got %+v", perms, parsed)
+	}
+
+	parsed, err = scope.ParseScope(scope.FormatScope(perms, nil), nil)
+	if err != nil || !reflect.DeepEqual(parsed, perms) {
+		t.Errorf("expected %+v without zone names, got %+v, %v", perms, parsed, err)
+	}
+}
+
+func FuzzParseRules(f *testing.F) {
+	f.Add("zone:Finance get,list")
+	f.Add("zone:\"Human Resources\" all; zone:" + financeId.String() + " none")
+	f.Add("zone:\"a\\\"b;c\" modify\n")
+	f.Fuzz(func(t *testing.T, text string) {
+		rules, err := scope.ParseRules(text)
+		if err != nil {
+			return
+		}
+		formatted := scope.FormatRules(rules)
+		again, err := scope.ParseRules(formatted)
+		if err != nil {
+			t.Fatalf("could not parse formatted rules %q: %v", formatted, err)
+		}
+		if !reflect.DeepEqual(rules, again) {
+			t.Errorf("round trip of %q gave %+v, expected %+v", formatted, again, rules)
+		}
+	})
+}
+
+func TestParseScope_Paths(t *testing.T) {
+	perms, err := scope.ParseScope("path:Finance:/Reports/2024 get; path:\""+hrId.String()+":/Payroll run.xlsx\" get,modify", testZones)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	reports := scope.PathKey(financeId, "/Reports/2024")
+	payroll := scope.PathKey(hrId, "/Payroll run.xlsx")
+	if !perms[reports].Get || !perms[payroll].Modify {
+		t.Errorf("unexpected permissions %+v", perms)
+	}
+
+	text := scope.FormatScope(perms, testZones)
+	expected := "path:\"Human Resources:/Payroll run.xlsx\" get,modify\npath:Finance:/Reports/2024 get"
+	if text != expected {
+		t.Errorf("expected %q, got %q", expected, text)
+	}
+
+	if _, err := scope.ParseScope("path:Finance get", testZones); err == nil {
+		t.Error("expected error for a path without zone, got nil")
+	}
+}
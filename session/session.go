@@ -5,8 +5,12 @@ import (
 )
 
 type Session struct {
-	Token     string `json:"token"`
-	Validity  string `json:"validity"`
+	Token    string `json:"token"`
+	Validity string `json:"validity"`
+	// Who signed in
+	User string `json:"user,omitempty"`
+	// The host the session is for
+	Host      string `json:"host,omitempty"`
 	persister StorageAdaper
 }
 
@@ -20,6 +24,8 @@ type SessionInterface interface {
 	Load() error
 	GetToken() string
 	SetToken(string)
+	GetUser() string
+	SetUser(string)
 }
 
 // Attempts to open a file with the given sessionPath and write the model as json to it. Will create the file if it doesn't exist.
@@ -55,6 +61,16 @@ func (s *Session) GetToken() string {
 	return s.Token
 }
 
+// Mutate the model to set the user that signed in
+func (s *Session) SetUser(user string) {
+	s.User = user
+}
+
+// Get the user that signed in
+func (s *Session) GetUser() string {
+	return s.User
+}
+
 // Initialize session
 func New(options ...func(*Session)) *Session {
 	s := &Session{
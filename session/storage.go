@@ -5,12 +5,44 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 )
 
 type FileStorage string
 
+// File storage in the users config directory, e.g. ~/.config/compass/session.json.
+// The directory is created if it doesn't exist.
+func DefaultStorage() (FileStorage, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return PathStorage(filepath.Join(dir, "compass", "session.json"))
+}
+
+// File storage for a named profile, e.g. ~/.config/compass/sessions/prod.json, so that
+// signing in to one profile doesn't sign out of another. The directory is created if it doesn't exist.
+func ProfileStorage(profile string) (FileStorage, error) {
+	if profile == "" || filepath.Base(profile) != profile {
+		return "", fmt.Errorf("Invalid profile name %q", profile)
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return PathStorage(filepath.Join(dir, "compass", "sessions", profile+".json"))
+}
+
+// File storage at path. The directory is created if it doesn't exist.
+func PathStorage(path string) (FileStorage, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	return FileStorage(path), nil
+}
+
 func (p FileStorage) Save(s *Session) error {
-	file, err := os.OpenFile(string(p), os.O_CREATE|os.O_WRONLY, 0600)
+	file, err := os.OpenFile(string(p), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
 	handleErr := func(err error) error {
 		return fmt.Errorf("Could not serialize session :: %+v", err)
 	}
@@ -48,3 +80,28 @@ func (p FileStorage) Load(s *Session) error {
 	}
 	return nil
 }
+
+// Storage that keeps the host with the session and only loads sessions for that host,
+// so that a session isn't sent to another host, e.g. when -h points a profile elsewhere
+type HostStorage struct {
+	Store StorageAdaper
+	Host  string
+}
+
+func (h HostStorage) Save(s *Session) error {
+	s.Host = h.Host
+	return h.Store.Save(s)
+}
+
+func (h HostStorage) Load(s *Session) error {
+	stored := &Session{}
+	if err := h.Store.Load(stored); err != nil {
+		return err
+	}
+	if stored.Host != h.Host {
+		return fmt.Errorf("Stored session is for %s, not %s", stored.Host, h.Host)
+	}
+	stored.persister = s.persister
+	*s = *stored
+	return nil
+}
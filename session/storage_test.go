@@ -3,6 +3,7 @@ package session
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -85,3 +86,81 @@ func TestFileStorage_Save_InvalidPath(t *testing.T) {
 
 	// Make sure the program doesn't crash and logs an error
 }
+
+func TestFileStorage_Save_Truncates(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "session_test_*.json")
+	if err != nil {
+		t.Fatalf("unable to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	storage := FileStorage(tmpFile.Name())
+	storage.Save(&Session{Token: "a-much-longer-token-than-the-next"})
+	storage.Save(&Session{Token: "short"})
+
+	loadedSession := &Session{}
+	if err := storage.Load(loadedSession); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if loadedSession.Token != "short" {
+		t.Errorf("expected Token to be short, got %s", loadedSession.Token)
+	}
+}
+
+func TestDefaultStorage(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	storage, err := DefaultStorage()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if filepath.Base(string(storage)) != "session.json" {
+		t.Errorf("expected session.json, got %s", storage)
+	}
+	if _, err := os.Stat(filepath.Dir(string(storage))); err != nil {
+		t.Errorf("expected directory to be created, got %v", err)
+	}
+}
+
+func TestProfileStorage(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	prod, err := ProfileStorage("prod")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	staging, _ := ProfileStorage("staging")
+	def, _ := DefaultStorage()
+	if prod == staging || prod == def {
+		t.Errorf("expected a session file per profile, got %s, %s and %s", prod, staging, def)
+	}
+
+	prod.Save(&Session{Token: "prod-token"})
+	loaded := &Session{}
+	staging.Load(loaded)
+	if loaded.Token != "" {
+		t.Errorf("expected profiles not to share sessions, got %s", loaded.Token)
+	}
+
+	if _, err := ProfileStorage("../prod"); err == nil {
+		t.Error("expected error for a profile name with a path in it")
+	}
+}
+
+func TestHostStorage(t *testing.T) {
+	path := FileStorage(filepath.Join(t.TempDir(), "session.json"))
+	prod := HostStorage{Store: path, Host: "https://prod.example.com"}
+	if err := prod.Save(&Session{Token: "prod-session"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	s := New(WithStore(prod))
+	if err := s.Load(); err != nil || s.GetToken() != "prod-session" {
+		t.Errorf("expected the session for the host, got %+v, %v", s, err)
+	}
+	s = New(WithStore(HostStorage{Store: path, Host: "https://staging.example.com"}))
+	if err := s.Load(); err == nil || s.GetToken() != "" {
+		t.Errorf("expected no session for another host, got %+v, %v", s, err)
+	}
+}
@@ -0,0 +1,136 @@
+package agentapproval
+
+import (
+	"compass/agent"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	promptStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Bold(true)
+	grantedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	deniedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	helpStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+)
+
+// How many decisions are shown
+const LOG_SIZE = 10
+
+// A request waiting for the user
+type pending struct {
+	request agent.Request
+	reply   chan bool
+}
+
+type Model struct {
+	queue []pending
+	log   []string
+}
+
+// Asks the user to approve agent requests in the TUI
+type Approver struct {
+	program *tea.Program
+	done    chan struct{}
+}
+
+func New() *Approver {
+	return &Approver{
+		program: tea.NewProgram(Model{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Show the TUI until the user quits. Requests made after that are denied
+func (a *Approver) Run() error {
+	defer close(a.done)
+	_, err := a.program.Run()
+	return err
+}
+
+// Wait for the user to decide on r
+func (a *Approver) Approve(r agent.Request) bool {
+	reply := make(chan bool, 1)
+	go a.program.Send(pending{request: r, reply: reply})
+	select {
+	case ok := <-reply:
+		return ok
+	case <-a.done:
+		return false
+	}
+}
+
+func describe(r agent.Request) string {
+	validity := agent.DEFAULT_VALIDITY
+	if r.Validity > 0 {
+		validity = time.Duration(r.Validity) * time.Second
+	}
+	return fmt.Sprintf("%s wants %q for %s, valid for %s", r.Requester, r.Name, r.Scope, validity)
+}
+
+func (m Model) decide(ok bool) Model {
+	p := m.queue[0]
+	m.queue = m.queue[1:]
+	p.reply <- ok
+	line := grantedStyle.Render("granted")
+	if !ok {
+		line = deniedStyle.Render("denied ")
+	}
+	m.log = append(m.log, fmt.Sprintf("%s %s %s", time.Now().Format(time.TimeOnly), line, describe(p.request)))
+	if len(m.log) > LOG_SIZE {
+		m.log = m.log[len(m.log)-LOG_SIZE:]
+	}
+	return m
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case pending:
+		m.queue = append(m.queue, msg)
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q":
+			for len(m.queue) > 0 {
+				m = m.decide(false)
+			}
+			return m, tea.Quit
+		case "y":
+			if len(m.queue) > 0 {
+				m = m.decide(true)
+			}
+		case "n":
+			if len(m.queue) > 0 {
+				m = m.decide(false)
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m Model) View() string {
+	doc := strings.Builder{}
+	doc.WriteString("compass agent\n\n")
+	for _, line := range m.log {
+		doc.WriteString(line + "\n")
+	}
+	if len(m.log) > 0 {
+		doc.WriteString("\n")
+	}
+	if len(m.queue) == 0 {
+		doc.WriteString("Waiting for requests...\n")
+	} else {
+		doc.WriteString(promptStyle.Render(describe(m.queue[0].request)+". Allow?") + "\n")
+		if len(m.queue) > 1 {
+			doc.WriteString(fmt.Sprintf("%d more waiting\n", len(m.queue)-1))
+		}
+	}
+	doc.WriteString(helpStyle.Render("\ny allow • n deny • q quit"))
+	return doc.String()
+}
+
+func (m Model) Init() tea.Cmd {
+	return nil
+}
@@ -1,11 +1,14 @@
 package login
 
 import (
+	"compass/api"
+	"compass/app"
 	"compass/client"
-	"compass/global"
+	"compass/config"
 	"compass/scope"
 	"compass/session"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -37,54 +40,27 @@ type Model struct {
 	client        client.ClientInterface
 	session       session.SessionInterface
 	authReq       byte
+	// How long the session lasts without being used
+	timeout time.Duration
 }
 
-func New() tea.Model {
-	s := session.New()
-
-	c := client.NewClient(global.SSIHost,
-		client.WithHeader("Content-Type", "application/json"),
-	)
-
+// Sign in with the shared client, into the shared session
+func New(ctx *app.Context) tea.Model {
 	return Model{
-		session:       s,
-		client:        c,
+		session:       ctx.Session,
+		client:        ctx.Client,
 		authReq:       REQ_NONE,
 		selectedInput: 0,
+		timeout:       ctx.Config.SessionTimeoutOr(config.DEFAULT_SESSION_TIMEOUT),
 	}
 }
 
-type LogonRequest struct {
-	AcceptSessions   *bool          `json:"acceptSessions,omitempty"`
-	AutoLogon        *bool          `json:"autoLogon,omitempty"`
-	LogonSessionId   *string        `json:"logonSessionId,omitempty"`
-	OrganizationName *string        `json:"organizationName,omitempty"`
-	Password         *[]string      `json:"password,omitempty"`
-	PasswordAsString *string        `json:"passwordAsString,omitempty"`
-	SavePassword     *bool          `json:"savePassword,omitempty"`
-	Scope            *scope.SPScope `json:"scope,omitempty"`
-	SetCookie        *bool          `json:"setCookie,omitempty"`
-	Timeout          *int64         `json:"timeout,omitempty"`
-	TimeoutInSeconds *int64         `json:"timeoutInSeconds,omitempty"`
-	Token            *string        `json:"token,omitempty"`
-	Username         *string        `json:"username,omitempty"`
-	VerificationCode *string        `json:"verificationCode,omitempty"`
-}
-
-type LogonResult struct {
-	ExpiresInMillis          *int64  `json:"expiresInMillis,omitempty"`
-	IdpURI                   *string `json:"idpURI,omitempty"`
-	LogonOK                  bool    `json:"logonOK"`
-	PasswordChangeIsRequired *bool   `json:"passwordChangeIsRequired,omitempty"`
-	SessionId                *string `json:"sessionId,omitempty"`
-}
-
 func getSession(m Model) tea.Cmd {
 	defer m.session.Save()
 
-	credentials := LogonRequest{}
+	credentials := api.LogonRequest{}
 
-	validity := int64(60 * 15)
+	validity := int64(m.timeout / time.Second)
 	credentials.TimeoutInSeconds = &validity
 
 	for _, input := range m.inputs {
@@ -105,14 +81,17 @@ func getSession(m Model) tea.Cmd {
 		}
 	}
 
-	loginRes := LogonResult{}
-	if err := m.client.Post("/access/logon", credentials, &loginRes); err != nil {
+	loginRes, err := api.Logon(m.client, credentials)
+	if err != nil {
 		return func() tea.Msg {
 			return err
 		}
 	}
 
 	m.session.SetToken(*loginRes.SessionId)
+	if credentials.Username != nil {
+		m.session.SetUser(*credentials.Username)
+	}
 
 	return func() tea.Msg {
 		return m.session
@@ -153,19 +132,11 @@ func getInputs(m Model) tea.Cmd {
 }
 
 type AuthReq byte
-type AuthorizationRequirements struct {
-	CanSavePassword  *bool   `json:"canSavePassword,omitempty"`
-	OrganizationName *string `json:"organizationName,omitempty"`
-	OtpRequired      *bool   `json:"otpRequired,omitempty"`
-	OtpType          *string `json:"otpType,omitempty"`
-	PasswordRequired *bool   `json:"passwordRequired,omitempty"`
-	WorldName        *string `json:"worldName,omitempty"`
-}
 
 func getAuthReq(m Model) tea.Cmd {
 	authReq := byte(0)
-	authReqData := AuthorizationRequirements{}
-	if err := m.client.Get("/access/authorizationrequirements", &authReqData); err != nil {
+	authReqData, err := api.GetAuthorizationRequirements(m.client)
+	if err != nil {
 		return func() tea.Msg {
 			return err
 		}
@@ -0,0 +1,224 @@
+package memberpicker
+
+import (
+	"compass/api"
+	"compass/app"
+	"compass/bubbles/checkbox"
+	"compass/client"
+	"compass/scope"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type MemberSelect struct {
+	member scope.ZoneMember
+	input  checkbox.Model
+}
+
+// Sent when the members of a zone have been fetched
+type MembersLoaded struct {
+	zone    scope.UUID
+	members []scope.ZoneMember
+	err     error
+}
+
+type Model struct {
+	client    client.ClientInterface
+	zones     []scope.UUID
+	members   []MemberSelect
+	search    textinput.Model
+	searching bool
+	loading   int
+	cursor    int
+	err       error
+}
+
+var (
+	detailStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	errorStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+)
+
+// Pick coworkers among the members of the given zones
+func New(ctx *app.Context, zones []scope.UUID) Model {
+	search := textinput.New()
+	search.Prompt = "/"
+	return Model{
+		client:  ctx.Client,
+		zones:   zones,
+		search:  search,
+		loading: len(zones),
+	}
+}
+
+func loadMembers(c client.ClientInterface, zone scope.UUID) tea.Cmd {
+	return func() tea.Msg {
+		members, err := api.GetZoneMembers(c, zone)
+		return MembersLoaded{zone: zone, members: members, err: err}
+	}
+}
+
+// The members that match the search, by display name
+func (m Model) visible() []int {
+	query := strings.ToLower(m.search.Value())
+	res := []int{}
+	for i, s := range m.members {
+		if strings.Contains(strings.ToLower(s.member.DisplayName), query) {
+			res = append(res, i)
+		}
+	}
+	return res
+}
+
+// The members the user has chosen
+func (m Model) Selected() []scope.ZoneMember {
+	selected := []scope.ZoneMember{}
+	for _, s := range m.members {
+		if s.input.GetChecked() {
+			selected = append(selected, s.member)
+		}
+	}
+	return selected
+}
+
+// Whether the search input has focus, and should get every key
+func (m Model) Searching() bool {
+	return m.searching
+}
+
+// Check the given members, they are listed before their zones have loaded.
+// Members that don't belong to the zones stay listed, so they can be unchecked
+func (m Model) WithSelected(members []scope.ZoneMember) Model {
+	m = m.addMembers(members)
+	for i := range m.members {
+		for _, member := range members {
+			if m.members[i].member.MemberId == member.MemberId {
+				m.members[i].input.SetChecked(true)
+			}
+		}
+	}
+	return m
+}
+
+func (m Model) addMembers(members []scope.ZoneMember) Model {
+	for _, member := range members {
+		found := false
+		for i := range m.members {
+			if m.members[i].member.MemberId == member.MemberId {
+				// Keep the details of a loaded member over those of a preselected one
+				if member.AccessLevel != "" {
+					m.members[i].member = member
+					m.members[i].input.Label = member.DisplayName
+				}
+				found = true
+			}
+		}
+		if found || member.MemberId == "" {
+			continue
+		}
+		cb := checkbox.New()
+		cb.Label = member.DisplayName
+		m.members = append(m.members, MemberSelect{member: member, input: cb})
+	}
+	sort.SliceStable(m.members, func(i, j int) bool {
+		return strings.ToLower(m.members[i].member.DisplayName) < strings.ToLower(m.members[j].member.DisplayName)
+	})
+	return m
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case MembersLoaded:
+		m.loading--
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		return m.addMembers(msg.members), nil
+
+	case tea.KeyMsg:
+		if m.searching {
+			switch msg.String() {
+			case "enter":
+				m.searching = false
+				m.search.Blur()
+				return m, nil
+			case "esc":
+				m.searching = false
+				m.search.Blur()
+				m.search.SetValue("")
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.search, cmd = m.search.Update(msg)
+			m.cursor = 0
+			return m, cmd
+		}
+
+		visible := m.visible()
+		switch msg.String() {
+		case "/":
+			m.searching = true
+			return m, m.search.Focus()
+		case "j", "down", "tab":
+			m.cursor = min(m.cursor+1, len(visible)-1)
+		case "k", "up", "shift+tab":
+			m.cursor = max(m.cursor-1, 0)
+		case " ":
+			if m.cursor >= 0 && m.cursor < len(visible) {
+				selected := &m.members[visible[m.cursor]].input
+				selected.SetChecked(!selected.GetChecked())
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m Model) View() string {
+	doc := strings.Builder{}
+	if m.loading > 0 {
+		doc.WriteString("Loading members...\n")
+	}
+	for i, index := range m.visible() {
+		s := m.members[index]
+		s.input.Blur()
+		if i == m.cursor {
+			s.input.Focus()
+		}
+		details := fmt.Sprintf(" %s, %s", s.member.MemberType, s.member.AccessLevel)
+		doc.WriteString(s.input.View() + detailStyle.Render(details) + "\n")
+	}
+	if m.searching || m.search.Value() != "" {
+		doc.WriteString("\n" + m.search.View() + "\n")
+	}
+	if m.err != nil {
+		doc.WriteString(errorStyle.Render(m.err.Error()) + "\n")
+	}
+	return doc.String()
+}
+
+func (m Model) Init() tea.Cmd {
+	cmds := []tea.Cmd{}
+	for _, zone := range m.zones {
+		cmds = append(cmds, loadMembers(m.client, zone))
+	}
+	return tea.Batch(cmds...)
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
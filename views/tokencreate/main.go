@@ -1,11 +1,18 @@
 package tokencreate
 
 import (
+	"compass/api"
+	"compass/app"
+	"compass/bubbles/checkbox"
+	"compass/bubbles/scopediff"
 	"compass/client"
 	"compass/scope"
+	"compass/views/memberpicker"
 	"compass/views/zoneselector"
+	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -19,22 +26,132 @@ const (
 	INPUT_NAME     InputName = "NAME"
 	INPUT_DESC     InputName = "DESC"
 	INPUT_VALIDITY InputName = "VALIDITY"
+
+	INPUT_IP_RANGE    InputName = "IP_RANGE"
+	INPUT_TIME_WINDOW InputName = "TIME_WINDOW"
+	INPUT_MAX_USES    InputName = "MAX_USES"
+)
+
+const (
+	STEP_DETAILS = iota
+	STEP_CLIENTS
+	STEP_MEMBERS
+	STEP_LIMITATIONS
 )
 
+var errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+
+// Sent when a token has been created
+type Created struct {
+	Name   string
+	Result scope.TokenResult
+}
+
 type Input struct {
 	model textinput.Model
 	name  InputName
 }
 
+type ClientSelect struct {
+	client scope.Client
+	input  checkbox.Model
+}
+
 type Model struct {
 	inputs        []Input
+	clients       []ClientSelect
+	members       memberpicker.Model
+	membersLoaded bool
+	limitInputs   []Input
+	step          int
+	err           error
 	selectedInput int
 	client        client.ClientInterface
 	authReq       byte
 	permissions   map[string]scope.Permission
+	source        *scope.SPScope
+	dropped       []scope.Client
+	zones         []scope.ZoneData
+}
+
+// Create the token from an existing scope. The form is prefilled with its name, description,
+// clients, coworkers and limitations, and shows how the new scope differs from it.
+// Clients of the source that aren't registered can't be selected, the form warns about them
+func WithSource(source scope.SPScope) func(*Model) {
+	return func(m *Model) {
+		m.source = &source
+		setInput(m.inputs, INPUT_NAME, source.Name)
+		setInput(m.inputs, INPUT_DESC, source.Description)
+		if len(source.Clients) > 0 {
+			WithClients(source.Clients)(m)
+		}
+		m.dropped = []scope.Client{}
+		for _, c := range source.Clients {
+			if _, ok := scope.LookupClient(c); !ok {
+				m.dropped = append(m.dropped, c)
+			}
+		}
+
+		collaborators := scope.CollaboratorsLimitation{}
+		if ok, _ := source.GetLimitation(&collaborators); ok {
+			members := []scope.ZoneMember{}
+			for _, c := range collaborators.Members {
+				members = append(members, scope.ZoneMember{MemberId: c.MemberId, DisplayName: c.DisplayName, MemberType: c.MemberType})
+			}
+			m.members = m.members.WithSelected(members)
+		}
+
+		ipRange := scope.IPRangeLimitation{}
+		if ok, _ := source.GetLimitation(&ipRange); ok {
+			setInput(m.limitInputs, INPUT_IP_RANGE, strings.Join(ipRange.Ranges, ", "))
+		}
+		timeWindow := scope.TimeWindowLimitation{}
+		if ok, _ := source.GetLimitation(&timeWindow); ok {
+			setInput(m.limitInputs, INPUT_TIME_WINDOW, strings.TrimSpace(timeWindow.Start+"-"+timeWindow.End+" "+timeWindow.Timezone))
+		}
+		maxUses := scope.MaxUsesLimitation{}
+		if ok, _ := source.GetLimitation(&maxUses); ok {
+			setInput(m.limitInputs, INPUT_MAX_USES, strconv.FormatInt(maxUses.Uses, 10))
+		}
+	}
+}
+
+// Prefill the validity
+func WithValidity(seconds int64) func(*Model) {
+	return func(m *Model) {
+		setInput(m.inputs, INPUT_VALIDITY, strconv.FormatInt(seconds, 10))
+	}
+}
+
+func setInput(inputs []Input, name InputName, value string) {
+	for i := range inputs {
+		if inputs[i].name == name {
+			inputs[i].model.SetValue(value)
+		}
+	}
+}
+
+// Clients that are selected when the form opens. Defaults to SynkzoneSSI
+func WithClients(clients []scope.Client) func(*Model) {
+	return func(m *Model) {
+		for i := range m.clients {
+			checked := false
+			for _, c := range clients {
+				checked = checked || c == m.clients[i].client
+			}
+			m.clients[i].input.SetChecked(checked)
+		}
+	}
+}
+
+// Zones used to show zone names instead of ids
+func WithZones(zones []scope.ZoneData) func(*Model) {
+	return func(m *Model) {
+		m.zones = zones
+	}
 }
 
-func New(c client.ClientInterface, perms zoneselector.PermissionCollection) tea.Model {
+func New(ctx *app.Context, perms zoneselector.PermissionCollection, options ...func(*Model)) tea.Model {
 	nameInput := textinput.New()
 	nameInput.Prompt = "Name: "
 	name := Input{
@@ -67,12 +184,62 @@ func New(c client.ClientInterface, perms zoneselector.PermissionCollection) tea.
 
 	inputs := []Input{name, desc, validity, pass}
 
-	return Model{
-		client:        c,
+	ipRangeInput := textinput.New()
+	ipRangeInput.Prompt = "Allowed IP ranges (comma separated): "
+	ipRangeInput.Placeholder = "10.0.0.0/8, 192.168.1.10"
+	ipRange := Input{
+		model: ipRangeInput,
+		name:  INPUT_IP_RANGE,
+	}
+
+	timeWindowInput := textinput.New()
+	timeWindowInput.Prompt = "Daily time window: "
+	timeWindowInput.Placeholder = "08:00-17:00 Europe/Stockholm"
+	timeWindow := Input{
+		model: timeWindowInput,
+		name:  INPUT_TIME_WINDOW,
+	}
+
+	maxUsesInput := textinput.New()
+	maxUsesInput.Prompt = "Max uses: "
+	maxUsesInput.Validate = intValidator
+	maxUses := Input{
+		model: maxUsesInput,
+		name:  INPUT_MAX_USES,
+	}
+
+	m := Model{
+		client:        ctx.Client,
 		selectedInput: 0,
 		inputs:        inputs,
+		limitInputs:   []Input{ipRange, timeWindow, maxUses},
+		step:          STEP_DETAILS,
 		permissions:   perms,
+		members:       memberpicker.New(ctx, scope.PermissionZones(perms)),
+	}
+	for _, info := range scope.Clients() {
+		cb := checkbox.New()
+		cb.Label = string(info.Client)
+		if info.Description != "" {
+			cb.Label += " (" + info.Description + ")"
+		}
+		cb.SetChecked(info.Client == scope.ClientSSI)
+		m.clients = append(m.clients, ClientSelect{
+			client: info.Client,
+			input:  cb,
+		})
+	}
+	// Defaults from the config. Options like WithSource override them
+	if clients, err := scope.ParseClients(ctx.Config.Clients); err == nil && len(clients) > 0 {
+		WithClients(clients)(&m)
+	}
+	if ctx.Config.Validity != "" {
+		WithValidity(int64(ctx.Config.ValidityOr(0) / time.Second))(&m)
 	}
+	for _, o := range options {
+		o(&m)
+	}
+	return m
 }
 
 func intValidator(s string) error {
@@ -80,11 +247,29 @@ func intValidator(s string) error {
 	return err
 }
 
-func createToken(m Model) tea.Cmd {
+func validateDetails(m Model) error {
+	for _, input := range m.inputs {
+		switch input.name {
+		case INPUT_NAME:
+			if strings.TrimSpace(input.model.Value()) == "" {
+				return fmt.Errorf("Name is required")
+			}
+		case INPUT_VALIDITY:
+			num, err := strconv.ParseInt(input.model.Value(), 10, 64)
+			if err != nil || num <= 0 {
+				return fmt.Errorf("Validity must be a positive number of seconds")
+			}
+		}
+	}
+	return nil
+}
+
+func tokenRequest(m Model) (scope.NewTokenRequest, error) {
 	tokenData := scope.NewTokenRequest{}
+	err := validateDetails(m)
 	s := scope.SPScope{}
 	s.Permissions = m.permissions
-	s.Clients = []scope.Client{"SynkzoneSSI"}
+	s.Clients = selectedClients(m)
 
 	for _, input := range m.inputs {
 		switch input.name {
@@ -106,10 +291,111 @@ func createToken(m Model) tea.Cmd {
 		}
 	}
 
+	for _, input := range m.limitInputs {
+		val := strings.TrimSpace(input.model.Value())
+		if val == "" || err != nil {
+			continue
+		}
+		l, parseErr := parseLimitation(input.name, val)
+		if parseErr != nil {
+			err = parseErr
+			continue
+		}
+		err = s.SetLimitation(l)
+	}
+
+	if m.source != nil {
+		carryOver(m.source, &s)
+	}
+
+	if coworkers := m.members.Selected(); err == nil && len(coworkers) > 0 {
+		err = s.SetLimitation(scope.NewCollaboratorsLimitation(coworkers))
+	}
+
+	if err == nil {
+		err = s.ValidateClients()
+	}
+
 	tokenData.Scope = s
+	return tokenData, err
+}
 
-	tokenRes := scope.TokenResult{}
-	err := m.client.Post("/tokens", tokenData, &tokenRes)
+// Limitations the form has inputs for. Other limitations of a source scope are kept as they are
+var formLimitations = map[string]bool{
+	scope.IPRangeLimitation{}.LimitationKey():       true,
+	scope.TimeWindowLimitation{}.LimitationKey():    true,
+	scope.MaxUsesLimitation{}.LimitationKey():       true,
+	scope.CollaboratorsLimitation{}.LimitationKey(): true,
+}
+
+// Copy the extensions and the limitations the form doesn't edit from source to s
+func carryOver(source *scope.SPScope, s *scope.SPScope) {
+	for key, l := range source.Limitations {
+		if formLimitations[key] {
+			continue
+		}
+		if s.Limitations == nil {
+			s.Limitations = map[string]interface{}{}
+		}
+		s.Limitations[key] = l
+	}
+	for key, e := range source.Extensions {
+		if s.Extensions == nil {
+			s.Extensions = map[string]interface{}{}
+		}
+		s.Extensions[key] = e
+	}
+}
+
+func selectedClients(m Model) []scope.Client {
+	clients := []scope.Client{}
+	for _, c := range m.clients {
+		if c.input.GetChecked() {
+			clients = append(clients, c.client)
+		}
+	}
+	return clients
+}
+
+func parseLimitation(name InputName, val string) (scope.Limitation, error) {
+	switch name {
+	case INPUT_IP_RANGE:
+		l := scope.IPRangeLimitation{}
+		for _, r := range strings.Split(val, ",") {
+			if r = strings.TrimSpace(r); r != "" {
+				l.Ranges = append(l.Ranges, r)
+			}
+		}
+		return l, nil
+	case INPUT_TIME_WINDOW:
+		l := scope.TimeWindowLimitation{}
+		fields := strings.Fields(val)
+		if len(fields) > 2 {
+			return l, fmt.Errorf("Time window should look like 08:00-17:00 with an optional time zone")
+		}
+		if len(fields) == 2 {
+			l.Timezone = fields[1]
+		}
+		l.Start, l.End, _ = strings.Cut(fields[0], "-")
+		return l, nil
+	case INPUT_MAX_USES:
+		num, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Max uses must be a number")
+		}
+		return scope.MaxUsesLimitation{Uses: num}, nil
+	}
+	return nil, fmt.Errorf("Unknown limitation %s", name)
+}
+
+func createToken(m Model) tea.Cmd {
+	tokenData, err := tokenRequest(m)
+	if err != nil {
+		return func() tea.Msg {
+			return err
+		}
+	}
+	tokenRes, err := api.CreateToken(m.client, tokenData)
 	if err != nil {
 		return func() tea.Msg {
 			return err
@@ -117,15 +403,62 @@ func createToken(m Model) tea.Cmd {
 	}
 
 	return func() tea.Msg {
-		return tokenRes
+		return Created{Name: *tokenData.TokenName, Result: tokenRes}
+	}
+}
+
+// The text inputs of the current step
+func (m Model) stepInputs() []Input {
+	switch m.step {
+	case STEP_CLIENTS, STEP_MEMBERS:
+		return []Input{}
+	case STEP_LIMITATIONS:
+		return m.limitInputs
+	}
+	return m.inputs
+}
+
+// The number of selectable items in the current step
+func (m Model) stepLen() int {
+	if m.step == STEP_CLIENTS {
+		return len(m.clients)
 	}
+	return len(m.stepInputs())
 }
 
 func (m Model) View() string {
 	form := strings.Builder{}
-	for _, i := range m.inputs {
+	switch m.step {
+	case STEP_CLIENTS:
+		form.WriteString("Clients, space to select. Esc to go back\n\n")
+		for _, c := range m.clients {
+			form.WriteString(c.input.View() + "\n")
+		}
+	case STEP_MEMBERS:
+		form.WriteString("Coworkers the token only works together with, space to select and / to search.\n")
+		form.WriteString("Leave empty for none. Esc to go back\n\n")
+		form.WriteString(m.members.View())
+	case STEP_LIMITATIONS:
+		form.WriteString("Limitations, leave empty for none. Esc to go back\n\n")
+	}
+	for _, i := range m.stepInputs() {
 		form.WriteString(i.model.View() + "\n")
 	}
+	if m.err != nil {
+		form.WriteString(errorStyle.Render(m.err.Error()) + "\n")
+	}
+	if len(m.dropped) > 0 {
+		names := []string{}
+		for _, c := range m.dropped {
+			names = append(names, string(c))
+		}
+		form.WriteString(errorStyle.Render(fmt.Sprintf("%s can't be selected since it isn't a registered client, the new token won't have it", strings.Join(names, ", "))) + "\n")
+	}
+	if m.source != nil {
+		tokenData, _ := tokenRequest(m)
+		changes := scopediff.Render(scope.Diff(*m.source, tokenData.Scope), m.zones)
+		form.WriteString("\nChanges from " + m.source.Name + ":\n" + changes + "\n")
+	}
 	return lipgloss.JoinVertical(
 		lipgloss.Top,
 		form.String(),
@@ -133,31 +466,100 @@ func (m Model) View() string {
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(memberpicker.MembersLoaded); ok {
+		var cmd tea.Cmd
+		m.members, cmd = m.members.Update(msg)
+		return m, cmd
+	}
+	if m.step == STEP_MEMBERS {
+		key, ok := msg.(tea.KeyMsg)
+		if !ok || m.members.Searching() || (key.String() != "enter" && key.String() != "esc") {
+			var cmd tea.Cmd
+			m.members, cmd = m.members.Update(msg)
+			return m, cmd
+		}
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "enter":
-			return m, createToken(m)
+			switch m.step {
+			case STEP_DETAILS:
+				m.err = validateDetails(m)
+			case STEP_CLIENTS:
+				s := scope.SPScope{Clients: selectedClients(m), Permissions: m.permissions}
+				m.err = s.ValidateClients()
+			case STEP_LIMITATIONS:
+				if _, m.err = tokenRequest(m); m.err != nil {
+					return m, nil
+				}
+				return m, createToken(m)
+			}
+			if m.err != nil {
+				return m, nil
+			}
+			m.step++
+			m.selectedInput = 0
+			if m.step == STEP_CLIENTS {
+				return m.updateClients(nil)
+			}
+			if m.step == STEP_MEMBERS {
+				if m.membersLoaded {
+					return m, nil
+				}
+				m.membersLoaded = true
+				return m, m.members.Init()
+			}
+		case "esc":
+			if m.step > STEP_DETAILS {
+				m.step--
+				m.selectedInput = 0
+				m.err = nil
+			}
+			if m.step == STEP_MEMBERS {
+				return m, nil
+			}
 		case "tab":
-			m.selectedInput = min(m.selectedInput+1, len(m.inputs)-1)
+			m.selectedInput = min(m.selectedInput+1, m.stepLen()-1)
 		case "shift+tab":
 			m.selectedInput = max(m.selectedInput-1, 0)
 		}
 	}
 
-	for index := range m.inputs {
+	if m.step == STEP_CLIENTS {
+		return m.updateClients(msg)
+	}
+
+	inputs := m.stepInputs()
+	for index := range inputs {
 		if index != m.selectedInput {
-			m.inputs[index].model.Blur()
+			inputs[index].model.Blur()
 		}
 	}
 
-	m.inputs[m.selectedInput].model.Focus()
+	inputs[m.selectedInput].model.Focus()
 
 	var cmd tea.Cmd
-	m.inputs[m.selectedInput].model, cmd = m.inputs[m.selectedInput].model.Update(msg)
+	inputs[m.selectedInput].model, cmd = inputs[m.selectedInput].model.Update(msg)
 	return m, cmd
 }
 
+func (m Model) updateClients(msg tea.Msg) (tea.Model, tea.Cmd) {
+	for index := range m.clients {
+		m.clients[index].input.Blur()
+	}
+	if len(m.clients) == 0 {
+		return m, nil
+	}
+	selected := &m.clients[m.selectedInput].input
+	selected.Focus()
+	if msg, ok := msg.(tea.KeyMsg); ok && msg.String() == " " {
+		selected.SetChecked(!selected.GetChecked())
+	}
+	return m, nil
+}
+
 func (m Model) Init() tea.Cmd {
 	return nil
 }
@@ -0,0 +1,36 @@
+package tokencreate
+
+import (
+	"compass/app"
+	"compass/scope"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestWithSource_Collaborators(t *testing.T) {
+	zone := uuid.New()
+	source := scope.SPScope{
+		Name:        "shared",
+		Clients:     []scope.Client{scope.ClientSSI, "RetiredClient"},
+		Permissions: map[string]scope.Permission{scope.ZoneKey(zone): scope.CreatePermission(scope.S_GET)},
+	}
+	source.SetLimitation(scope.CollaboratorsLimitation{Members: []scope.Collaborator{{MemberId: "m-1", DisplayName: "Alice"}}})
+
+	m := New(&app.Context{}, source.Permissions, WithSource(source), WithValidity(3600)).(Model)
+	if len(m.dropped) != 1 || m.dropped[0] != "RetiredClient" {
+		t.Errorf("expected RetiredClient to be dropped, got %v", m.dropped)
+	}
+
+	req, err := tokenRequest(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collaborators := scope.CollaboratorsLimitation{}
+	if ok, _ := req.Scope.GetLimitation(&collaborators); !ok {
+		t.Fatal("expected the collaborators limitation to be kept")
+	}
+	if len(collaborators.Members) != 1 || collaborators.Members[0].MemberId != "m-1" {
+		t.Errorf("expected member m-1, got %v", collaborators.Members)
+	}
+}
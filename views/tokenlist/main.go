@@ -0,0 +1,470 @@
+package tokenlist
+
+import (
+	"compass/api"
+	"compass/app"
+	"compass/client"
+	"compass/inventory"
+	"compass/registry"
+	"compass/scope"
+	"compass/views/tokencreate"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+type tokensMsg struct {
+	tokens []scope.TokenInformation
+	zones  []scope.ZoneData
+	err    error
+}
+
+// Sent when the user wants to create a new token like an existing one
+type Clone struct {
+	Scope scope.SPScope
+	// How long the existing token was valid for, zero when unknown
+	Validity time.Duration
+}
+
+// The outcome of an action on one token
+type result struct {
+	message string
+	err     error
+}
+
+type resultsMsg []result
+
+type rotatedMsg struct {
+	token  scope.TokenInformation
+	result scope.TokenResult
+	// What became of the old token, empty when it was left in place
+	revoke result
+}
+
+const (
+	ACTION_NONE = iota
+	ACTION_REVOKE
+	ACTION_ROTATE
+)
+
+type Model struct {
+	client     client.ClientInterface
+	tokens     []scope.TokenInformation
+	marked     map[string]bool
+	confirming int
+	results    []result
+	zones      []scope.ZoneData
+	sortKey    int
+	reverse    bool
+	filter     textinput.Model
+	filtering  bool
+	grace      textinput.Model
+	askGrace   bool
+	cursor     int
+	loading    bool
+	err        error
+}
+
+const SUMMARY_WIDTH = 48
+
+var (
+	selectedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("212")).Bold(true)
+	expiringStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+	expiredStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	headerStyle   = lipgloss.NewStyle().Bold(true)
+	cellStyle     = lipgloss.NewStyle().PaddingRight(2)
+	helpStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	errorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+)
+
+func New(ctx *app.Context) Model {
+	filter := textinput.New()
+	filter.Prompt = "/"
+	grace := textinput.New()
+	grace.Prompt = "Revoke the old token after (e.g. 0s or 1h, empty keeps it): "
+	return Model{
+		client:  ctx.Client,
+		filter:  filter,
+		grace:   grace,
+		marked:  map[string]bool{},
+		loading: true,
+	}
+}
+
+func loadTokens(c client.ClientInterface) tea.Cmd {
+	return func() tea.Msg {
+		tokens, err := api.ListTokens(c)
+		if err != nil {
+			return tokensMsg{err: err}
+		}
+		zones, err := api.GetZones(c)
+		return tokensMsg{tokens: tokens, zones: zones, err: err}
+	}
+}
+
+// The tokens that match the filter, in display order
+func (m Model) visible() []scope.TokenInformation {
+	tokens := inventory.Filter(m.tokens, m.filter.Value(), m.zones)
+	inventory.Sort(tokens, inventory.SortKeys[m.sortKey], m.reverse)
+	return tokens
+}
+
+// The token under the cursor
+func (m Model) Selected() (scope.TokenInformation, bool) {
+	visible := m.visible()
+	if m.cursor < 0 || m.cursor >= len(visible) {
+		return scope.TokenInformation{}, false
+	}
+	return visible[m.cursor], true
+}
+
+// The tokens an action applies to, the marked ones that match the filter or else the one under the cursor.
+// Marked tokens the filter hides are left alone, since the user can't see them
+func (m Model) targets() []scope.TokenInformation {
+	targets := []scope.TokenInformation{}
+	for _, t := range m.visible() {
+		if m.marked[inventory.Id(t)] {
+			targets = append(targets, t)
+		}
+	}
+	if len(targets) == 0 {
+		if t, ok := m.Selected(); ok {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+func revokeTokens(c client.ClientInterface, tokens []scope.TokenInformation) tea.Cmd {
+	return func() tea.Msg {
+		results := resultsMsg{}
+		for _, t := range tokens {
+			if err := api.RevokeToken(c, inventory.Id(t)); err != nil {
+				results = append(results, result{err: fmt.Errorf("Could not revoke %s :: %+v", inventory.Name(t), err)})
+				continue
+			}
+			results = append(results, result{message: fmt.Sprintf("Revoked %s", inventory.Name(t))})
+			if err := registry.Forget(inventory.Id(t)); err != nil {
+				results = append(results, result{err: err})
+			}
+		}
+		return results
+	}
+}
+
+// How long a rotated token is valid, the same as the token it replaces when known
+func rotateValidity(t scope.TokenInformation) time.Duration {
+	if lifetime, ok := inventory.Lifetime(t); ok {
+		return lifetime
+	}
+	return inventory.DefaultRotateValidity
+}
+
+// Create a new token with the scope of t. Like token rotate -revoke-after, a zero grace period revokes t right away,
+// a longer one marks it in the registry for token revoke -due and a negative one leaves it in place
+func rotateToken(c client.ClientInterface, t scope.TokenInformation, grace time.Duration) tea.Cmd {
+	return func() tea.Msg {
+		req, err := inventory.RotateRequest(t, rotateValidity(t))
+		if err != nil {
+			return resultsMsg{{err: err}}
+		}
+		res, err := api.CreateToken(c, req)
+		if err != nil {
+			return resultsMsg{{err: fmt.Errorf("Could not rotate %s :: %+v", inventory.Name(t), err)}}
+		}
+		msg := rotatedMsg{token: t, result: res}
+		switch {
+		case grace == 0:
+			if err := api.RevokeToken(c, inventory.Id(t)); err != nil {
+				msg.revoke.err = fmt.Errorf("Could not revoke %s :: %+v", inventory.Name(t), err)
+				break
+			}
+			msg.revoke.message = fmt.Sprintf("Revoked the old %s", inventory.Name(t))
+			if err := registry.Forget(inventory.Id(t)); err != nil {
+				msg.revoke.err = err
+			}
+		case grace > 0:
+			at := time.Now().Add(grace)
+			if err := registry.ScheduleRevoke(inventory.Id(t), inventory.Name(t), at); err != nil {
+				msg.revoke.err = fmt.Errorf("Could not schedule revoking %s :: %+v", inventory.Name(t), err)
+				break
+			}
+			msg.revoke.message = fmt.Sprintf("The old %s can be revoked from %s with compass token revoke -due", inventory.Name(t), at.Format(time.RFC3339))
+		}
+		return msg
+	}
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case resultsMsg:
+		m.results = msg
+		m.marked = map[string]bool{}
+		m.loading = true
+		return m, loadTokens(m.client)
+
+	case rotatedMsg:
+		m.results = []result{{message: fmt.Sprintf("Rotated %s, the new token is %s", inventory.Name(msg.token), msg.result.Id)}}
+		if msg.revoke.err != nil || msg.revoke.message != "" {
+			m.results = append(m.results, msg.revoke)
+		} else {
+			m.results = append(m.results, result{message: "Press d to revoke the old one"})
+		}
+		m.loading = true
+		return m, tea.Batch(
+			func() tea.Msg {
+				return tokencreate.Created{Name: inventory.Name(msg.token), Result: msg.result}
+			},
+			loadTokens(m.client),
+		)
+
+	case tokensMsg:
+		m.loading = false
+		m.err = msg.err
+		m.tokens = msg.tokens
+		m.zones = msg.zones
+		m.cursor = min(m.cursor, max(len(m.visible())-1, 0))
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.askGrace {
+			switch msg.String() {
+			case "enter":
+				grace := time.Duration(-1)
+				if value := strings.TrimSpace(m.grace.Value()); value != "" {
+					d, err := inventory.ParseDuration(value)
+					if err != nil {
+						m.results = []result{{err: err}}
+						return m, nil
+					}
+					grace = d
+				}
+				m.askGrace = false
+				m.grace.Blur()
+				if t, ok := m.Selected(); ok {
+					return m, rotateToken(m.client, t, grace)
+				}
+				return m, nil
+			case "esc":
+				m.askGrace = false
+				m.grace.Blur()
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.grace, cmd = m.grace.Update(msg)
+			return m, cmd
+		}
+
+		if m.confirming != ACTION_NONE {
+			action := m.confirming
+			m.confirming = ACTION_NONE
+			if msg.String() != "y" {
+				return m, nil
+			}
+			switch action {
+			case ACTION_REVOKE:
+				return m, revokeTokens(m.client, m.targets())
+			case ACTION_ROTATE:
+				m.askGrace = true
+				m.grace.SetValue("")
+				return m, m.grace.Focus()
+			}
+			return m, nil
+		}
+
+		if m.filtering {
+			switch msg.String() {
+			case "enter":
+				m.filtering = false
+				m.filter.Blur()
+				return m, nil
+			case "esc":
+				m.filtering = false
+				m.filter.Blur()
+				m.filter.SetValue("")
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.filter, cmd = m.filter.Update(msg)
+			m.cursor = 0
+			return m, cmd
+		}
+
+		switch msg.String() {
+		case "j", "down", "tab":
+			m.cursor = min(m.cursor+1, max(len(m.visible())-1, 0))
+		case "k", "up", "shift+tab":
+			m.cursor = max(m.cursor-1, 0)
+		case " ":
+			if t, ok := m.Selected(); ok {
+				id := inventory.Id(t)
+				m.marked[id] = !m.marked[id]
+			}
+		case "d":
+			m.results = nil
+			if len(m.targets()) > 0 {
+				m.confirming = ACTION_REVOKE
+			}
+		case "R":
+			m.results = nil
+			if _, ok := m.Selected(); ok {
+				m.confirming = ACTION_ROTATE
+			}
+		case "c":
+			t, ok := m.Selected()
+			if !ok {
+				return m, nil
+			}
+			if t.Scope == nil {
+				m.results = []result{{err: fmt.Errorf("%s has no scope to clone", inventory.Name(t))}}
+				return m, nil
+			}
+			clone := Clone{Scope: *t.Scope}
+			if clone.Scope.Name == "" {
+				clone.Scope.Name = inventory.Name(t)
+			}
+			clone.Validity, _ = inventory.Lifetime(t)
+			return m, func() tea.Msg {
+				return clone
+			}
+		case "s":
+			m.sortKey = (m.sortKey + 1) % len(inventory.SortKeys)
+		case "r":
+			m.reverse = !m.reverse
+		case "/":
+			m.filtering = true
+			return m, m.filter.Focus()
+		case "esc":
+			m.filter.SetValue("")
+		case "ctrl+r":
+			m.loading = true
+			return m, loadTokens(m.client)
+		}
+	}
+	return m, nil
+}
+
+func (m Model) View() string {
+	doc := strings.Builder{}
+	order := "ascending"
+	if m.reverse {
+		order = "descending"
+	}
+	doc.WriteString(fmt.Sprintf("Tokens, sorted by %s (%s)\n\n", inventory.SortKeys[m.sortKey], order))
+
+	if m.loading {
+		doc.WriteString("Loading...\n")
+	}
+
+	now := time.Now()
+	visible := m.visible()
+	statuses := make([]inventory.Status, len(visible))
+	rows := [][]string{}
+	for i, t := range visible {
+		statuses[i] = inventory.StatusOf(t, now, inventory.DefaultSoon)
+		clientId := "-"
+		if t.ClientIdentifier != nil {
+			clientId = *t.ClientIdentifier
+		}
+		mark := "  "
+		if m.marked[inventory.Id(t)] {
+			mark = "* "
+		}
+		rows = append(rows, []string{
+			mark + inventory.Name(t),
+			inventory.FormatTime(inventory.Created(t)),
+			inventory.FormatTime(inventory.Expires(t)),
+			statuses[i].String(),
+			clientId,
+			truncate(inventory.Summary(t.Scope, m.zones), SUMMARY_WIDTH),
+		})
+	}
+
+	if len(rows) > 0 {
+		t := table.New().
+			Border(lipgloss.HiddenBorder()).
+			Headers("  NAME", "CREATED", "VALID UNTIL", "STATUS", "CLIENT", "SCOPE").
+			Rows(rows...).
+			StyleFunc(func(row, col int) lipgloss.Style {
+				if row == 0 {
+					return headerStyle.Inherit(cellStyle)
+				}
+				style := cellStyle
+				switch statuses[row-1] {
+				case inventory.STATUS_EXPIRING:
+					style = expiringStyle.Inherit(cellStyle)
+				case inventory.STATUS_EXPIRED:
+					style = expiredStyle.Inherit(cellStyle)
+				}
+				if row-1 == m.cursor {
+					style = selectedStyle.Inherit(style)
+				}
+				return style
+			})
+		doc.WriteString(t.Render() + "\n")
+	} else if !m.loading {
+		doc.WriteString("No tokens\n")
+	}
+
+	if m.filtering || m.filter.Value() != "" {
+		doc.WriteString("\n" + m.filter.View() + "\n")
+	}
+	for _, r := range m.results {
+		if r.err != nil {
+			doc.WriteString(errorStyle.Render(r.err.Error()) + "\n")
+		} else {
+			doc.WriteString(r.message + "\n")
+		}
+	}
+	switch m.confirming {
+	case ACTION_REVOKE:
+		names := []string{}
+		for _, t := range m.targets() {
+			names = append(names, inventory.Name(t))
+		}
+		doc.WriteString(expiredStyle.Render(fmt.Sprintf("\nRevoke %s? y/n", strings.Join(names, ", "))) + "\n")
+	case ACTION_ROTATE:
+		if t, ok := m.Selected(); ok {
+			doc.WriteString(expiringStyle.Render(fmt.Sprintf("\nCreate a new token like %s, valid for %s? y/n", inventory.Name(t), rotateValidity(t))) + "\n")
+		}
+	}
+	if m.askGrace {
+		doc.WriteString("\n" + m.grace.View() + "\n")
+	}
+	if m.err != nil {
+		doc.WriteString(errorStyle.Render(m.err.Error()) + "\n")
+	}
+	doc.WriteString(helpStyle.Render("\nspace mark • d revoke • R rotate • c clone • s sort • r reverse • / filter • ctrl+r refresh • ctrl+t back"))
+	return doc.String()
+}
+
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+func (m Model) Init() tea.Cmd {
+	return loadTokens(m.client)
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,179 @@
+package tokenresult
+
+import (
+	"compass/clip"
+	"compass/inventory"
+	"compass/scope"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	labelStyle   = lipgloss.NewStyle().Bold(true).Width(12)
+	successStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	warningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+	errorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	helpStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+)
+
+// Sent when the user is done with the result screen
+type Done struct{}
+
+type clearedMsg struct {
+	// Which copy was cleared, so a newer copy isn't reported as cleared
+	copy int
+	err  error
+}
+
+type Model struct {
+	name   string
+	result scope.TokenResult
+	// Where the token was written
+	where string
+	// Why the token could not be written, if it wasn't
+	err error
+	// Clear the clipboard this long after copying, zero keeps it
+	clearAfter time.Duration
+	// Where OSC52 sequences are written when there is no system clipboard
+	terminal io.Writer
+
+	status    string
+	statusErr bool
+	copies    int
+}
+
+func WithClearAfter(d time.Duration) func(*Model) {
+	return func(m *Model) {
+		m.clearAfter = d
+	}
+}
+
+// The token could not be written, so copying it is the only way to keep it
+func WithError(err error) func(*Model) {
+	return func(m *Model) {
+		m.err = err
+	}
+}
+
+// The terminal the TUI is drawn on
+func WithTerminal(w io.Writer) func(*Model) {
+	return func(m *Model) {
+		m.terminal = w
+	}
+}
+
+func New(name string, result scope.TokenResult, where string, options ...func(*Model)) Model {
+	m := Model{
+		name:     name,
+		result:   result,
+		where:    where,
+		terminal: os.Stdout,
+	}
+	for _, o := range options {
+		o(&m)
+	}
+	return m
+}
+
+func (m Model) copy(what string, text string) (Model, tea.Cmd) {
+	method, err := clip.Copy(text, m.terminal)
+	if err != nil {
+		m.status = err.Error()
+		m.statusErr = true
+		return m, nil
+	}
+	m.copies++
+	m.statusErr = false
+	m.status = fmt.Sprintf("Copied %s to the %s", what, method)
+	if m.clearAfter <= 0 {
+		return m, nil
+	}
+	m.status += fmt.Sprintf(" (clears in %s)", m.clearAfter)
+
+	// Cleared by a command rather than in Update, so the clipboard is cleared even if the user has moved on.
+	// Copies that are still pending when compass quits are cleared by clip.ClearPending
+	copy, clear := m.copies, clip.ClearLater(method, text, m.terminal)
+	return m, tea.Tick(m.clearAfter, func(time.Time) tea.Msg {
+		return clearedMsg{copy: copy, err: clear()}
+	})
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case clearedMsg:
+		if msg.copy != m.copies {
+			return m, nil
+		}
+		if msg.err != nil {
+			m.status = msg.err.Error()
+			m.statusErr = true
+			return m, nil
+		}
+		m.status = "Cleared the clipboard"
+		m.statusErr = false
+		return m, nil
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "c":
+			return m.copy("token", m.result.Token)
+		case "i":
+			return m.copy("token id", m.result.Id)
+		case "enter", "esc":
+			return m, func() tea.Msg {
+				return Done{}
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m Model) View() string {
+	doc := strings.Builder{}
+	if m.err != nil {
+		doc.WriteString(warningStyle.Render("Token created, but it could not be written. Copy it now, it can't be shown again") + "\n")
+		doc.WriteString(errorStyle.Render(m.err.Error()) + "\n\n")
+	} else {
+		doc.WriteString(successStyle.Render("Token created") + "\n\n")
+	}
+
+	expires := "-"
+	if t, ok := inventory.ParseTime(m.result.ExpiresAt); ok {
+		left := time.Until(t)
+		if left > 0 {
+			expires = fmt.Sprintf("%s (%s left)", inventory.FormatTime(t, true), inventory.FormatRemaining(left))
+		} else {
+			expires = fmt.Sprintf("%s (expired)", inventory.FormatTime(t, true))
+		}
+	}
+	rows := [][2]string{
+		{"Name", m.name},
+		{"Id", m.result.Id},
+		{"Expires", expires},
+	}
+	if m.err == nil {
+		rows = append(rows, [2]string{"Written to", m.where})
+	}
+	for _, row := range rows {
+		doc.WriteString(labelStyle.Render(row[0]) + row[1] + "\n")
+	}
+
+	if m.status != "" {
+		style := successStyle
+		if m.statusErr {
+			style = errorStyle
+		}
+		doc.WriteString("\n" + style.Render(m.status) + "\n")
+	}
+	doc.WriteString(helpStyle.Render("\nc copy token • i copy id • enter done"))
+	return doc.String()
+}
+
+func (m Model) Init() tea.Cmd {
+	return nil
+}
@@ -0,0 +1,101 @@
+package tokenresult
+
+import (
+	"bytes"
+	"compass/clip"
+	"compass/scope"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var testResult = scope.TokenResult{Token: "secret", Id: "11111111-1111-1111-1111-111111111111"}
+
+// Copies go to the terminal as OSC52 sequences, so tests don't touch the system clipboard
+func newTestModel(t *testing.T, options ...func(*Model)) (Model, *bytes.Buffer) {
+	t.Setenv("SSH_TTY", "/dev/pts/1")
+	terminal := &bytes.Buffer{}
+	options = append([]func(*Model){WithTerminal(terminal)}, options...)
+	return New("backup", testResult, "token~backup.json", options...), terminal
+}
+
+func press(m Model, key string) (Model, tea.Cmd) {
+	model, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+	return model.(Model), cmd
+}
+
+func TestView(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []func(*Model)
+		expected []string
+		missing  []string
+	}{
+		{"written", nil, []string{"Token created", "backup", testResult.Id, "token~backup.json"}, []string{"could not be written"}},
+		{"not written", []func(*Model){WithError(errors.New("disk full"))}, []string{"could not be written", "disk full"}, []string{"token~backup.json"}},
+	}
+	for _, test := range tests {
+		m, _ := newTestModel(t, test.options...)
+		view := m.View()
+		for _, expected := range test.expected {
+			if !strings.Contains(view, expected) {
+				t.Errorf("%s: expected %q in %q", test.name, expected, view)
+			}
+		}
+		for _, missing := range test.missing {
+			if strings.Contains(view, missing) {
+				t.Errorf("%s: expected no %q in %q", test.name, missing, view)
+			}
+		}
+	}
+}
+
+func TestUpdate_Copy(t *testing.T) {
+	m, terminal := newTestModel(t)
+	m, cmd := press(m, "c")
+	if cmd != nil {
+		t.Error("expected no clear to be scheduled without -clear-clipboard")
+	}
+	if !strings.Contains(terminal.String(), "c2VjcmV0") || !strings.Contains(m.View(), "Copied token to the terminal clipboard") {
+		t.Errorf("expected the token to be copied, got %q", terminal.String())
+	}
+}
+
+func TestUpdate_ClearAfter(t *testing.T) {
+	m, terminal := newTestModel(t, WithClearAfter(time.Minute))
+	m, cmd := press(m, "c")
+	if cmd == nil || !strings.Contains(m.View(), "clears in 1m0s") {
+		t.Fatalf("expected a clear to be scheduled, got %q", m.View())
+	}
+	m, _ = press(m, "i")
+
+	// Only the latest copy is reported as cleared
+	model, _ := m.Update(clearedMsg{copy: 1})
+	if strings.Contains(model.View(), "Cleared the clipboard") {
+		t.Error("expected an older copy not to be reported as cleared")
+	}
+	model, _ = m.Update(clearedMsg{copy: 2})
+	if !strings.Contains(model.View(), "Cleared the clipboard") {
+		t.Errorf("expected the latest copy to be reported as cleared, got %q", model.View())
+	}
+
+	// Quitting before the copies are cleared
+	terminal.Reset()
+	if err := clip.ClearPending(); err != nil || strings.Count(terminal.String(), "52;c;!") != 2 {
+		t.Errorf("expected both copies to be cleared on quit, got %q, %v", terminal.String(), err)
+	}
+}
+
+func TestUpdate_Done(t *testing.T) {
+	m, _ := newTestModel(t)
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil {
+		t.Fatal("expected a command")
+	}
+	if _, ok := cmd().(Done); !ok {
+		t.Error("expected Done")
+	}
+}
@@ -0,0 +1,369 @@
+package zonebrowser
+
+import (
+	"compass/api"
+	"compass/app"
+	"compass/bubbles/permissioneditor"
+	"compass/client"
+	"compass/scope"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const ROOT = "/"
+
+type Node struct {
+	entry    scope.FileEntry
+	depth    int
+	expanded bool
+}
+
+// Sent when the user leaves the browser. Holds every path permission in the zone
+type PathPermissions struct {
+	Zone        scope.ZoneData
+	Permissions map[string]scope.Permission
+}
+
+type filesMsg struct {
+	zone  scope.UUID
+	path  string
+	files []scope.FileEntry
+	err   error
+}
+
+type Model struct {
+	client      client.ClientInterface
+	zone        scope.ZoneData
+	nodes       []Node
+	children    map[string][]scope.FileEntry
+	loading     map[string]bool
+	permissions map[string]scope.Permission
+	editor      permissioneditor.Model
+	editMode    bool
+	search      textinput.Model
+	searching   bool
+	cursor      int
+	err         error
+}
+
+var (
+	cursorStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("212"))
+	permissionStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	helpStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	errorStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+)
+
+// Browse the files in a zone. perms can hold path permissions that were set earlier
+func New(ctx *app.Context, zone scope.ZoneData, perms map[string]scope.Permission) Model {
+	search := textinput.New()
+	search.Prompt = "/"
+	m := Model{
+		client:      ctx.Client,
+		zone:        zone,
+		children:    map[string][]scope.FileEntry{},
+		loading:     map[string]bool{ROOT: true},
+		permissions: map[string]scope.Permission{},
+		search:      search,
+	}
+	for key, p := range perms {
+		if zoneId, _, ok := scope.ParsePathKey(key); ok && zoneId == zone.Id.String() {
+			m.permissions[key] = p
+		}
+	}
+	return m
+}
+
+func loadFiles(c client.ClientInterface, zone scope.UUID, path string) tea.Cmd {
+	return func() tea.Msg {
+		files, err := api.ListFiles(c, zone, path)
+		return filesMsg{zone: zone, path: path, files: files, err: err}
+	}
+}
+
+// The entries that are shown, either the tree or the search results
+func (m Model) visible() []Node {
+	query := strings.ToLower(m.search.Value())
+	if query == "" {
+		return m.nodes
+	}
+	res := []Node{}
+	for _, files := range m.children {
+		for _, f := range files {
+			if strings.Contains(strings.ToLower(f.Path), query) {
+				res = append(res, Node{entry: f})
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].entry.Path < res[j].entry.Path
+	})
+	return res
+}
+
+func (m Model) indexOf(path string) int {
+	for i, n := range m.nodes {
+		if n.entry.Path == path {
+			return i
+		}
+	}
+	return -1
+}
+
+// Show the loaded children of an expanded folder in the tree
+func (m Model) insertChildren(path string) Model {
+	i := m.indexOf(path)
+	if i < 0 || !m.nodes[i].expanded {
+		return m
+	}
+	if i+1 < len(m.nodes) && m.nodes[i+1].depth > m.nodes[i].depth {
+		return m
+	}
+	children := []Node{}
+	for _, f := range m.children[path] {
+		children = append(children, Node{entry: f, depth: m.nodes[i].depth + 1})
+	}
+	nodes := append([]Node{}, m.nodes[:i+1]...)
+	nodes = append(nodes, children...)
+	m.nodes = append(nodes, m.nodes[i+1:]...)
+	return m
+}
+
+func (m Model) collapse(i int) Model {
+	end := i + 1
+	for end < len(m.nodes) && m.nodes[end].depth > m.nodes[i].depth {
+		end++
+	}
+	m.nodes[i].expanded = false
+	m.nodes = append(m.nodes[:i+1], m.nodes[end:]...)
+	return m
+}
+
+func (m Model) toggle(i int) (Model, tea.Cmd) {
+	node := m.nodes[i]
+	if !node.entry.IsFolder {
+		return m, nil
+	}
+	if node.expanded {
+		return m.collapse(i), nil
+	}
+	m.nodes[i].expanded = true
+	if _, ok := m.children[node.entry.Path]; ok {
+		return m.insertChildren(node.entry.Path), nil
+	}
+	if m.loading[node.entry.Path] {
+		return m, nil
+	}
+	m.loading[node.entry.Path] = true
+	return m, loadFiles(m.client, m.zone.Id, node.entry.Path)
+}
+
+// Move the cursor to the parent folder, collapsing it
+func (m Model) parent(i int) Model {
+	if m.nodes[i].expanded {
+		return m.collapse(i)
+	}
+	for p := i - 1; p >= 0; p-- {
+		if m.nodes[p].depth < m.nodes[i].depth {
+			m.cursor = p
+			return m.collapse(p)
+		}
+	}
+	return m
+}
+
+func (m Model) edit(entry scope.FileEntry) Model {
+	key := scope.PathKey(m.zone.Id, entry.Path)
+	m.editor = permissioneditor.New(key, m.zone.Name+":"+entry.Path).SetPermission(m.permissions[key])
+	m.editMode = true
+	return m
+}
+
+func (m Model) done() tea.Cmd {
+	return func() tea.Msg {
+		return PathPermissions{
+			Zone:        m.zone,
+			Permissions: m.permissions,
+		}
+	}
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case filesMsg:
+		if msg.zone != m.zone.Id {
+			return m, nil
+		}
+		delete(m.loading, msg.path)
+		if msg.err != nil {
+			m.err = msg.err
+			if i := m.indexOf(msg.path); i >= 0 {
+				m.nodes[i].expanded = false
+			}
+			return m, nil
+		}
+		m.children[msg.path] = msg.files
+		if msg.path == ROOT {
+			m.nodes = []Node{}
+			for _, f := range msg.files {
+				m.nodes = append(m.nodes, Node{entry: f})
+			}
+			return m, nil
+		}
+		return m.insertChildren(msg.path), nil
+
+	case permissioneditor.PermissionMessage:
+		m.editMode = false
+		if msg.Flag == 0 {
+			delete(m.permissions, msg.Name)
+		} else {
+			m.permissions[msg.Name] = scope.CreatePermission(msg.Flag)
+		}
+		return m, nil
+	}
+
+	if m.editMode {
+		if msg, ok := msg.(tea.KeyMsg); ok && msg.String() == "esc" {
+			m.editMode = false
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.editor, cmd = m.editor.Update(msg)
+		return m, cmd
+	}
+
+	if m.searching {
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "enter":
+				m.searching = false
+				m.search.Blur()
+				return m, nil
+			case "esc":
+				m.searching = false
+				m.search.Blur()
+				m.search.SetValue("")
+				m.cursor = 0
+				return m, nil
+			}
+		}
+		var cmd tea.Cmd
+		m.search, cmd = m.search.Update(msg)
+		m.cursor = 0
+		return m, cmd
+	}
+
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	visible := m.visible()
+	searchActive := m.search.Value() != ""
+	switch key.String() {
+	case "j", "down", "tab":
+		m.cursor = min(m.cursor+1, len(visible)-1)
+	case "k", "up", "shift+tab":
+		m.cursor = max(m.cursor-1, 0)
+	case "/":
+		m.searching = true
+		return m, m.search.Focus()
+	case "esc":
+		if searchActive {
+			m.search.SetValue("")
+			m.cursor = 0
+			return m, nil
+		}
+		return m, m.done()
+	}
+	if len(visible) == 0 || m.cursor < 0 {
+		return m, nil
+	}
+
+	current := visible[m.cursor]
+	switch key.String() {
+	case "p":
+		return m.edit(current.entry), nil
+	case "x":
+		delete(m.permissions, scope.PathKey(m.zone.Id, current.entry.Path))
+	case "enter", "l", "right":
+		if !searchActive {
+			return m.toggle(m.cursor)
+		}
+	case "h", "left":
+		if !searchActive {
+			return m.parent(m.cursor), nil
+		}
+	}
+	return m, nil
+}
+
+func (m Model) View() string {
+	if m.editMode {
+		return m.editor.View()
+	}
+
+	doc := strings.Builder{}
+	doc.WriteString(m.zone.Name + "\n----------\n")
+	if m.loading[ROOT] {
+		doc.WriteString("Loading...\n")
+	}
+	searchActive := m.search.Value() != ""
+	for i, n := range m.visible() {
+		line := strings.Repeat("  ", n.depth)
+		switch {
+		case !n.entry.IsFolder:
+			line += "  "
+		case n.expanded:
+			line += "▾ "
+		default:
+			line += "▸ "
+		}
+		if searchActive {
+			line += n.entry.Path
+		} else {
+			line += n.entry.Name
+		}
+		if n.entry.IsFolder {
+			line += "/"
+		}
+		if m.loading[n.entry.Path] {
+			line += " ..."
+		}
+		if p, ok := m.permissions[scope.PathKey(m.zone.Id, n.entry.Path)]; ok {
+			line += " " + permissionStyle.Render("["+strings.Join(scope.PermissionNames(p), ",")+"]")
+		}
+		if i == m.cursor {
+			line = cursorStyle.Render(line + " <")
+		}
+		doc.WriteString(line + "\n")
+	}
+	if m.searching || searchActive {
+		doc.WriteString("\n" + m.search.View() + "\n")
+	}
+	if m.err != nil {
+		doc.WriteString(errorStyle.Render(m.err.Error()) + "\n")
+	}
+	doc.WriteString(helpStyle.Render("\nenter open • h close • p permissions • x remove • / search • esc done"))
+	return doc.String()
+}
+
+func (m Model) Init() tea.Cmd {
+	return loadFiles(m.client, m.zone.Id, ROOT)
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
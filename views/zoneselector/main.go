@@ -1,9 +1,12 @@
 package zoneselector
 
 import (
+	"compass/app"
 	"compass/bubbles/checkbox"
 	"compass/bubbles/permissioneditor"
+	"compass/client"
 	"compass/scope"
+	"compass/views/zonebrowser"
 	"fmt"
 	"strings"
 
@@ -15,22 +18,48 @@ import (
 type ZoneSelect struct {
 	zone  scope.ZoneData
 	input checkbox.Model
+	// Selected from existing permissions, dropped again when unchecked
+	cloned bool
 }
 
 type PermissionCollection map[string]scope.Permission
 
+var helpStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+
 type Model struct {
+	// Passed on to the zone browser
+	ctx              *app.Context
+	client           client.ClientInterface
 	zones            []ZoneSelect
 	zoneQueue        queue.Queue[scope.ZoneData]
 	permissions      PermissionCollection
 	permissionEditor permissioneditor.Model
+	browser          zonebrowser.Model
+	browsing         bool
 	selected         int
 	editMode         bool
 	finished         bool
 }
 
-func New(zones []scope.ZoneData) Model {
+// Start from existing permissions, e.g. the scope of a token that is cloned.
+// Zones with permissions are selected and their permissions are shown in the editor
+func WithPermissions(perms map[string]scope.Permission) func(*Model) {
+	return func(m *Model) {
+		for key, p := range perms {
+			m.permissions[key] = p
+		}
+		for i := range m.zones {
+			_, ok := perms[permissionZoneName(m.zones[i].zone)]
+			m.zones[i].input.SetChecked(ok)
+			m.zones[i].cloned = ok
+		}
+	}
+}
+
+func New(ctx *app.Context, zones []scope.ZoneData, options ...func(*Model)) Model {
 	m := Model{
+		ctx:              ctx,
+		client:           ctx.Client,
 		zoneQueue:        queue.NewQueue[scope.ZoneData](len(zones)),
 		permissionEditor: permissioneditor.New("", ""),
 		permissions:      PermissionCollection{},
@@ -44,20 +73,50 @@ func New(zones []scope.ZoneData) Model {
 			input: cb,
 		})
 	}
+	for _, o := range options {
+		o(&m)
+	}
 	return m
 }
 
 func permissionZoneName(z scope.ZoneData) string {
-	return "Zone=" + z.Id.String()
+	return scope.ZoneKey(z.Id)
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 
+	if msg, ok := msg.(zonebrowser.PathPermissions); ok {
+		for key := range m.permissions {
+			if zoneId, _, ok := scope.ParsePathKey(key); ok && zoneId == msg.Zone.Id.String() {
+				delete(m.permissions, key)
+			}
+		}
+		for key, p := range msg.Permissions {
+			m.permissions[key] = p
+		}
+		for index := range m.zones {
+			if m.zones[index].zone.Id == msg.Zone.Id {
+				m.zones[index].cloned = false
+			}
+		}
+		m.browsing = false
+		return m, nil
+	}
+
+	if m.browsing {
+		m.browser, cmd = m.browser.Update(msg)
+		return m, cmd
+	}
+
 	switch msg := msg.(type) {
 
 	case permissioneditor.PermissionMessage:
-		m.permissions[msg.Name] = scope.CreatePermission(msg.Flag)
+		if msg.Flag == 0 {
+			delete(m.permissions, msg.Name)
+		} else {
+			m.permissions[msg.Name] = scope.CreatePermission(msg.Flag)
+		}
 		m.editMode = false
 		if m.finished {
 			return m, func() tea.Msg {
@@ -68,12 +127,27 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "enter":
+			if !m.editMode {
+				m.dropUnchecked()
+			}
 			for index, input := range m.zones {
 				if input.input.GetChecked() {
 					m.zones[index].input.SetChecked(false)
 					m.zoneQueue.Enqueue(input.zone)
 				}
 			}
+			if m.zoneQueue.Empty() && !m.editMode && len(m.permissions) > 0 {
+				m.finished = true
+				return m, func() tea.Msg {
+					return m.permissions
+				}
+			}
+		case "b":
+			if !m.editMode && len(m.zones) > 0 {
+				m.browser = zonebrowser.New(m.ctx, m.zones[m.selected].zone, m.permissions)
+				m.browsing = true
+				return m, m.browser.Init()
+			}
 		case "tab", "j", "down":
 			m.selected = min(m.selected+1, len(m.zones)-1)
 		case "shift+tab", "k", "up":
@@ -96,7 +170,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.permissionEditor = permissioneditor.New(
 			permissionZoneName(item),
 			item.Name,
-		)
+		).SetPermission(m.permissions[permissionZoneName(item)])
 
 		m.editMode = true
 		if m.zoneQueue.Empty() {
@@ -115,6 +189,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m Model) View() string {
+	if m.browsing {
+		return m.browser.View()
+	}
 	if m.editMode {
 		return m.permissionEditor.View()
 	}
@@ -128,14 +205,47 @@ func (m Model) View() string {
 	doc := strings.Builder{}
 	for _, zone := range m.zones {
 		doc.WriteString(zone.input.View())
+		if paths := m.pathCount(zone.zone); paths > 0 {
+			doc.WriteString(fmt.Sprintf(" (%d paths)", paths))
+		}
 		doc.WriteString("\n")
 	}
+	doc.WriteString(helpStyle.Render("\nspace select • b browse files • enter continue"))
 	return lipgloss.JoinVertical(
 		lipgloss.Top,
 		doc.String(),
 	)
 }
 
+// Removes the zone and path permissions of cloned zones that were unchecked
+func (m *Model) dropUnchecked() {
+	for index, input := range m.zones {
+		if !input.cloned {
+			continue
+		}
+		m.zones[index].cloned = false
+		if input.input.GetChecked() {
+			continue
+		}
+		delete(m.permissions, permissionZoneName(input.zone))
+		for key := range m.permissions {
+			if zoneId, _, ok := scope.ParsePathKey(key); ok && zoneId == input.zone.Id.String() {
+				delete(m.permissions, key)
+			}
+		}
+	}
+}
+
+func (m Model) pathCount(zone scope.ZoneData) int {
+	count := 0
+	for key := range m.permissions {
+		if zoneId, _, ok := scope.ParsePathKey(key); ok && zoneId == zone.Id.String() {
+			count++
+		}
+	}
+	return count
+}
+
 func (m Model) Init() tea.Cmd {
 	return nil
 }
@@ -0,0 +1,45 @@
+package zoneselector
+
+import (
+	"compass/app"
+	"compass/scope"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/uuid"
+)
+
+func TestUpdate_UncheckCloned(t *testing.T) {
+	kept := scope.ZoneData{Id: uuid.New(), Name: "Kept"}
+	dropped := scope.ZoneData{Id: uuid.New(), Name: "Dropped"}
+	browsed := scope.ZoneData{Id: uuid.New(), Name: "Browsed"}
+	perms := map[string]scope.Permission{
+		scope.ZoneKey(kept.Id):                scope.CreatePermission(scope.S_GET),
+		scope.ZoneKey(dropped.Id):             scope.CreatePermission(scope.S_GET),
+		scope.PathKey(dropped.Id, "/docs"):    scope.CreatePermission(scope.S_GET),
+		scope.PathKey(browsed.Id, "/reports"): scope.CreatePermission(scope.S_LIST),
+	}
+	m := New(&app.Context{}, []scope.ZoneData{kept, dropped, browsed}, WithPermissions(perms))
+	if !m.zones[0].input.GetChecked() || !m.zones[1].input.GetChecked() || m.zones[2].input.GetChecked() {
+		t.Fatal("expected the zones with a zone permission to be checked")
+	}
+	m.zones[1].input.SetChecked(false)
+
+	model, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = model.(Model)
+
+	tests := []struct {
+		key      string
+		expected bool
+	}{
+		{scope.ZoneKey(kept.Id), true},
+		{scope.ZoneKey(dropped.Id), false},
+		{scope.PathKey(dropped.Id, "/docs"), false},
+		{scope.PathKey(browsed.Id, "/reports"), true},
+	}
+	for _, test := range tests {
+		if _, ok := m.permissions[test.key]; ok != test.expected {
+			t.Errorf("%s: expected present to be %t, got %t", test.key, test.expected, ok)
+		}
+	}
+}